@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/backup"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runBackup сохраняет фильмы, актеров, их связи, пользователей и сессии в архив.
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	dsn := flags.String("dsn", defaultDSN(), "connection string (or FILMOTEKA_DSN)")
+	path := flags.String("file", "", "path to the archive to create (.tar.gz)")
+	noCredentials := flags.Bool("no-credentials", false, "do not save user passwords and sessions")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("backup: -file is required")
+	}
+	if err := requirePostgres("backup", *dsn); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	defer db.Close()
+
+	file, err := os.OpenFile(*path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	manifest, err := backup.NewBackupRepository(db).Backup(file, backup.Options{NoCredentials: *noCredentials})
+	if err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err != nil {
+		os.Remove(*path)
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	for _, table := range manifest.Tables {
+		log.Printf("backup %s: %d rows", table.Name, table.Rows)
+	}
+	log.Printf("backup saved to %s, schema version %d", *path, manifest.SchemaVersion)
+	return nil
+}
+
+// runRestore загружает архив, созданный командой backup, в пустую базу.
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dsn := flags.String("dsn", defaultDSN(), "connection string (or FILMOTEKA_DSN)")
+	path := flags.String("file", "", "path to the archive created by filmoteka backup")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("restore: -file is required")
+	}
+	if err := requirePostgres("restore", *dsn); err != nil {
+		return err
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer file.Close()
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	defer db.Close()
+
+	manifest, err := backup.NewBackupRepository(db).Restore(file)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	for _, table := range manifest.Tables {
+		log.Printf("restore %s: %d rows", table.Name, table.Rows)
+	}
+	if !manifest.Credentials {
+		log.Println("archive has no credentials: user passwords must be reset before login")
+	}
+	return nil
+}
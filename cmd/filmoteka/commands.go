@@ -0,0 +1,40 @@
+package main
+
+import (
+	"filmoteka/internal/sqlite"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commands - подкоманды бинарника. Без подкоманды filmoteka запускает http-сервер.
+var commands = map[string]func(args []string) error{
+	"backup":  runBackup,
+	"restore": runRestore,
+	"import":  runImport,
+	"imdb":    runImdb,
+	"migrate": runMigrate,
+}
+
+func runCommand(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available commands: %s", name, strings.Join(names, ", "))
+	}
+	return command(args)
+}
+
+// requirePostgres запрещает команде работать с SQLite: backup, restore и imdb
+// используют возможности PostgreSQL (снимок repeatable read, to_regclass,
+// последовательности), которых в SQLite нет.
+func requirePostgres(command, dsn string) error {
+	if sqlite.IsDSN(dsn) {
+		return fmt.Errorf("%s: SQLite is not supported, use a PostgreSQL connection string", command)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"filmoteka/internal/imdb"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runImdb загружает фильмы, актеров и составы из датасетов IMDb (https://datasets.imdbws.com).
+// Повторный запуск продолжает прерванный импорт и не создает дубликатов.
+func runImdb(args []string) error {
+	flags := flag.NewFlagSet("imdb", flag.ExitOnError)
+	dsn := flags.String("dsn", defaultDSN(), "connection string (or FILMOTEKA_DSN)")
+	titles := flags.String("titles", "", "path to title.basics.tsv(.gz)")
+	names := flags.String("names", "", "path to name.basics.tsv(.gz)")
+	principals := flags.String("principals", "", "path to title.principals.tsv(.gz)")
+	ratings := flags.String("ratings", "", "optional path to title.ratings.tsv(.gz)")
+	types := flags.String("types", "movie", "comma separated titleType values to import")
+	defaultRating := flags.Int("default-rating", imdb.DefaultRating, "rating for titles without votes in -ratings")
+	batchSize := flags.Int("batch-size", imdb.DefaultBatchSize, "number of lines committed in one transaction")
+	restart := flags.Bool("restart", false, "forget saved progress and start from the beginning of the files")
+	flags.Parse(args)
+
+	if *titles == "" || *names == "" || *principals == "" {
+		return errors.New("imdb: -titles, -names and -principals are required")
+	}
+	if *defaultRating < 1 || *defaultRating > 10 {
+		return errors.New("imdb: -default-rating must be between 1 and 10")
+	}
+	if err := requirePostgres("imdb", *dsn); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return fmt.Errorf("imdb: %w", err)
+	}
+	defer db.Close()
+
+	return imdb.NewImdbRepository(db).Import(imdb.Options{
+		TitlesPath:     *titles,
+		NamesPath:      *names,
+		PrincipalsPath: *principals,
+		RatingsPath:    *ratings,
+		TitleTypes:     strings.Split(*types, ","),
+		DefaultRating:  *defaultRating,
+		BatchSize:      *batchSize,
+		Restart:        *restart,
+	})
+}
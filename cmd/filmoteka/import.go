@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/importer"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runImport импортирует фильмы из файла так же, как POST /admin/film/import,
+// и печатает отчет в stdout.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dsn := flags.String("dsn", defaultDSN(), "connection string (or FILMOTEKA_DSN)")
+	path := flags.String("file", "", "path to csv, json or ndjson file with films")
+	format := flags.String("format", "", "file format: csv, json or ndjson (by default taken from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "validate the file and print the report without saving anything")
+	batchSize := flags.Int("batch-size", importer.DefaultBatchSize, "number of films committed in one transaction")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("import: -file is required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*path)), ".")
+		if *format == "jsonl" {
+			*format = importer.FormatNDJSON
+		}
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	defer file.Close()
+
+	records, err := importer.Parse(file, *format)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	defer db.Close()
+
+	report, err := importer.NewImportRepository(db).Import(context.Background(), records, importer.Options{
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -4,11 +4,19 @@ import (
 	"database/sql"
 	_ "filmoteka/docs"
 	"filmoteka/internal/actor"
+	"filmoteka/internal/audit"
 	"filmoteka/internal/auth"
+	"filmoteka/internal/export"
 	"filmoteka/internal/film"
+	"filmoteka/internal/history"
+	"filmoteka/internal/importer"
+	"filmoteka/internal/moderation"
+	"filmoteka/internal/sqlite"
+	"filmoteka/pkg"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"log"
 	"net/http"
+	"os"
 )
 
 const dbLocal = "host=localhost port=5432 user=postgres dbname=filmoteka password=111111 sslmode=disable"
@@ -21,24 +29,67 @@ const dbDocker = "host=dbPostgres port=5432 user=postgres dbname=postgres passwo
 // @host localhost:8080
 // @basePath /
 func main() {
-	db, err := sql.Open("postgres", dbDocker)
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	err := pkg.SetDateFormat(os.Getenv("DATE_FORMAT"))
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = db.Ping()
+
+	storage := os.Getenv("STORAGE")
+	dsn := serverDSN(storage)
+	db, err := openDB(dsn)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	actorRepo := actor.NewActorRepository(db)
-	filmRepo := film.NewFilmRepository(actorRepo, db)
+	err = autoMigrate(db, dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := newCatalog(storage, db, dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	a := actor.ActorHandler{
-		ActorRepo: actorRepo,
+		ActorRepo: c.Actors,
 	}
 	f := film.FilmHandler{
-		FilmRepo: filmRepo,
+		FilmRepo:  c.Films,
+		ActorRepo: c.Actors,
+	}
+
+	au := audit.AuditHandler{
+		AuditRepo: audit.NewAuditRepository(db),
+	}
+
+	ex := export.ExportHandler{
+		FilmRepo:  c.FilmSource,
+		ActorRepo: c.Actors,
+	}
+
+	hi := history.HistoryHandler{
+		HistoryRepo: history.NewHistoryRepository(db),
+		FilmRepo:    c.Films,
+	}
+
+	im := importer.ImportHandler{
+		ImportRepo: importer.NewImportRepository(db),
+	}
+
+	m := moderation.ModerationHandler{
+		ModerationRepo: moderation.NewModerationRepository(db),
+		FilmRepo:       c.Films,
+		ActorRepo:      c.Actors,
 	}
 
 	sm := auth.NewSessionsDB(db)
@@ -48,27 +99,77 @@ func main() {
 		Sessions: sm,
 	}
 
+	// аудит, заявки, импорт и история просмотров ссылаются на фильмы и актеров
+	// в базе, поэтому с каталогом в памяти они недоступны
+	dbOnly := func(h http.HandlerFunc) http.HandlerFunc {
+		if c.InMemory {
+			return memoryUnsupported
+		}
+		return h
+	}
+
 	adminMux := http.NewServeMux()
 	adminMux.HandleFunc("/admin/actor/delete", a.DeleteActor)
 	adminMux.HandleFunc("/admin/film/update", f.UpdateFilm)
+	adminMux.HandleFunc("/admin/film/patch", pkg.ByMethod(map[string]http.HandlerFunc{
+		http.MethodPatch: f.PatchFilm,
+	}))
 	adminMux.HandleFunc("/admin/film/delete", f.DeleteFilm)
+	adminMux.HandleFunc("/admin/film/rollback", f.RollbackFilm)
+	adminMux.HandleFunc("/admin/films/", pkg.ByPath(map[string]http.HandlerFunc{
+		"/admin/films/{id}/rollback": f.RollbackFilm,
+		"/admin/films/{id}/actors/{actorId}": pkg.ByMethod(map[string]http.HandlerFunc{
+			http.MethodPost:   f.AddFilmActor,
+			http.MethodDelete: f.RemoveFilmActor,
+		}),
+	}))
+	adminMux.HandleFunc("/admin/film/actor", pkg.ByMethod(map[string]http.HandlerFunc{
+		http.MethodPost:   f.AddFilmActor,
+		http.MethodDelete: f.RemoveFilmActor,
+	}))
+	adminMux.HandleFunc("/admin/film/import", dbOnly(im.ImportFilms))
 	adminMux.HandleFunc("/admin/actor/update", a.UpdateActor)
+	adminMux.HandleFunc("/admin/actor/patch", pkg.ByMethod(map[string]http.HandlerFunc{
+		http.MethodPatch: a.PatchActor,
+	}))
+	adminMux.HandleFunc("/admin/audit", dbOnly(au.GetEntries))
+	adminMux.HandleFunc("/admin/moderation/queue", dbOnly(m.Queue))
+	adminMux.HandleFunc("/admin/moderation/approve", dbOnly(m.Approve))
+	adminMux.HandleFunc("/admin/moderation/reject", dbOnly(m.Reject))
+	adminMux.HandleFunc("/", pkg.NotFound)
 
 	adminAuthHandler := auth.AdminAuthMiddleware(sm, adminMux)
 
 	siteMux := http.NewServeMux()
 	siteMux.Handle("/admin/", adminAuthHandler)
-	siteMux.HandleFunc("/user/actor/add", a.AddActor)
-	siteMux.HandleFunc("/user/film/add", f.AddFilm)
+	siteMux.HandleFunc("/user/actor/add", moderation.AdminOrSubmit(a.AddActor, dbOnly(m.SubmitActor)))
+	siteMux.HandleFunc("/user/film/add", moderation.AdminOrSubmit(f.AddFilm, dbOnly(m.SubmitFilm)))
+	siteMux.HandleFunc("/user/submissions", dbOnly(m.MySubmissions))
+	siteMux.HandleFunc("/user/film", f.GetFilm)
+	siteMux.HandleFunc("/user/actor", a.GetActor)
+	siteMux.HandleFunc("/user/actors/", pkg.ByPath(map[string]http.HandlerFunc{
+		"/user/actors/{id}": a.GetActor,
+	}))
 	siteMux.HandleFunc("/user/film/filmsList", f.GetAllFilms)
 	siteMux.HandleFunc("/user/film/findFilms", f.FindFilms)
 	siteMux.HandleFunc("/user/film/actorsListWithFilms", f.ActorsListWithFilms)
+	siteMux.HandleFunc("/user/film/export", ex.Export)
+	siteMux.HandleFunc("/user/film/revisions", f.GetRevisions)
+	siteMux.HandleFunc("/user/film/revisionsDiff", f.DiffRevisions)
+	siteMux.HandleFunc("/user/films/", pkg.ByPath(map[string]http.HandlerFunc{
+		"/user/films/{id}":                f.GetFilm,
+		"/user/films/{id}/revisions":      f.GetRevisions,
+		"/user/films/{id}/revisions/diff": f.DiffRevisions,
+	}))
+	siteMux.HandleFunc("/user/history", dbOnly(hi.GetHistory))
+	siteMux.HandleFunc("/user/history/import", dbOnly(hi.ImportHistory))
 
 	siteMux.HandleFunc("/login", u.Login)
 	siteMux.HandleFunc("/logout", u.Logout)
 	siteMux.HandleFunc("/reg", u.Reg)
+	siteMux.HandleFunc("/", pkg.NotFound)
 
-	http.Handle("/", auth.AuthMiddleware(sm, siteMux))
+	http.Handle("/", pkg.RequestID(auth.AuthMiddleware(sm, siteMux)))
 
 	http.Handle("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
@@ -77,3 +178,38 @@ func main() {
 	http.ListenAndServe(":8080", nil)
 
 }
+
+// defaultDSN возвращает строку подключения из FILMOTEKA_DSN: строку PostgreSQL
+// или sqlite:путь/к/файлу.db.
+func defaultDSN() string {
+	if dsn := os.Getenv("FILMOTEKA_DSN"); dsn != "" {
+		return dsn
+	}
+	return dbDocker
+}
+
+// serverDSN - строка подключения сервера. С каталогом в памяти без
+// FILMOTEKA_DSN пользователи и сессии хранятся во временной базе SQLite в
+// памяти, и PostgreSQL не нужен.
+func serverDSN(storage string) string {
+	if storage == "memory" && os.Getenv("FILMOTEKA_DSN") == "" {
+		return sqlite.MemoryDSN
+	}
+	return defaultDSN()
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	if sqlite.IsDSN(dsn) {
+		return sqlite.Open(dsn)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
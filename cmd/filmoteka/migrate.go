@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/migrate"
+	"filmoteka/internal/sqlite"
+	"filmoteka/schema"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// runMigrate применяет встроенные в бинарник миграции: filmoteka migrate up|down|status [flags].
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate: expected up, down or status")
+	}
+	action := args[0]
+
+	flags := flag.NewFlagSet("migrate "+action, flag.ExitOnError)
+	dsn := flags.String("dsn", defaultDSN(), "connection string (or FILMOTEKA_DSN)")
+	to := flags.Int64("to", 0, "up: migrate to this version instead of the latest one")
+	steps := flags.Int("steps", 1, "down: number of migrations to revert")
+	all := flags.Bool("all", false, "down: revert all migrations")
+	flags.Parse(args[1:])
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(db, *dsn)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx, *to)
+		logMigrations("applied", applied)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	case "down":
+		if *all {
+			*steps = -1
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		logMigrations("reverted", reverted)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	default:
+		return fmt.Errorf("migrate: unknown action %q, expected up, down or status", action)
+	}
+	return nil
+}
+
+// autoMigrate применяет миграции при запуске сервера, если AUTO_MIGRATE=true,
+// и всегда - к временной базе в памяти.
+func autoMigrate(db *sql.DB, dsn string) error {
+	enabled, _ := strconv.ParseBool(os.Getenv("AUTO_MIGRATE"))
+	// временная база в памяти создается пустой при каждом запуске
+	if !enabled && dsn != sqlite.MemoryDSN {
+		return nil
+	}
+
+	migrator, err := newMigrator(db, dsn)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background(), 0)
+	logMigrations("applied", applied)
+	return err
+}
+
+// newMigrator выбирает миграции PostgreSQL или SQLite по строке подключения.
+func newMigrator(db *sql.DB, dsn string) (*migrate.Migrator, error) {
+	if sqlite.IsDSN(dsn) {
+		return migrate.NewSQLiteMigrator(db, schema.SQLiteMigrations)
+	}
+	return migrate.NewMigrator(db, schema.Migrations)
+}
+
+func logMigrations(action string, migrations []migrate.Migration) {
+	if len(migrations) == 0 {
+		log.Println("migrate: no migrations", action)
+	}
+	for _, migration := range migrations {
+		log.Printf("migrate: %s %d_%s", action, migration.Version, migration.Name)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/export"
+	"filmoteka/internal/film"
+	"filmoteka/internal/memory"
+	"filmoteka/internal/sqlite"
+	"filmoteka/pkg"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// кэш списков каталога: число разных запросов и время жизни ответа
+const cacheSize = 256
+const cacheTTL = 5 * time.Minute
+
+type actorStorage interface {
+	actor.Storage
+	export.ActorSource
+}
+
+// catalog - хранилища фильмов и актеров, выбранные переменной окружения STORAGE.
+type catalog struct {
+	// Films отдает списки каталога из кэша, а FilmSource читает фильмы для
+	// выгрузки напрямую из хранилища.
+	Films      film.Storage
+	FilmSource export.FilmSource
+	Actors     actorStorage
+
+	// InMemory - каталог хранится в памяти, а не в базе db.
+	InMemory bool
+}
+
+// newCatalog создает хранилище фильмов и актеров: db (по умолчанию) - база из
+// строки подключения, или memory. В памяти хранятся только фильмы и актеры,
+// пользователи и сессии остаются в базе. Кэш списков используется только с
+// PostgreSQL: его сбрасывают уведомления, которых нет в SQLite.
+func newCatalog(kind string, db *sql.DB, dsn string) (*catalog, error) {
+	switch kind {
+	case "", "db":
+		actorRepo := actor.NewActorRepository(db)
+		filmRepo := film.NewFilmRepository(actorRepo, db)
+		if sqlite.IsDSN(dsn) {
+			return &catalog{Films: filmRepo, FilmSource: filmRepo, Actors: actorRepo}, nil
+		}
+		filmCache := film.NewCachedStorage(filmRepo, pkg.NewLRU(cacheSize, cacheTTL))
+		go func() {
+			err := filmCache.Listen(context.Background(), dsn)
+			if err != nil {
+				log.Println("catalog cache listener stopped:", err)
+			}
+		}()
+		return &catalog{Films: filmCache, FilmSource: filmRepo, Actors: actorRepo}, nil
+	case "memory":
+		store := memory.NewStore()
+		filmRepo := memory.NewFilmRepository(store)
+		return &catalog{Films: filmRepo, FilmSource: filmRepo, Actors: memory.NewActorRepository(store), InMemory: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q: it must be db or memory", kind)
+	}
+}
+
+// memoryUnsupported отвечает на запросы к данным, которые ссылаются на каталог в
+// базе, когда каталог хранится в памяти.
+func memoryUnsupported(w http.ResponseWriter, r *http.Request) {
+	pkg.WriteProblem(w, r, http.StatusNotImplemented, "not available with STORAGE=memory")
+}
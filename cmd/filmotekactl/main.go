@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"filmoteka/internal/sqlite"
+	"fmt"
+	_ "github.com/lib/pq"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+const dbDocker = "host=dbPostgres port=5432 user=postgres dbname=postgres password=111111 sslmode=disable"
+
+// commands - подкоманды filmotekactl. Все они работают напрямую с базой, без запущенного сервера.
+var commands = map[string]func(args []string) error{
+	"create-user":     createUser,
+	"reset-password":  resetPassword,
+	"promote":         setAdmin(true),
+	"demote":          setAdmin(false),
+	"disable":         setDisabled(true),
+	"enable":          setDisabled(false),
+	"users":           listUsers,
+	"sessions":        listSessions,
+	"revoke-sessions": revokeSessions,
+}
+
+// filmotekactl - утилита администрирования пользователей filmoteka.
+func main() {
+	log.SetFlags(0)
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(os.Args) < 2 {
+		log.Fatalf("usage: filmotekactl <command> [flags], commands: %s", strings.Join(names, ", "))
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		log.Fatalf("unknown command %q, available commands: %s", os.Args[1], strings.Join(names, ", "))
+	}
+
+	err := command(os.Args[2:])
+	if err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	if sqlite.IsDSN(dsn) {
+		return sqlite.Open(dsn)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't connect to database: %w", err)
+	}
+	return db, nil
+}
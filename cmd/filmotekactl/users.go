@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/auth"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// userFlags создает набор флагов подкоманды с общими -dsn и -login.
+func userFlags(name string) (*flag.FlagSet, *string, *string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	dsn := flags.String("dsn", defaultDSN(), "connection string (or FILMOTEKA_DSN)")
+	login := flags.String("login", "", "user login")
+	return flags, dsn, login
+}
+
+func defaultDSN() string {
+	if dsn := os.Getenv("FILMOTEKA_DSN"); dsn != "" {
+		return dsn
+	}
+	return dbDocker
+}
+
+func withRepo(dsn string, run func(repo *auth.UserRepository) error) error {
+	db, err := openDB(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return run(auth.NewUserRepository(db))
+}
+
+// readPassword возвращает пароль из флага или, если он не задан, первую строку stdin,
+// чтобы пароль не попадал в историю командной строки.
+func readPassword(password string) (string, error) {
+	if password != "" {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("can't read password: %w", err)
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
+
+func requireLogin(login string) error {
+	if login == "" {
+		return errors.New("-login is required")
+	}
+	return nil
+}
+
+func createUser(args []string) error {
+	flags, dsn, login := userFlags("create-user")
+	password := flags.String("password", "", "user password (read from stdin if empty)")
+	admin := flags.Bool("admin", false, "create an administrator")
+	flags.Parse(args)
+
+	if err := requireLogin(*login); err != nil {
+		return err
+	}
+	pass, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	return withRepo(*dsn, func(repo *auth.UserRepository) error {
+		user, err := repo.Create(*login, pass, *admin)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("user %s created: id %d, admin %t\n", user.Login, user.ID, user.IsAdmin)
+		return nil
+	})
+}
+
+func resetPassword(args []string) error {
+	flags, dsn, login := userFlags("reset-password")
+	password := flags.String("password", "", "new password (read from stdin if empty)")
+	flags.Parse(args)
+
+	if err := requireLogin(*login); err != nil {
+		return err
+	}
+	pass, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	return withRepo(*dsn, func(repo *auth.UserRepository) error {
+		err := repo.SetPassword(*login, pass)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("password reset for %s, sessions revoked\n", *login)
+		return nil
+	})
+}
+
+func setAdmin(isAdmin bool) func(args []string) error {
+	return func(args []string) error {
+		name := "demote"
+		if isAdmin {
+			name = "promote"
+		}
+		flags, dsn, login := userFlags(name)
+		flags.Parse(args)
+
+		if err := requireLogin(*login); err != nil {
+			return err
+		}
+		return withRepo(*dsn, func(repo *auth.UserRepository) error {
+			err := repo.SetAdmin(*login, isAdmin)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("user %s: admin %t\n", *login, isAdmin)
+			return nil
+		})
+	}
+}
+
+func setDisabled(disabled bool) func(args []string) error {
+	return func(args []string) error {
+		name := "enable"
+		if disabled {
+			name = "disable"
+		}
+		flags, dsn, login := userFlags(name)
+		flags.Parse(args)
+
+		if err := requireLogin(*login); err != nil {
+			return err
+		}
+		return withRepo(*dsn, func(repo *auth.UserRepository) error {
+			err := repo.SetDisabled(*login, disabled)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("user %s: disabled %t\n", *login, disabled)
+			return nil
+		})
+	}
+}
+
+func listUsers(args []string) error {
+	flags, dsn, _ := userFlags("users")
+	flags.Parse(args)
+
+	return withRepo(*dsn, func(repo *auth.UserRepository) error {
+		users, err := repo.List()
+		if err != nil {
+			return err
+		}
+		return printJSON(users)
+	})
+}
+
+func listSessions(args []string) error {
+	flags, dsn, login := userFlags("sessions")
+	flags.Parse(args)
+
+	return withRepo(*dsn, func(repo *auth.UserRepository) error {
+		sessions, err := repo.Sessions(*login)
+		if err != nil {
+			return err
+		}
+		return printJSON(sessions)
+	})
+}
+
+func revokeSessions(args []string) error {
+	flags, dsn, login := userFlags("revoke-sessions")
+	sessionId := flags.String("id", "", "id of a single session to revoke")
+	flags.Parse(args)
+
+	if (*login == "") == (*sessionId == "") {
+		return errors.New("exactly one of -login and -id is required")
+	}
+
+	return withRepo(*dsn, func(repo *auth.UserRepository) error {
+		if *sessionId != "" {
+			err := repo.RevokeSession(*sessionId)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("session %s revoked\n", *sessionId)
+			return nil
+		}
+
+		revoked, err := repo.RevokeSessions(*login)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d sessions of %s revoked\n", revoked, *login)
+		return nil
+	})
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
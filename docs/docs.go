@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/admin/actor/delete": {
             "delete": {
-                "description": "Удаляет актера из базы данных по переданным данным актера.",
+                "description": "Удаляет актера из базы данных по переданным данным актера. Актер, указанный в фильмах, удаляется только с cascade=true вместе со связями (каждый из этих фильмов получает новую ревизию), иначе возвращается 409 со списком фильмов. Если передан If-Match, актер удаляется, только если его версия не изменилась, иначе возвращается 412.",
                 "consumes": [
                     "application/json"
                 ],
@@ -34,6 +34,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/actor.Actor"
                         }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Удалить актера вместе со связями с фильмами",
+                        "name": "cascade",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag актера",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -46,531 +58,2557 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "404": {
                         "description": "Actor not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Actor is referenced by films",
+                        "schema": {
+                            "$ref": "#/definitions/actor.ActorConflict"
+                        }
+                    },
+                    "412": {
+                        "description": "Actor was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/admin/actor/update": {
-            "put": {
-                "description": "Обновляет информацию об актере в базе данных на основе переданных данных.",
+        "/admin/actor/patch": {
+            "patch": {
+                "description": "Применяет к актеру, найденному по имени, полу и дате рождения, JSON Merge Patch (RFC 7396) или JSON Patch (RFC 6902) в зависимости от Content-Type. Сохраняются только изменившиеся поля. Возвращает обновленного актера. Без If-Match патч сохраняется, только если актера не изменили после его чтения сервером, иначе возвращается 412.",
                 "consumes": [
-                    "application/json"
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Обновляет информацию об актере",
+                "summary": "Частично обновляет актера",
                 "parameters": [
                     {
-                        "description": "Старая и новая информация об актере",
-                        "name": "actorInfo",
+                        "type": "string",
+                        "description": "Имя актера",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пол актера",
+                        "name": "gender",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата рождения актера",
+                        "name": "birth_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Патч",
+                        "name": "patch",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/actor.Actor"
-                            }
+                            "type": "object"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag актера",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "actor updated: {newActor}",
+                        "description": "Обновленный актер",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/actor.Actor"
+                        },
+                        "headers": {
+                            "Content-Location": {
+                                "type": "string",
+                                "description": "Адрес актера по id"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия актера"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "404": {
                         "description": "Actor not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "409": {
+                        "description": "Patch test failed",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
-                    }
-                }
-            }
-        },
-        "/admin/film/delete": {
-            "delete": {
-                "description": "Удаляет фильм из базы данных на основе переданных данных.",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Удаляет фильм",
-                "parameters": [
-                    {
-                        "description": "Данные фильма",
-                        "name": "film",
-                        "in": "body",
-                        "required": true,
+                    },
+                    "412": {
+                        "description": "Actor was modified",
                         "schema": {
-                            "$ref": "#/definitions/film.Film"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "film deleted",
+                    },
+                    "415": {
+                        "description": "Unsupported patch type",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
-                    "400": {
-                        "description": "Bad request",
+                    "422": {
+                        "description": "Actor violates data constraints",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/admin/film/update": {
+        "/admin/actor/update": {
             "put": {
-                "description": "Обновляет информацию о фильме в базе данных на основе переданных данных.",
+                "description": "Обновляет информацию об актере в базе данных на основе переданных данных. Если передан If-Match, актер обновляется, только если его версия не изменилась, иначе возвращается 412.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Обновляет информацию о фильме",
+                "summary": "Обновляет информацию об актере",
                 "parameters": [
                     {
-                        "description": "Старая и новая информация о фильме",
-                        "name": "filmInfo",
+                        "description": "Старая и новая информация об актере",
+                        "name": "actorInfo",
                         "in": "body",
                         "required": true,
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/film.Film"
+                                "$ref": "#/definitions/actor.Actor"
                             }
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag актера",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "film updated",
+                        "description": "actor updated: {newActor}",
                         "schema": {
                             "type": "string"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия актера"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Actor not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Actor already exists",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Actor was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Actor violates data constraints",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/login": {
-            "post": {
-                "description": "Аутентифицирует пользователя по логину и паролю, предоставленным в запросе.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/audit": {
+            "get": {
+                "description": "Возвращает записи аудита изменений фильмов и актеров с фильтрацией по сущности, пользователю и интервалу времени (RFC 3339). Записи отсортированы от новых к старым.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Аутентифицирует пользователя",
+                "summary": "Журнал изменений каталога",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Логин пользователя",
-                        "name": "login",
-                        "in": "query",
-                        "required": true
+                        "description": "Сущность (film, actor)",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Id сущности",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Id пользователя, сделавшего изменение",
+                        "name": "user_id",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Пароль пользователя",
-                        "name": "password",
-                        "in": "query",
-                        "required": true
+                        "description": "Начало интервала, например 2024-03-01T00:00:00Z",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец интервала, например 2024-03-31T23:59:59Z",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальное количество записей (по умолчанию 100)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Logged in",
+                        "description": "Записи аудита",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/audit.Entry"
+                            }
                         }
                     },
                     "400": {
-                        "description": "No user\" \"Bad pass",
+                        "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
-                        "description": "Db err",
-                        "schema": {
-                            "type": "string"
-                        }
-                    }
-                }
-            }
-        },
-        "/logout": {
-            "get": {
-                "description": "Разлогинивает текущего пользователя, уничтожая его сессию, и перенаправляет на страницу входа.",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Выход пользователя",
-                "responses": {
-                    "200": {
-                        "description": "Logged out",
+                        "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/register": {
+        "/admin/film/actor": {
             "post": {
-                "description": "Регистрирует нового пользователя, предоставляя логин, пароль и статус isAdmin.",
+                "description": "Добавляет актера в фильм, найденный по названию и дате выхода, или по id фильма и актера в пути. Актер из тела запроса, которого еще нет в базе, создается. Возвращает обновленный фильм.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Регистрирует нового пользователя",
+                "summary": "Добавляет актера в фильм",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Логин пользователя",
-                        "name": "login",
-                        "in": "query",
-                        "required": true
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Пароль пользователя",
-                        "name": "password",
-                        "in": "query",
-                        "required": true
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
                     },
                     {
-                        "type": "boolean",
-                        "description": "Статус isAdmin пользователя (true или false)",
-                        "name": "isAdmin",
-                        "in": "query",
-                        "required": true
+                        "description": "Данные актера, если он не указан в пути",
+                        "name": "actor",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/actor.Actor"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "User created",
+                    "200": {
+                        "description": "Обновленный фильм",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия фильма"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Actor not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Actor is already in the film",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/user/actor/add": {
-            "post": {
-                "description": "Добавляет нового актера в базу данных на основе переданных данных.",
+            },
+            "delete": {
+                "description": "Убирает актера из фильма, найденного по названию и дате выхода, или по id фильма и актера в пути. Сам актер остается в базе. Возвращает обновленный фильм.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Добавляет актера",
+                "summary": "Убирает актера из фильма",
                 "parameters": [
                     {
-                        "description": "Данные актера",
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Данные актера, если он не указан в пути",
                         "name": "actor",
                         "in": "body",
-                        "required": true,
                         "schema": {
                             "$ref": "#/definitions/actor.Actor"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "actor added: {actor}",
+                    "200": {
+                        "description": "Обновленный фильм",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия фильма"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
-                    "409": {
-                        "description": "Actor already exists",
+                    "404": {
+                        "description": "Actor is not in the film",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/actors": {
-            "get": {
-                "description": "Возвращает список всех актеров вместе с их фильмами из базы данных.",
+        "/admin/film/delete": {
+            "delete": {
+                "description": "Удаляет фильм из базы данных на основе переданных данных. Фильм, в котором указаны актеры, удаляется только с cascade=true вместе со связями, иначе возвращается 409 со списком актеров. Если передан If-Match, фильм удаляется, только если его версия не изменилась, иначе возвращается 412.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Получает список актеров с их фильмами",
+                "summary": "Удаляет фильм",
+                "parameters": [
+                    {
+                        "description": "Данные фильма",
+                        "name": "film",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/film.Film"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Удалить фильм вместе со связями с актерами",
+                        "name": "cascade",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "Список актеров с фильмами",
+                        "description": "film deleted",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/film.ActorListWithFilms"
-                            }
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Film has actors",
+                        "schema": {
+                            "$ref": "#/definitions/film.FilmConflict"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/film/add": {
+        "/admin/film/import": {
             "post": {
-                "description": "Добавляет новый фильм в базу данных на основе переданных данных.",
+                "description": "Импортирует фильмы с актерами из файла CSV, JSON (массив фильмов) или NDJSON, переданного в теле запроса. В CSV каждая строка - фильм и один актер (колонки title, description, release_date, rating, actor_name, actor_gender, actor_birth_date), строки одного фильма объединяются. Существующие фильмы обновляются. В режиме dry_run ничего не сохраняется, но возвращается такой же отчет.",
                 "consumes": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Добавляет фильм",
+                "summary": "Массово импортирует фильмы",
                 "parameters": [
                     {
-                        "description": "Данные фильма",
-                        "name": "film",
+                        "type": "string",
+                        "description": "Формат файла (csv, json, ndjson); по умолчанию определяется по Content-Type",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только проверить файл, не сохраняя изменений",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество фильмов в одной транзакции (по умолчанию 100)",
+                        "name": "batch_size",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Содержимое файла",
+                        "name": "file",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/film.Film"
+                            "type": "string"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "film added",
+                    "200": {
+                        "description": "Отчет по каждой строке",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/importer.Report"
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/films": {
-            "get": {
-                "description": "Возвращает список всех фильмов из базы данных, с возможностью сортировки по указанному столбцу (по умолчанию сортировка по названию).",
+        "/admin/film/patch": {
+            "patch": {
+                "description": "Применяет к фильму, найденному по названию и дате выхода, JSON Merge Patch (RFC 7396) или JSON Patch (RFC 6902) в зависимости от Content-Type. Сохраняются только изменившиеся поля, актеры заменяются, только если изменился их список. Возвращает обновленный фильм. Без If-Match патч сохраняется, только если фильм не изменили после его чтения сервером, иначе возвращается 412.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Получает список всех фильмов",
+                "summary": "Частично обновляет фильм",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Столбец для сортировки (title, release_date, rating)",
-                        "name": "sort",
-                        "in": "query"
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Патч",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Список фильмов",
+                        "description": "Обновленный фильм",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/film.Film"
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия фильма"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Patch test failed",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported patch type",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Film violates data constraints",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
         },
-        "/user/films/find": {
-            "get": {
-                "description": "Поиск фильмов в базе данных по указанной строке поиска.",
+        "/admin/film/rollback": {
+            "put": {
+                "description": "Возвращает поля и актеров фильма к состоянию указанной ревизии. Откат сохраняется как новая ревизия. Если передан If-Match, откат выполняется, только если версия фильма не изменилась, иначе возвращается 412.",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Находит фильмы по строке поиска",
+                "summary": "Откатывает фильм к ревизии",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Строка поиска",
-                        "name": "find",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер ревизии",
+                        "name": "revision",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Найденные фильмы",
+                        "description": "film rolled back",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/film.Film"
-                            }
+                            "type": "string"
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film or revision not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pkg.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "actor.Actor": {
-            "type": "object",
-            "properties": {
-                "birth_date": {
-                    "type": "string"
-                },
-                "gender": {
-                    "type": "string",
-                    "enum": [
-                        "man",
-                        "woman"
-                    ]
-                },
-                "name": {
-                    "type": "string"
-                }
-            }
         },
-        "film.ActorListWithFilms": {
-            "type": "object",
-            "properties": {
-                "actor": {
-                    "$ref": "#/definitions/actor.Actor"
-                },
-                "films": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/film.Film"
+        "/admin/film/update": {
+            "put": {
+                "description": "Полностью заменяет фильм, включая список актеров: актеры, которых нет в новой информации, убираются из фильма, а новые актеры добавляются в базу. Возвращает обновленный фильм. Если передан If-Match, фильм обновляется, только если его версия не изменилась, иначе возвращается 412.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Обновляет информацию о фильме",
+                "parameters": [
+                    {
+                        "description": "Старая и новая информация о фильме",
+                        "name": "filmInfo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/film.Film"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновленный фильм",
+                        "schema": {
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия фильма"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Film already exists",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Film violates data constraints",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
                     }
                 }
             }
         },
-        "film.Film": {
-            "type": "object",
-            "properties": {
-                "actors": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/actor.Actor"
-                    }
-                },
-                "description": {
+        "/admin/films/{id}/actors/{actorId}": {
+            "post": {
+                "description": "Добавляет актера в фильм, найденный по названию и дате выхода, или по id фильма и актера в пути. Актер из тела запроса, которого еще нет в базе, создается. Возвращает обновленный фильм.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Добавляет актера в фильм",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id фильма из Content-Location",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Id актера из Content-Location",
+                        "name": "actorId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Данные актера, если он не указан в пути",
+                        "name": "actor",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/actor.Actor"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновленный фильм",
+                        "schema": {
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия фильма"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Actor not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Actor is already in the film",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Убирает актера из фильма, найденного по названию и дате выхода, или по id фильма и актера в пути. Сам актер остается в базе. Возвращает обновленный фильм.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Убирает актера из фильма",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id фильма из Content-Location",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Id актера из Content-Location",
+                        "name": "actorId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Данные актера, если он не указан в пути",
+                        "name": "actor",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/actor.Actor"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Обновленный фильм",
+                        "schema": {
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия фильма"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Actor is not in the film",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/films/{id}/rollback": {
+            "put": {
+                "description": "Возвращает поля и актеров фильма к состоянию указанной ревизии. Откат сохраняется как новая ревизия. Если передан If-Match, откат выполняется, только если версия фильма не изменилась, иначе возвращается 412.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Откатывает фильм к ревизии",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id фильма из Content-Location",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер ревизии",
+                        "name": "revision",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "film rolled back",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film or revision not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Film was modified",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/moderation/approve": {
+            "post": {
+                "description": "Добавляет фильм или актера из заявки в каталог. Если передано тело запроса, оно заменяет данные заявки (правка перед одобрением). Запись в каталоге и статус заявки сохраняются в одной транзакции, поэтому заявка не может быть одобрена дважды.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Одобряет заявку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id заявки",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "Исправленные данные фильма или актера",
+                        "name": "data",
+                        "in": "body",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "submission approved",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Submission not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Submission already reviewed",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/moderation/queue": {
+            "get": {
+                "description": "Возвращает заявки с указанным статусом, по умолчанию ожидающие модерации.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получает очередь модерации",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Статус заявок (pending, approved, rejected)",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Заявки",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/moderation.Submission"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/moderation/reject": {
+            "post": {
+                "description": "Отклоняет заявку с указанием причины, которую увидит автор заявки.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Отклоняет заявку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id заявки",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Причина отказа",
+                        "name": "reason",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "submission rejected",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Submission not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Submission already reviewed",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Аутентифицирует пользователя по логину и паролю, предоставленным в запросе.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Аутентифицирует пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Логин пользователя",
+                        "name": "login",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пароль пользователя",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Logged in",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "No user\" \"Bad pass",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "User disabled",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Db err",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/logout": {
+            "get": {
+                "description": "Разлогинивает текущего пользователя, уничтожая его сессию, и перенаправляет на страницу входа.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Выход пользователя",
+                "responses": {
+                    "200": {
+                        "description": "Logged out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/register": {
+            "post": {
+                "description": "Регистрирует нового пользователя, предоставляя логин, пароль и статус isAdmin.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Регистрирует нового пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Логин пользователя",
+                        "name": "login",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пароль пользователя",
+                        "name": "password",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Статус isAdmin пользователя (true или false)",
+                        "name": "isAdmin",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "User created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/actor": {
+            "get": {
+                "description": "Возвращает актера по id или по имени, полу и дате рождения. Версия актера возвращается в заголовке ETag, ее можно передать в If-Match при изменении. Адрес актера по id возвращается в заголовке Content-Location.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Возвращает актера",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Имя актера",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пол актера",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата рождения актера",
+                        "name": "birth_date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Актер",
+                        "schema": {
+                            "$ref": "#/definitions/actor.Actor"
+                        },
+                        "headers": {
+                            "Content-Location": {
+                                "type": "string",
+                                "description": "Адрес актера по id"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия актера"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Actor not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/actor/add": {
+            "post": {
+                "description": "Добавляет нового актера в базу данных на основе переданных данных. Актер от пользователя без прав администратора сохраняется как заявка и попадает в каталог после модерации.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Добавляет актера",
+                "parameters": [
+                    {
+                        "description": "Данные актера",
+                        "name": "actor",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/actor.Actor"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "actor added: {actor}",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "202": {
+                        "description": "actor submitted for moderation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Actor already exists",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Actor violates data constraints",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/actors": {
+            "get": {
+                "description": "Возвращает список всех актеров вместе с их фильмами из базы данных. Если каталог не изменился с предыдущего ответа, возвращается 304.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получает список актеров с их фильмами",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag из предыдущего ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified из предыдущего ответа",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список актеров с фильмами",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/film.ActorListWithFilms"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия каталога"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Время последнего изменения каталога"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/actors/{id}": {
+            "get": {
+                "description": "Возвращает актера по id или по имени, полу и дате рождения. Версия актера возвращается в заголовке ETag, ее можно передать в If-Match при изменении. Адрес актера по id возвращается в заголовке Content-Location.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Возвращает актера",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id актера из Content-Location",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Имя актера",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пол актера",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата рождения актера",
+                        "name": "birth_date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Актер",
+                        "schema": {
+                            "$ref": "#/definitions/actor.Actor"
+                        },
+                        "headers": {
+                            "Content-Location": {
+                                "type": "string",
+                                "description": "Адрес актера по id"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия актера"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Actor not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/film": {
+            "get": {
+                "description": "Возвращает фильм вместе с актерами по id или по названию и дате выхода. Версия фильма возвращается в заголовке ETag, ее можно передать в If-Match при изменении или в If-None-Match, чтобы получить 304, если фильм не изменился. Адрес фильма по id возвращается в заголовке Content-Location.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Возвращает фильм",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма из предыдущего ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Фильм",
+                        "schema": {
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "Content-Location": {
+                                "type": "string",
+                                "description": "Адрес фильма по id"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия фильма"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/film/add": {
+            "post": {
+                "description": "Добавляет новый фильм в базу данных на основе переданных данных. Фильм от пользователя без прав администратора сохраняется как заявка и попадает в каталог после модерации.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Добавляет фильм",
+                "parameters": [
+                    {
+                        "description": "Данные фильма",
+                        "name": "film",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/film.Film"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "film added",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "202": {
+                        "description": "film submitted for moderation",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Film already exists",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Film violates data constraints",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/film/export": {
+            "get": {
+                "description": "Потоково (chunked) выгружает фильмы вместе с актерами или отдельно актеров в формате NDJSON, JSON или CSV. CSV фильмов совпадает с форматом импорта: одна строка на каждого актера фильма.",
+                "produces": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "summary": "Выгружает каталог",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Что выгружать (films, actors), по умолчанию films",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Формат (ndjson, json, csv), по умолчанию ndjson",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Столбец для сортировки фильмов (title, release_date, rating)",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Фильмы или актеры",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/film.Film"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/film/revisions": {
+            "get": {
+                "description": "Возвращает все ревизии фильма, найденного по id или по названию и дате выхода, начиная с первой. По id доступна и история удаленного фильма, его последняя ревизия - delete.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получает историю изменений фильма",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ревизии фильма",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/film.Revision"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/film/revisionsDiff": {
+            "get": {
+                "description": "Возвращает поля фильма, которые отличаются между ревизиями from и to.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Сравнивает две ревизии фильма",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер первой ревизии",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер второй ревизии",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Различия между ревизиями",
+                        "schema": {
+                            "$ref": "#/definitions/film.RevisionsDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film or revision not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/films": {
+            "get": {
+                "description": "Возвращает список всех фильмов из базы данных, с возможностью сортировки по указанному столбцу (по умолчанию сортировка по названию). Если каталог не изменился с предыдущего ответа, возвращается 304.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получает список всех фильмов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Столбец для сортировки (title, release_date, rating)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag из предыдущего ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified из предыдущего ответа",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список фильмов",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/film.Film"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия каталога"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Время последнего изменения каталога"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/films/find": {
+            "get": {
+                "description": "Поиск фильмов в базе данных по указанной строке поиска. Если каталог не изменился с предыдущего ответа, возвращается 304.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Находит фильмы по строке поиска",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Строка поиска",
+                        "name": "find",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag из предыдущего ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified из предыдущего ответа",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Найденные фильмы",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/film.Film"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия каталога"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Время последнего изменения каталога"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "No films found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/films/{id}": {
+            "get": {
+                "description": "Возвращает фильм вместе с актерами по id или по названию и дате выхода. Версия фильма возвращается в заголовке ETag, ее можно передать в If-Match при изменении или в If-None-Match, чтобы получить 304, если фильм не изменился. Адрес фильма по id возвращается в заголовке Content-Location.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Возвращает фильм",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id фильма из Content-Location",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag фильма из предыдущего ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Фильм",
+                        "schema": {
+                            "$ref": "#/definitions/film.Film"
+                        },
+                        "headers": {
+                            "Content-Location": {
+                                "type": "string",
+                                "description": "Адрес фильма по id"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия фильма"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/films/{id}/revisions": {
+            "get": {
+                "description": "Возвращает все ревизии фильма, найденного по id или по названию и дате выхода, начиная с первой. По id доступна и история удаленного фильма, его последняя ревизия - delete.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получает историю изменений фильма",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id фильма из Content-Location",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ревизии фильма",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/film.Revision"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/films/{id}/revisions/diff": {
+            "get": {
+                "description": "Возвращает поля фильма, которые отличаются между ревизиями from и to.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Сравнивает две ревизии фильма",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Id фильма из Content-Location",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название фильма",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата выхода фильма",
+                        "name": "release_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер первой ревизии",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер второй ревизии",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Различия между ревизиями",
+                        "schema": {
+                            "$ref": "#/definitions/film.RevisionsDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Film or revision not found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/history": {
+            "get": {
+                "description": "Возвращает фильмы, которые текущий пользователь оценил или посмотрел, с оценкой и датами просмотров.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получает личную историю",
+                "responses": {
+                    "200": {
+                        "description": "История пользователя",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/history.Item"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "No auth",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/history/import": {
+            "post": {
+                "description": "Загружает оценки и даты просмотров текущего пользователя из экспорта Letterboxd (ratings.csv, diary.csv или watched.csv) или Trakt (JSON истории, просмотренных или оцененных фильмов). Фильмы сопоставляются с каталогом по названию и году выхода. Оценки Letterboxd (0.5-5 звезд) переводятся в шкалу от 1 до 10. С create=true администратор может создать отсутствующие фильмы с описанием из выгрузки (overview в Trakt), иначе они попадают в отчет как unmatched. Фильм без описания не создается, строка попадает в отчет как error.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Импортирует личную историю из Letterboxd или Trakt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Источник (letterboxd, trakt)",
+                        "name": "source",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Создавать отсутствующие в каталоге фильмы (только для администратора)",
+                        "name": "create",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Содержимое файла экспорта",
+                        "name": "file",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Отчет по каждой строке",
+                        "schema": {
+                            "$ref": "#/definitions/history.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "No auth",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Only admins can create films",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error, created films are listed in created",
+                        "schema": {
+                            "$ref": "#/definitions/history.NotSaved"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/submissions": {
+            "get": {
+                "description": "Возвращает все заявки текущего пользователя с их статусом (pending, approved, rejected) и причиной отказа.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Получает заявки текущего пользователя",
+                "responses": {
+                    "200": {
+                        "description": "Заявки пользователя",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/moderation.Submission"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "No auth",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "actor.Actor": {
+            "type": "object",
+            "properties": {
+                "birth_date": {
+                    "type": "string"
+                },
+                "gender": {
+                    "type": "string",
+                    "enum": [
+                        "man",
+                        "woman"
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "actor.ActorConflict": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "can't find film: not found"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pkg.FieldError"
+                    }
+                },
+                "films": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/actor.FilmRef"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/user/film"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "5f2b6c1e9a7d4e3b8c0a1f2e3d4c5b6a"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "actor.FilmRef": {
+            "type": "object",
+            "properties": {
+                "release_date": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "audit.Entry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "diff": {
+                    "type": "object"
+                },
+                "entity": {
+                    "type": "string"
+                },
+                "entity_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "film.ActorListWithFilms": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "$ref": "#/definitions/actor.Actor"
+                },
+                "films": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/film.Film"
+                    }
+                }
+            }
+        },
+        "film.Film": {
+            "type": "object",
+            "properties": {
+                "actors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/actor.Actor"
+                    }
+                },
+                "description": {
                     "type": "string"
                 },
                 "rating": {
@@ -585,6 +2623,311 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "film.FilmConflict": {
+            "type": "object",
+            "properties": {
+                "actors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/actor.Actor"
+                    }
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "can't find film: not found"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pkg.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/user/film"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "5f2b6c1e9a7d4e3b8c0a1f2e3d4c5b6a"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "film.Revision": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "film": {
+                    "$ref": "#/definitions/film.Film"
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "film.RevisionsDiff": {
+            "type": "object",
+            "properties": {
+                "diff": {
+                    "type": "object"
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "integer"
+                }
+            }
+        },
+        "history.Item": {
+            "type": "object",
+            "properties": {
+                "rating": {
+                    "type": "integer"
+                },
+                "release_date": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "watched_at": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "history.NotSaved": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/history.RowResult"
+                    }
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "can't find film: not found"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pkg.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/user/film"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "5f2b6c1e9a7d4e3b8c0a1f2e3d4c5b6a"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "history.Report": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "matched": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/history.RowResult"
+                    }
+                },
+                "unmatched": {
+                    "type": "integer"
+                }
+            }
+        },
+        "history.RowResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "year": {
+                    "type": "string"
+                }
+            }
+        },
+        "importer.Report": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/importer.RowResult"
+                    }
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "importer.RowResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "release_date": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "moderation.Submission": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "data": {
+                    "type": "object"
+                },
+                "entity": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reviewed_at": {
+                    "type": "string"
+                },
+                "reviewed_by": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "pkg.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "release_date"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "wrong date format"
+                }
+            }
+        },
+        "pkg.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "can't find film: not found"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pkg.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/user/film"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "5f2b6c1e9a7d4e3b8c0a1f2e3d4c5b6a"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 404
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Not Found"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
         }
     }
 }`
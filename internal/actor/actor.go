@@ -1,7 +1,38 @@
 package actor
 
+import (
+	"filmoteka/pkg"
+	"fmt"
+)
+
 type Actor struct {
 	Name      string `json:"name" notempty:"true"`
-	Gender    string `json:"gender" validate:"oneof=man woman"`
-	BirthDate string `json:"birth_date" notempty:"true"`
+	Gender    string `json:"gender" notempty:"true" validate:"oneof=man woman"`
+	BirthDate string `json:"birth_date" notempty:"true" validate:"date"`
+}
+
+// FilmRef - фильм, в котором указан актер.
+type FilmRef struct {
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date"`
+}
+
+// ActorConflict - ответ на удаление актера, который еще указан в фильмах.
+type ActorConflict struct {
+	pkg.Problem
+	Films []FilmRef `json:"films"`
+}
+
+// ReferencedError возвращается при удалении актера, который еще указан в фильмах.
+// Такого актера можно удалить только вместе со связями (cascade).
+type ReferencedError struct {
+	Films []FilmRef `json:"films"`
+}
+
+func (e *ReferencedError) Error() string {
+	return fmt.Sprintf("actor is referenced by %d films", len(e.Films))
+}
+
+func (e *ReferencedError) Is(target error) bool {
+	return target == pkg.ErrConflict
 }
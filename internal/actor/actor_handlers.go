@@ -1,18 +1,23 @@
 package actor
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"filmoteka/pkg"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 )
 
 type Storage interface {
 	Add(*Actor) error
 	GetActorId(*Actor) (int64, error)
-	Update(int64, *Actor) error
-	Delete(int64) error
+	Update(context.Context, int64, *Actor) (int64, error)
+	Get(actorId int64) (*Actor, int64, error)
+	Patch(ctx context.Context, actorId int64, patched *Actor) (*Actor, int64, error)
+	Delete(ctx context.Context, actorId int64, cascade bool) error
 }
 
 type ActorHandler struct {
@@ -24,14 +29,16 @@ func NewActorHandler(actorRepo Storage) *ActorHandler {
 }
 
 // @Summary Добавляет актера
-// @Description Добавляет нового актера в базу данных на основе переданных данных.
+// @Description Добавляет нового актера в базу данных на основе переданных данных. Актер от пользователя без прав администратора сохраняется как заявка и попадает в каталог после модерации.
 // @Accept json
 // @Produce json
 // @Param actor body Actor true "Данные актера"
 // @Success 201 {string} string "actor added: {actor}"
-// @Failure 400 {string} string "Bad request"
-// @Failure 409 {string} string "Actor already exists"
-// @Failure 500 {string} string "Internal server error"
+// @Success 202 {string} string "actor submitted for moderation"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 409 {object} pkg.Problem "Actor already exists"
+// @Failure 422 {object} pkg.Problem "Actor violates data constraints"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /user/actor/add [post]
 func (h *ActorHandler) AddActor(w http.ResponseWriter, r *http.Request) {
 	var actor Actor
@@ -40,30 +47,31 @@ func (h *ActorHandler) AddActor(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&actor)
 	if err != nil {
 		log.Println("error decoding request JSON:", err)
-		http.Error(w, "can't decode request JSON", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
 		return
 	}
 
 	defer pkg.CloseBody(r)
 
-	err = pkg.DateValidation(actor.BirthDate)
-	if err != nil {
-		log.Println("wrong actor birth date format:", err)
-		http.Error(w, fmt.Sprintf("wrong actor birth date format"), http.StatusBadRequest)
+	if errs := pkg.Validate(&actor); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
 		return
 	}
 
 	_, err = h.ActorRepo.GetActorId(&actor)
 	if err == nil {
 		log.Println("error adding actor: actor already exists", err)
-		http.Error(w, "actor already exists", http.StatusConflict)
+		pkg.WriteProblem(w, r, http.StatusConflict, "actor already exists")
+		return
+	}
+	if !errors.Is(err, pkg.ErrNotFound) {
+		pkg.WriteError(w, r, err, "can't add actor")
 		return
 	}
 
 	err = h.ActorRepo.Add(&actor)
 	if err != nil {
-		log.Println("error adding actor:", err)
-		http.Error(w, fmt.Sprintf("can't add actor %w", err), http.StatusInternalServerError)
+		pkg.WriteError(w, r, err, "can't add actor")
 		return
 	}
 
@@ -72,15 +80,50 @@ func (h *ActorHandler) AddActor(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+// @Summary Возвращает актера
+// @Description Возвращает актера по id или по имени, полу и дате рождения. Версия актера возвращается в заголовке ETag, ее можно передать в If-Match при изменении. Адрес актера по id возвращается в заголовке Content-Location.
+// @Produce json
+// @Param id path int true "Id актера из Content-Location"
+// @Param name query string false "Имя актера"
+// @Param gender query string false "Пол актера"
+// @Param birth_date query string false "Дата рождения актера"
+// @Success 200 {object} Actor "Актер"
+// @Header 200 {string} ETag "Версия актера"
+// @Header 200 {string} Content-Location "Адрес актера по id"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Actor not found"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /user/actor [get]
+// @Router /user/actors/{id} [get]
+func (h *ActorHandler) GetActor(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := h.actorIdFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	actor, version, err := h.ActorRepo.Get(actorID)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get actor")
+		return
+	}
+
+	writeActor(w, r, actorID, actor, version)
+}
+
 // @Summary Обновляет информацию об актере
-// @Description Обновляет информацию об актере в базе данных на основе переданных данных.
+// @Description Обновляет информацию об актере в базе данных на основе переданных данных. Если передан If-Match, актер обновляется, только если его версия не изменилась, иначе возвращается 412.
 // @Accept json
 // @Produce json
 // @Param actorInfo body []Actor true "Старая и новая информация об актере"
+// @Param If-Match header string false "ETag актера"
 // @Success 200 {string} string "actor updated: {newActor}"
-// @Failure 400 {string} string "Bad request"
-// @Failure 404 {string} string "Actor not found"
-// @Failure 500 {string} string "Internal server error"
+// @Header 200 {string} ETag "Новая версия актера"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Actor not found"
+// @Failure 409 {object} pkg.Problem "Actor already exists"
+// @Failure 412 {object} pkg.Problem "Actor was modified"
+// @Failure 422 {object} pkg.Problem "Actor violates data constraints"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /admin/actor/update [put]
 func (h *ActorHandler) UpdateActor(w http.ResponseWriter, r *http.Request) {
 
@@ -91,7 +134,7 @@ func (h *ActorHandler) UpdateActor(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Println("error decoding request JSON:", err)
-		http.Error(w, "can't decode request JSON", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
 		return
 	}
 
@@ -100,73 +143,195 @@ func (h *ActorHandler) UpdateActor(w http.ResponseWriter, r *http.Request) {
 
 	defer pkg.CloseBody(r)
 
-	err = pkg.DateValidation(newActor.BirthDate)
-	if err != nil {
-		log.Println("wrong actor birth date format: ", err)
-		http.Error(w, fmt.Sprintf("wrong actor birth date format"), http.StatusBadRequest)
+	if errs := pkg.Validate(&newActor); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
 		return
 	}
 
 	oldActorID, err := h.ActorRepo.GetActorId(&oldActor)
 	if err != nil {
-		log.Println("error updating actor: actor not found", err)
-		http.Error(w, "actor not found", http.StatusNotFound)
+		pkg.WriteError(w, r, err, "can't find actor")
 		return
 	}
 
-	err = h.ActorRepo.Update(oldActorID, &newActor)
+	version, err := h.ActorRepo.Update(pkg.IfMatch(r), oldActorID, &newActor)
 	if err != nil {
-		log.Println("error updating actor:", err)
-		http.Error(w, fmt.Sprintf("can't update actor %w", err), http.StatusInternalServerError)
+		pkg.WriteError(w, r, err, "can't update actor")
 		return
 	}
 
 	log.Println("actor updated:", newActor)
+	pkg.SetETag(w, version)
 	w.Write([]byte(fmt.Sprintf("actor updated: %v", newActor)))
 	w.WriteHeader(http.StatusOK)
 }
 
+// @Summary Частично обновляет актера
+// @Description Применяет к актеру, найденному по имени, полу и дате рождения, JSON Merge Patch (RFC 7396) или JSON Patch (RFC 6902) в зависимости от Content-Type. Сохраняются только изменившиеся поля. Возвращает обновленного актера. Без If-Match патч сохраняется, только если актера не изменили после его чтения сервером, иначе возвращается 412.
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param name query string true "Имя актера"
+// @Param gender query string true "Пол актера"
+// @Param birth_date query string true "Дата рождения актера"
+// @Param patch body object true "Патч"
+// @Param If-Match header string false "ETag актера"
+// @Success 200 {object} Actor "Обновленный актер"
+// @Header 200 {string} ETag "Новая версия актера"
+// @Header 200 {string} Content-Location "Адрес актера по id"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Actor not found"
+// @Failure 409 {object} pkg.Problem "Patch test failed"
+// @Failure 412 {object} pkg.Problem "Actor was modified"
+// @Failure 415 {object} pkg.Problem "Unsupported patch type"
+// @Failure 422 {object} pkg.Problem "Actor violates data constraints"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/actor/patch [patch]
+func (h *ActorHandler) PatchActor(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := h.actorIdFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	current, version, err := h.ActorRepo.Get(actorID)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get actor")
+		return
+	}
+
+	var patched Actor
+	if !pkg.DecodePatch(w, r, current, &patched) {
+		return
+	}
+
+	if errs := pkg.Validate(&patched); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return
+	}
+
+	ctx := pkg.IfMatch(r)
+	if r.Header.Get("If-Match") == "" {
+		// патч применен к прочитанной версии, поэтому она не должна измениться до записи
+		ctx = pkg.WithIfMatch(ctx, pkg.ETag(version))
+	}
+
+	updated, version, err := h.ActorRepo.Patch(ctx, actorID, &patched)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't update actor")
+		return
+	}
+
+	writeActor(w, r, actorID, updated, version)
+}
+
+// actorIdFromRequest возвращает id актера из пути /user/actors/{id} или находит
+// актера по параметрам запроса. При ошибке ответ уже записан в w.
+func (h *ActorHandler) actorIdFromRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if pkg.PathValue(r, "id") != "" {
+		return pkg.PathID(w, r, "id")
+	}
+	return h.actorIdFromQuery(w, r)
+}
+
+// actorIdFromQuery находит актера по параметрам запроса name, gender и birth_date.
+// При ошибке ответ уже записан в w.
+func (h *ActorHandler) actorIdFromQuery(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	query := r.URL.Query()
+	actor := Actor{
+		Name:      query.Get("name"),
+		Gender:    query.Get("gender"),
+		BirthDate: query.Get("birth_date"),
+	}
+	if errs := pkg.Validate(&actor); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return 0, false
+	}
+
+	actorID, err := h.ActorRepo.GetActorId(&actor)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't find actor")
+		return 0, false
+	}
+	return actorID, true
+}
+
+// ActorPath возвращает адрес актера по id. Он передается в заголовке
+// Content-Location ответов с актером, по id актера можно менять состав фильма.
+func ActorPath(actorId int64) string {
+	return "/user/actors/" + strconv.FormatInt(actorId, 10)
+}
+
+func writeActor(w http.ResponseWriter, r *http.Request, actorId int64, actor *Actor, version int64) {
+	resp, err := json.Marshal(actor)
+	if err != nil {
+		log.Println("error marshalling actor:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal actor")
+		return
+	}
+
+	pkg.SetETag(w, version)
+	w.Header().Set("Content-Location", ActorPath(actorId))
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
+
 // @Summary Удаляет актера
-// @Description Удаляет актера из базы данных по переданным данным актера.
+// @Description Удаляет актера из базы данных по переданным данным актера. Актер, указанный в фильмах, удаляется только с cascade=true вместе со связями (каждый из этих фильмов получает новую ревизию), иначе возвращается 409 со списком фильмов. Если передан If-Match, актер удаляется, только если его версия не изменилась, иначе возвращается 412.
 // @Accept json
 // @Produce json
 // @Param actor body Actor true "Данные актера"
+// @Param cascade query boolean false "Удалить актера вместе со связями с фильмами"
+// @Param If-Match header string false "ETag актера"
 // @Success 200 {string} string "actor deleted: {actor}"
-// @Failure 400 {string} string "Bad request"
-// @Failure 404 {string} string "Actor not found"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Actor not found"
+// @Failure 409 {object} ActorConflict "Actor is referenced by films"
+// @Failure 412 {object} pkg.Problem "Actor was modified"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /admin/actor/delete [delete]
 func (h *ActorHandler) DeleteActor(w http.ResponseWriter, r *http.Request) {
 	var actor Actor
 
+	var cascade bool
+	var err error
+	if s := r.URL.Query().Get("cascade"); s != "" {
+		cascade, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Println("error deleting actor: wrong cascade", err)
+			pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong cascade: it must be true or false")
+			return
+		}
+	}
+
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&actor)
+	err = decoder.Decode(&actor)
 	if err != nil {
 		log.Println("error decoding request JSON:", err)
-		http.Error(w, "can't decode request JSON", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
 		return
 	}
 
 	defer pkg.CloseBody(r)
 
-	err = pkg.DateValidation(actor.BirthDate)
-	if err != nil {
-		log.Println("wrong actor birth date format: ", err)
-		http.Error(w, fmt.Sprintf("wrong actor birth date format"), http.StatusBadRequest)
+	if errs := pkg.Validate(&actor); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
 		return
 	}
 
 	actorID, err := h.ActorRepo.GetActorId(&actor)
 	if err != nil {
-		log.Println("error deleting actor: actor not found", err)
-		http.Error(w, "actor not found", http.StatusNotFound)
+		pkg.WriteError(w, r, err, "can't find actor")
 		return
 	}
 
-	err = h.ActorRepo.Delete(actorID)
-	if err != nil {
+	err = h.ActorRepo.Delete(pkg.IfMatch(r), actorID, cascade)
+	var refErr *ReferencedError
+	if errors.As(err, &refErr) {
 		log.Println("error deleting actor:", err)
-		http.Error(w, fmt.Sprintf("can't delete actor %w", err), http.StatusInternalServerError)
+		conflict := ActorConflict{Problem: *pkg.NewProblem(r, http.StatusConflict, refErr.Error()), Films: refErr.Films}
+		pkg.WriteProblemJSON(w, http.StatusConflict, conflict)
+		return
+	}
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't delete actor")
 		return
 	}
 
@@ -1,58 +1,414 @@
 package actor
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"filmoteka/internal/audit"
+	"filmoteka/pkg"
 	"fmt"
 	_ "github.com/lib/pq"
 )
 
+// Querier - общие методы *sql.DB и *sql.Tx, через которые репозиторий выполняет запросы.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 type ActorRepository struct {
-	db *sql.DB
+	db          *sql.DB
+	q           Querier
+	filmHistory FilmHistory
 }
 
+// FilmHistory выполняет fn в транзакции tx и записывает ревизии и аудит фильмов
+// filmIds, состав которых fn меняет. Ее задает film.NewFilmRepository: пакет
+// actor не может импортировать film.
+type FilmHistory func(ctx context.Context, tx *sql.Tx, filmIds []int64, fn func() error) error
+
 func NewActorRepository(db *sql.DB) *ActorRepository {
 	return &ActorRepository{
 		db: db,
+		q:  db,
 	}
 }
 
+// WithTx возвращает репозиторий, который выполняет запросы Add, GetActorId, GetOrAdd
+// и EachActor в транзакции tx. Update и Delete всегда открывают свою транзакцию.
+func (repo *ActorRepository) WithTx(tx *sql.Tx) *ActorRepository {
+	return &ActorRepository{
+		db:          repo.db,
+		q:           tx,
+		filmHistory: repo.filmHistory,
+	}
+}
+
+// SetFilmHistory задает запись истории фильмов, из которых каскадно удаляется актер.
+func (repo *ActorRepository) SetFilmHistory(history FilmHistory) {
+	repo.filmHistory = history
+}
+
 func (repo *ActorRepository) Add(actor *Actor) error {
 	op := "actor_repo.Add"
-	_, err := repo.db.Exec(`INSERT INTO actor(name, gender, birth_date) VALUES($1, $2, $3) `, actor.Name, actor.Gender, actor.BirthDate)
+	_, err := repo.q.Exec(`INSERT INTO actor(name, gender, birth_date, birth_date_precision, birth_date_approx) VALUES($1, $2, $3, $4, $5)`,
+		actor.Name, actor.Gender, pkg.DBDate(actor.BirthDate), pkg.DBPrecision(actor.BirthDate), pkg.DBApproximate(actor.BirthDate))
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	return nil
 }
 
 func (repo *ActorRepository) GetActorId(actor *Actor) (int64, error) {
 	op := "actor_repo.GetByID"
-	row := repo.db.QueryRow("SELECT id FROM actor where name = $1 and  gender = $2 and birth_date = $3",
-		actor.Name, actor.Gender, actor.BirthDate)
+	row := repo.q.QueryRow(`SELECT id FROM actor
+		WHERE name = $1 AND gender = $2 AND birth_date = $3 AND birth_date_precision = $4 AND birth_date_approx = $5`,
+		actor.Name, actor.Gender, pkg.DBDate(actor.BirthDate), pkg.DBPrecision(actor.BirthDate), pkg.DBApproximate(actor.BirthDate))
 	var actor_id int64
 	err := row.Scan(&actor_id)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	return actor_id, nil
 }
 
-func (repo *ActorRepository) Update(actor_id int64, newActor *Actor) error {
-	op := "actor_repo.UpdateActor"
-	_, err := repo.db.Query("UPDATE actor SET name = $1, gender = $2, birth_date = $3 WHERE id = $4",
-		newActor.Name, newActor.Gender, newActor.BirthDate, actor_id)
+// GetOrAdd возвращает id актера, добавляя его в базу, если такого актера еще нет.
+func (repo *ActorRepository) GetOrAdd(actor *Actor) (int64, error) {
+	op := "actor_repo.GetOrAdd"
+	actor_id, err := repo.GetActorId(actor)
+	if err == nil {
+		return actor_id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = repo.q.QueryRow(`INSERT INTO actor(name, gender, birth_date, birth_date_precision, birth_date_approx)
+		VALUES($1, $2, $3, $4, $5) RETURNING id`,
+		actor.Name, actor.Gender, pkg.DBDate(actor.BirthDate), pkg.DBPrecision(actor.BirthDate), pkg.DBApproximate(actor.BirthDate)).
+		Scan(&actor_id)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
+	return actor_id, nil
+}
+
+// EachActor по очереди передает в fn всех актеров, отсортированных по имени.
+func (repo *ActorRepository) EachActor(fn func(actor *Actor) error) error {
+	op := "actor_repo.EachActor"
 
+	rows, err := repo.q.Query("SELECT name, gender, partial_date(birth_date, birth_date_precision, birth_date_approx) FROM actor ORDER BY name, id")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var actor Actor
+		err := rows.Scan(&actor.Name, &actor.Gender, pkg.ScanDate(&actor.BirthDate))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+		if err := fn(&actor); err != nil {
+			return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
 	return nil
 }
 
-func (repo *ActorRepository) Delete(actor_id int64) error {
+// Update заменяет данные актера и возвращает его новую версию. Версия
+// проверяется по условию If-Match из ctx. Если данные не изменились, ничего не
+// записывается и версия остается прежней.
+func (repo *ActorRepository) Update(ctx context.Context, actor_id int64, newActor *Actor) (int64, error) {
+	op := "actor_repo.UpdateActor"
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer tx.Rollback()
+
+	version, err := pkg.BumpVersion(ctx, tx, "actor", actor_id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	before, err := getActorById(tx, actor_id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	if newActor.Name == before.Name && newActor.Gender == before.Gender && pkg.SameDate(newActor.BirthDate, before.BirthDate) {
+		return version - 1, nil
+	}
+
+	_, err = tx.Exec(`UPDATE actor SET name = $1, gender = $2, birth_date = $3, birth_date_precision = $4, birth_date_approx = $5 WHERE id = $6`,
+		newActor.Name, newActor.Gender, pkg.DBDate(newActor.BirthDate), pkg.DBPrecision(newActor.BirthDate), pkg.DBApproximate(newActor.BirthDate),
+		actor_id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = bumpFilmVersions(tx, actor_id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	// новое состояние читается из базы, чтобы даты в аудите были в одном формате
+	after, err := getActorById(tx, actor_id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = recordAudit(ctx, tx, actor_id, audit.ActionUpdate, before, after)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	return version, nil
+}
+
+// Get возвращает актера по id и его версию.
+func (repo *ActorRepository) Get(actor_id int64) (*Actor, int64, error) {
+	op := "actor_repo.Get"
+	row := repo.q.QueryRow("SELECT name, gender, partial_date(birth_date, birth_date_precision, birth_date_approx), version FROM actor WHERE id = $1",
+		actor_id)
+	var actor Actor
+	var version int64
+	err := row.Scan(&actor.Name, &actor.Gender, pkg.ScanDate(&actor.BirthDate), &version)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return &actor, version, nil
+}
+
+// Patch сохраняет актера patched, полученного из текущего состояния: в UPDATE
+// попадают только изменившиеся колонки. Возвращает новое состояние актера и
+// его версию. Если ничего не изменилось, версия остается прежней.
+func (repo *ActorRepository) Patch(ctx context.Context, actor_id int64, patched *Actor) (*Actor, int64, error) {
+	op := "actor_repo.Patch"
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer tx.Rollback()
+
+	version, err := pkg.BumpVersion(ctx, tx, "actor", actor_id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	before, err := getActorById(tx, actor_id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	set := &pkg.Assignments{}
+	if patched.Name != before.Name {
+		set.Set("name", patched.Name)
+	}
+	if patched.Gender != before.Gender {
+		set.Set("gender", patched.Gender)
+	}
+	if !pkg.SameDate(patched.BirthDate, before.BirthDate) {
+		set.Set("birth_date", pkg.DBDate(patched.BirthDate))
+		set.Set("birth_date_precision", pkg.DBPrecision(patched.BirthDate))
+		set.Set("birth_date_approx", pkg.DBApproximate(patched.BirthDate))
+	}
+	if set.Empty() {
+		return before, version - 1, nil
+	}
+
+	query, args := set.Update("actor", actor_id)
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = bumpFilmVersions(tx, actor_id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	after, err := getActorById(tx, actor_id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = recordAudit(ctx, tx, actor_id, audit.ActionUpdate, before, after)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	return after, version, nil
+}
+
+// Delete удаляет актера. Если актер указан в фильмах, без cascade возвращается
+// *ReferencedError со списком этих фильмов, а с cascade актер удаляется вместе со
+// связями, и каждый из этих фильмов получает ревизию и запись аудита.
+func (repo *ActorRepository) Delete(ctx context.Context, actor_id int64, cascade bool) error {
 	op := "actor_repo.DeleteActor"
-	_, err := repo.db.Query("DELETE FROM actor WHERE id = $1", actor_id)
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer tx.Rollback()
+
+	_, err = pkg.BumpVersion(ctx, tx, "actor", actor_id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	before, err := getActorById(tx, actor_id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	films, err := getActorFilms(tx, actor_id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	if len(films) > 0 {
+		if !cascade {
+			return fmt.Errorf("%s: %w", op, &ReferencedError{Films: films})
+		}
+		err = repo.unlinkFilms(ctx, tx, actor_id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM actor WHERE id = $1", actor_id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = recordAudit(ctx, tx, actor_id, audit.ActionDelete, before, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
+
 	return nil
 }
+
+// unlinkFilms убирает актера из всех фильмов и увеличивает их версии.
+func (repo *ActorRepository) unlinkFilms(ctx context.Context, tx *sql.Tx, actor_id int64) error {
+	unlink := func() error {
+		err := bumpFilmVersions(tx, actor_id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM film_actor WHERE actor_id = $1", actor_id)
+		return err
+	}
+	if repo.filmHistory == nil {
+		return unlink()
+	}
+
+	filmIds, err := getActorFilmIds(tx, actor_id)
+	if err != nil {
+		return err
+	}
+	return repo.filmHistory(ctx, tx, filmIds, unlink)
+}
+
+// getActorById читает актера внутри транзакции и блокирует его строку, чтобы до
+// конца транзакции актера нельзя было добавить в фильм.
+func getActorById(tx *sql.Tx, actor_id int64) (*Actor, error) {
+	op := "actor_repo.getActorById"
+	row := tx.QueryRow("SELECT name, gender, partial_date(birth_date, birth_date_precision, birth_date_approx) FROM actor WHERE id = $1 FOR UPDATE",
+		actor_id)
+	var actor Actor
+	err := row.Scan(&actor.Name, &actor.Gender, pkg.ScanDate(&actor.BirthDate))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return &actor, nil
+}
+
+// bumpFilmVersions увеличивает версии фильмов, в которых указан актер: актеры
+// входят в ответ с фильмом, поэтому после их изменения прежний ETag фильма
+// становится неверным.
+func bumpFilmVersions(tx *sql.Tx, actor_id int64) error {
+	_, err := tx.Exec("UPDATE film SET version = version + 1 WHERE id IN (SELECT film_id FROM film_actor WHERE actor_id = $1)", actor_id)
+	return err
+}
+
+func getActorFilmIds(tx *sql.Tx, actor_id int64) ([]int64, error) {
+	op := "actor_repo.getActorFilmIds"
+	rows, err := tx.Query("SELECT film_id FROM film_actor WHERE actor_id = $1 ORDER BY film_id", actor_id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	var filmIds []int64
+	for rows.Next() {
+		var filmId int64
+		err := rows.Scan(&filmId)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+		filmIds = append(filmIds, filmId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return filmIds, nil
+}
+
+func getActorFilms(tx *sql.Tx, actor_id int64) ([]FilmRef, error) {
+	op := "actor_repo.getActorFilms"
+	rows, err := tx.Query(`
+    SELECT f.title, partial_date(f.release_date, f.release_date_precision, f.release_date_approx)
+    FROM film f
+    JOIN film_actor fa ON fa.film_id = f.id
+    WHERE fa.actor_id = $1
+    ORDER BY f.title, f.id`, actor_id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	var films []FilmRef
+	for rows.Next() {
+		var film FilmRef
+		err := rows.Scan(&film.Title, pkg.ScanDate(&film.ReleaseDate))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+		films = append(films, film)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return films, nil
+}
+
+func recordAudit(ctx context.Context, tx *sql.Tx, actor_id int64, action string, before, after *Actor) error {
+	entry, err := audit.NewEntry(ctx, audit.EntityActor, actor_id, action, before, after)
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, entry)
+}
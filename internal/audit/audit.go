@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"filmoteka/internal/auth"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	EntityFilm  = "film"
+	EntityActor = "actor"
+
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionRollback = "rollback"
+)
+
+type Entry struct {
+	ID       int64           `json:"id"`
+	UserID   *uint32         `json:"user_id,omitempty"`
+	Entity   string          `json:"entity"`
+	EntityID int64           `json:"entity_id"`
+	Action   string          `json:"action"`
+	Before   json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After    json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	Diff     json.RawMessage `json:"diff,omitempty" swaggertype:"object"`
+	Time     time.Time       `json:"time"`
+}
+
+type Filter struct {
+	Entity   string
+	EntityID int64
+	UserID   uint32
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// NewEntry собирает запись аудита, беря пользователя из сессии в контексте.
+// Если сессии нет (например, изменение сделано не через http), UserID остается пустым.
+func NewEntry(ctx context.Context, entity string, entityId int64, action string, before, after interface{}) (*Entry, error) {
+	op := "audit.NewEntry"
+
+	entry := &Entry{
+		Entity:   entity,
+		EntityID: entityId,
+		Action:   action,
+	}
+
+	if sess, err := auth.SessionFromContext(ctx); err == nil {
+		userId := sess.UserID
+		entry.UserID = &userId
+	}
+
+	var err error
+	if entry.Before, err = marshalState(before); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if entry.After, err = marshalState(after); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if entry.Diff, err = Diff(entry.Before, entry.After); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entry, nil
+}
+
+// Diff сравнивает два JSON-объекта поле за полем и возвращает только изменившиеся поля
+// в виде {"field": {"old": ..., "new": ...}}.
+func Diff(before, after json.RawMessage) (json.RawMessage, error) {
+	oldFields, err := unmarshalFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := unmarshalFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for field, oldValue := range oldFields {
+		newValue := newFields[field]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes[field] = Change{Old: oldValue, New: newValue}
+		}
+	}
+	for field, newValue := range newFields {
+		if _, ok := oldFields[field]; !ok {
+			changes[field] = Change{Old: nil, New: newValue}
+		}
+	}
+
+	return json.Marshal(changes)
+}
+
+func marshalState(state interface{}) (json.RawMessage, error) {
+	if state == nil || reflect.ValueOf(state).Kind() == reflect.Ptr && reflect.ValueOf(state).IsNil() {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+func unmarshalFields(data json.RawMessage) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if len(data) == 0 {
+		return fields, nil
+	}
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"filmoteka/pkg"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Storage interface {
+	Find(filter *Filter) ([]Entry, error)
+}
+
+type AuditHandler struct {
+	AuditRepo Storage
+}
+
+// @Summary Журнал изменений каталога
+// @Description Возвращает записи аудита изменений фильмов и актеров с фильтрацией по сущности, пользователю и интервалу времени (RFC 3339). Записи отсортированы от новых к старым.
+// @Produce json
+// @Param entity query string false "Сущность (film, actor)"
+// @Param entity_id query int false "Id сущности"
+// @Param user_id query int false "Id пользователя, сделавшего изменение"
+// @Param from query string false "Начало интервала, например 2024-03-01T00:00:00Z"
+// @Param to query string false "Конец интервала, например 2024-03-31T23:59:59Z"
+// @Param limit query int false "Максимальное количество записей (по умолчанию 100)"
+// @Success 200 {array} Entry "Записи аудита"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/audit [get]
+func (h *AuditHandler) GetEntries(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		log.Println("error parsing audit filter:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.AuditRepo.Find(filter)
+	if err != nil {
+		log.Println("error getting audit entries:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't get audit entries")
+		return
+	}
+
+	resp, err := json.Marshal(entries)
+	if err != nil {
+		log.Println("error marshalling audit entries:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal audit entries")
+		return
+	}
+
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
+
+func parseFilter(r *http.Request) (*Filter, error) {
+	query := r.URL.Query()
+	filter := &Filter{
+		Entity: query.Get("entity"),
+	}
+
+	if filter.Entity != "" && filter.Entity != EntityFilm && filter.Entity != EntityActor {
+		return nil, errors.New("wrong entity: it must be empty, film or actor")
+	}
+
+	if s := query.Get("entity_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, errors.New("wrong entity_id: it must be a number")
+		}
+		filter.EntityID = id
+	}
+	if s := query.Get("user_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, errors.New("wrong user_id: it must be a number")
+		}
+		filter.UserID = uint32(id)
+	}
+	if s := query.Get("from"); s != "" {
+		from, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, errors.New("wrong from: it must be RFC 3339 time")
+		}
+		filter.From = from
+	}
+	if s := query.Get("to"); s != "" {
+		to, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, errors.New("wrong to: it must be RFC 3339 time")
+		}
+		filter.To = to
+	}
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return nil, errors.New("wrong limit: it must be a positive number")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const defaultLimit = 100
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+// Record пишет запись аудита в ту же транзакцию, в которой выполняется изменение,
+// чтобы изменение и запись о нем либо сохранились вместе, либо не сохранились вовсе.
+func Record(tx *sql.Tx, entry *Entry) error {
+	op := "audit_repo.Record"
+
+	_, err := tx.Exec(`INSERT INTO audit_log(user_id, entity, entity_id, action, before, after, diff) VALUES($1, $2, $3, $4, $5, $6, $7)`,
+		entry.UserID, entry.Entity, entry.EntityID, entry.Action, nullJSON(entry.Before), nullJSON(entry.After), nullJSON(entry.Diff))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (repo *AuditRepository) Find(filter *Filter) ([]Entry, error) {
+	op := "audit_repo.Find"
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Entity != "" {
+		addCondition("entity = $%d", filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		addCondition("entity_id = $%d", filter.EntityID)
+	}
+	if filter.UserID != 0 {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at <= $%d", filter.To)
+	}
+
+	query := "SELECT id, user_id, entity, entity_id, action, before, after, diff, created_at FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var userId sql.NullInt64
+		var before, after, diff []byte
+		err := rows.Scan(&entry.ID, &userId, &entry.Entity, &entry.EntityID, &entry.Action, &before, &after, &diff, &entry.Time)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if userId.Valid {
+			id := uint32(userId.Int64)
+			entry.UserID = &id
+		}
+		entry.Before, entry.After, entry.Diff = before, after, diff
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// nullJSON превращает пустое состояние (например, "после" удаления) в NULL,
+// иначе postgres не примет пустую строку как jsonb.
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"context"
+	"filmoteka/pkg"
 	"net/http"
 	"strings"
 )
@@ -14,15 +14,14 @@ func AdminAuthMiddleware(sm SessionManager, next http.Handler) http.Handler {
 		}
 		sess, err := sm.Check(r)
 		if err != nil {
-			http.Error(w, "No admin auth", http.StatusUnauthorized)
+			pkg.WriteProblem(w, r, http.StatusUnauthorized, "No admin auth")
 			return
 		}
 
 		if !sess.IsAdmin {
-			http.Error(w, "Not an admin", http.StatusForbidden)
+			pkg.WriteProblem(w, r, http.StatusForbidden, "Not an admin")
 			return
 		}
-		ctx := context.WithValue(r.Context(), sessionKey, sess)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(ContextWithSession(r.Context(), sess)))
 	})
 }
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"errors"
+	"filmoteka/pkg"
 	"net/http"
 )
 
@@ -37,6 +38,10 @@ func SessionFromContext(ctx context.Context) (*Session, error) {
 	return sess, nil
 }
 
+func ContextWithSession(ctx context.Context, sess *Session) context.Context {
+	return context.WithValue(ctx, sessionKey, sess)
+}
+
 var (
 	noAuthUrls = map[string]struct{}{
 		"/login":   struct{}{},
@@ -53,10 +58,9 @@ func AuthMiddleware(sm SessionManager, next http.Handler) http.Handler {
 		}
 		sess, err := sm.Check(r)
 		if err != nil {
-			http.Error(w, "No auth", http.StatusUnauthorized)
+			pkg.WriteProblem(w, r, http.StatusUnauthorized, "No auth")
 			return
 		}
-		ctx := context.WithValue(r.Context(), sessionKey, sess)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(ContextWithSession(r.Context(), sess)))
 	})
 }
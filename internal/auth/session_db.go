@@ -36,12 +36,17 @@ func (sm *SessionsDB) Check(r *http.Request) (*Session, error) {
 		return nil, err
 	}
 
-	rowIsAdmin := sm.DB.QueryRow(`SELECT role FROM users WHERE id = $1`, sess.UserID)
-	err = rowIsAdmin.Scan(&sess.IsAdmin)
+	var disabled bool
+	rowIsAdmin := sm.DB.QueryRow(`SELECT role, disabled FROM users WHERE id = $1`, sess.UserID)
+	err = rowIsAdmin.Scan(&sess.IsAdmin, &disabled)
 	if err != nil {
 		log.Println("CheckSession err:", err)
 		return nil, err
 	}
+	if disabled {
+		log.Println("CheckSession user disabled:", sess.UserID)
+		return nil, ErrNoAuth
+	}
 
 	return sess, nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"errors"
+	"filmoteka/pkg"
 	"golang.org/x/crypto/argon2"
 	"html/template"
 	"log"
@@ -29,8 +30,9 @@ type UserHandler struct {
 // @Param login query string true "Логин пользователя"
 // @Param password query string true "Пароль пользователя"
 // @Success 200 {string} string "Logged in"
-// @Failure 400 {string} string "No user" "Bad pass"
-// @Failure 500 {string} string "Db err"
+// @Failure 400 {object} pkg.Problem "No user" "Bad pass"
+// @Failure 403 {object} pkg.Problem "User disabled"
+// @Failure 500 {object} pkg.Problem "Db err"
 // @Router /login [post]
 func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	login := r.FormValue("login")
@@ -42,11 +44,13 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	case nil:
 		// all is ok
 	case errNoRec:
-		http.Error(w, "No user", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "No user")
 	case errBadPass:
-		http.Error(w, "Bad pass", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "Bad pass")
+	case errDisabled:
+		pkg.WriteProblem(w, r, http.StatusForbidden, "User disabled")
 	default:
-		http.Error(w, "Db err", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "Db err")
 	}
 	if err != nil {
 		return
@@ -65,8 +69,8 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Param password query string true "Пароль пользователя"
 // @Param isAdmin query boolean true "Статус isAdmin пользователя (true или false)"
 // @Success 201 {string} string "User created"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /register [post]
 func (uh *UserHandler) Reg(w http.ResponseWriter, r *http.Request) {
 	login := r.FormValue("login")
@@ -79,19 +83,19 @@ func (uh *UserHandler) Reg(w http.ResponseWriter, r *http.Request) {
 	} else if isAdminString == "false" {
 		isAdmin = false
 	} else {
-		http.Error(w, "isAdmin must be true or false", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "isAdmin must be true or false")
 		return
 	}
 	result, err := uh.DB.Exec("INSERT INTO users(login, password, role) VALUES($1, $2, $3)", login, pass, isAdmin)
 	if err != nil {
 		log.Println("insert error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	affected, _ := result.RowsAffected()
 	if affected == 0 {
-		http.Error(w, "Looks like user exists", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "Looks like user exists")
 		return
 	}
 	userID, _ := result.LastInsertId()
@@ -121,6 +125,11 @@ func (uh *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 }
 
 func (uh *UserHandler) hashPass(plainPassword, salt string) []byte {
+	return HashPass(plainPassword, salt)
+}
+
+// HashPass хеширует пароль argon2id; первые 8 байт результата - соль.
+func HashPass(plainPassword, salt string) []byte {
 	hashedPass := argon2.IDKey([]byte(plainPassword), []byte(salt), 1, 64*1024, 4, 32)
 	res := make([]byte, len(salt))
 	copy(res, salt[:len(salt)])
@@ -128,8 +137,9 @@ func (uh *UserHandler) hashPass(plainPassword, salt string) []byte {
 }
 
 var (
-	errNoRec   = errors.New("No user record found")
-	errBadPass = errors.New("No user record found")
+	errNoRec    = errors.New("No user record found")
+	errBadPass  = errors.New("No user record found")
+	errDisabled = errors.New("User disabled")
 )
 
 func (uh *UserHandler) passwordIsValid(pass string, row *sql.Row) (*User, error) {
@@ -138,40 +148,48 @@ func (uh *UserHandler) passwordIsValid(pass string, row *sql.Row) (*User, error)
 		dbPass []byte
 		user   = &User{}
 	)
-	err := row.Scan(&user.ID, &user.Login, &user.IsAdmin, &dbPass)
+	var disabled bool
+	err := row.Scan(&user.ID, &user.Login, &user.IsAdmin, &dbPass, &disabled)
 	if err == sql.ErrNoRows {
 		return nil, errNoRec
 	} else if err != nil {
 		return nil, err
 	}
 
+	// пароль короче соли не мог получиться из HashPass, под него ничего не подходит
+	if len(dbPass) < 8 {
+		return nil, errBadPass
+	}
 	salt := string(dbPass[0:8])
 	if !bytes.Equal(uh.hashPass(pass, salt), dbPass) {
 		return nil, errBadPass
 	}
+	if disabled {
+		return nil, errDisabled
+	}
 	return user, nil
 }
 
 func (uh *UserHandler) checkPasswordByUserID(uid uint32, pass string) (*User, error) {
-	row := uh.DB.QueryRow("SELECT id, login, role, password FROM users WHERE id = $1", uid)
+	row := uh.DB.QueryRow("SELECT id, login, role, password, disabled FROM users WHERE id = $1", uid)
 	return uh.passwordIsValid(pass, row)
 }
 
 func (uh *UserHandler) checkPasswordByLogin(login, pass string) (*User, error) {
-	row := uh.DB.QueryRow("SELECT id, login, role, password FROM users WHERE login = $1", login)
+	row := uh.DB.QueryRow("SELECT id, login, role, password, disabled FROM users WHERE login = $1", login)
 	return uh.passwordIsValid(pass, row)
 }
 
 func (uh *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	if r.FormValue("pass1") == "" || r.FormValue("pass1") != r.FormValue("pass2") {
-		http.Error(w, "New password mistmatch", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "New password mistmatch")
 		return
 	}
 
 	sess, _ := SessionFromContext(r.Context())
 	user, err := uh.checkPasswordByUserID(sess.UserID, r.FormValue("old_password"))
 	if err != nil {
-		http.Error(w, "Bad pass", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "Bad pass")
 		return
 	}
 
@@ -182,7 +200,7 @@ func (uh *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		pass, user.ID)
 	if err != nil {
 		log.Println("update password error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
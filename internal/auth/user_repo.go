@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"database/sql"
+	"filmoteka/pkg"
+	"fmt"
+	"time"
+)
+
+var ErrNoUser = pkg.NewError(pkg.ErrNotFound, "user not found")
+
+// UserInfo - пользователь вместе с полями, которые нужны для администрирования.
+type UserInfo struct {
+	ID       uint32 `json:"id"`
+	Login    string `json:"login"`
+	IsAdmin  bool   `json:"is_admin"`
+	Disabled bool   `json:"disabled"`
+	Sessions int    `json:"sessions"`
+}
+
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	UserID    uint32    `json:"user_id"`
+	Login     string    `json:"login"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRepository управляет пользователями и сессиями напрямую в базе, без http.
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
+
+func (repo *UserRepository) Create(login, password string, isAdmin bool) (*User, error) {
+	op := "user_repo.Create"
+
+	user := &User{
+		Login:   login,
+		IsAdmin: isAdmin,
+	}
+	err := repo.db.QueryRow("INSERT INTO users(login, password, role) VALUES($1, $2, $3) RETURNING id",
+		login, HashPass(password, RandStringRunes(8)), isAdmin).Scan(&user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+// SetPassword задает новый пароль и завершает все сессии пользователя, как ChangePassword.
+func (repo *UserRepository) SetPassword(login, password string) error {
+	op := "user_repo.SetPassword"
+	return repo.update(op, login, "UPDATE users SET password = $1 WHERE login = $2 RETURNING id",
+		HashPass(password, RandStringRunes(8)), true)
+}
+
+func (repo *UserRepository) SetAdmin(login string, isAdmin bool) error {
+	op := "user_repo.SetAdmin"
+	return repo.update(op, login, "UPDATE users SET role = $1 WHERE login = $2 RETURNING id", isAdmin, false)
+}
+
+// SetDisabled блокирует или разблокирует пользователя. При блокировке все его сессии завершаются.
+func (repo *UserRepository) SetDisabled(login string, disabled bool) error {
+	op := "user_repo.SetDisabled"
+	return repo.update(op, login, "UPDATE users SET disabled = $1 WHERE login = $2 RETURNING id", disabled, disabled)
+}
+
+func (repo *UserRepository) update(op, login, query string, value interface{}, revokeSessions bool) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var userId uint32
+	err = tx.QueryRow(query, value, login).Scan(&userId)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%s: %w", op, ErrNoUser)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if revokeSessions {
+		_, err = tx.Exec("DELETE FROM sessions WHERE user_id = $1", userId)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (repo *UserRepository) List() ([]UserInfo, error) {
+	op := "user_repo.List"
+
+	rows, err := repo.db.Query(`SELECT u.id, u.login, COALESCE(u.role, false), u.disabled, COUNT(s.id)
+		FROM users u LEFT JOIN sessions s ON s.user_id = u.id
+		GROUP BY u.id ORDER BY u.login`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	users := []UserInfo{}
+	for rows.Next() {
+		var user UserInfo
+		err = rows.Scan(&user.ID, &user.Login, &user.IsAdmin, &user.Disabled, &user.Sessions)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return users, nil
+}
+
+// Sessions возвращает сессии пользователя login или всех пользователей, если login пустой.
+func (repo *UserRepository) Sessions(login string) ([]SessionInfo, error) {
+	op := "user_repo.Sessions"
+
+	rows, err := repo.db.Query(`SELECT s.id, s.user_id, u.login, s.created_at
+		FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE $1 = '' OR u.login = $1
+		ORDER BY s.created_at`, login)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	sessions := []SessionInfo{}
+	for rows.Next() {
+		var session SessionInfo
+		err = rows.Scan(&session.ID, &session.UserID, &session.Login, &session.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession завершает одну сессию по ее id.
+func (repo *UserRepository) RevokeSession(sessionId string) error {
+	op := "user_repo.RevokeSession"
+
+	result, err := repo.db.Exec("DELETE FROM sessions WHERE id = $1", sessionId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: session %s not found", op, sessionId)
+	}
+	return nil
+}
+
+// RevokeSessions завершает все сессии пользователя и возвращает их количество.
+func (repo *UserRepository) RevokeSessions(login string) (int64, error) {
+	op := "user_repo.RevokeSessions"
+
+	var exists bool
+	err := repo.db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE login = $1)", login).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("%s: %w", op, ErrNoUser)
+	}
+
+	result, err := repo.db.Exec("DELETE FROM sessions WHERE user_id = (SELECT id FROM users WHERE login = $1)", login)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected, nil
+}
@@ -0,0 +1,146 @@
+package backup
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"filmoteka/pkg"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormatVersion - версия формата архива. Увеличивается при несовместимых изменениях
+// структуры архива, а не схемы базы: версия схемы хранится отдельно в SchemaVersion.
+const FormatVersion = 1
+
+const manifestName = "manifest.json"
+
+// Table - таблица, попадающая в архив.
+type Table struct {
+	Name string
+	// OrderBy - порядок строк в архиве, по умолчанию id.
+	OrderBy string
+	// Serial - у таблицы есть последовательность для id, которую нужно сдвинуть после восстановления.
+	Serial bool
+	// ReservedIds - запросы, возвращающие наибольший id, который уже встречался
+	// в других таблицах (например, у удаленного фильма, чьи ревизии остались).
+	// Последовательность сдвигается и за них, чтобы новые записи не получили эти id.
+	ReservedIds []string
+	// Credentials - таблица целиком состоит из секретов и не попадает в архив без учетных данных.
+	Credentials bool
+	// CredentialColumns - колонки с секретами. В архиве без учетных данных их нет,
+	// а при восстановлении они заполняются значением функции для каждой строки.
+	CredentialColumns map[string]func() (interface{}, error)
+}
+
+// Tables - таблицы в порядке восстановления, чтобы внешние ключи ссылались на уже загруженные строки.
+// В архив не попадают imdb_import_progress (состояние прерванного импорта имеет
+// смысл только в исходной базе), catalog_version (его ведут триггеры) и
+// schema_migrations (версию схемы задают миграции целевой базы).
+var Tables = []Table{
+	{Name: "users", Serial: true, CredentialColumns: map[string]func() (interface{}, error){"password": lockedPassword}},
+	{Name: "actor", Serial: true, ReservedIds: []string{
+		"SELECT MAX(entity_id) FROM audit_log WHERE entity = 'actor'",
+	}},
+	{Name: "film", Serial: true, ReservedIds: []string{
+		"SELECT MAX(film_id) FROM film_revision",
+		"SELECT MAX(entity_id) FROM audit_log WHERE entity = 'film'",
+	}},
+	{Name: "film_actor", Serial: true},
+	{Name: "film_revision", Serial: true},
+	{Name: "audit_log", Serial: true},
+	{Name: "submission", Serial: true},
+	{Name: "user_film_rating", OrderBy: "user_id, film_id"},
+	{Name: "user_film_watch", Serial: true},
+	{Name: "sessions", Credentials: true},
+}
+
+// lockedPassword - пароль пользователя, восстановленного из архива без учетных
+// данных: случайные соль и хеш той же длины, что у auth.HashPass. Под него не
+// подходит ни один пароль, войти можно будет после reset-password.
+func lockedPassword() (interface{}, error) {
+	password := make([]byte, 8+32)
+	_, err := rand.Read(password)
+	if err != nil {
+		return nil, err
+	}
+	return password, nil
+}
+
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type TableManifest struct {
+	Name    string   `json:"name"`
+	File    string   `json:"file"`
+	Columns []Column `json:"columns"`
+	Rows    int64    `json:"rows"`
+	SHA256  string   `json:"sha256"`
+}
+
+type Manifest struct {
+	FormatVersion int             `json:"format_version"`
+	SchemaVersion int64           `json:"schema_version"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Credentials   bool            `json:"credentials"`
+	Tables        []TableManifest `json:"tables"`
+}
+
+type Options struct {
+	// NoCredentials исключает из архива пароли пользователей и сессии.
+	NoCredentials bool
+}
+
+// encodeValue переводит значение колонки в JSON. Даты сохраняются в ISO 8601, бинарные
+// колонки кодируются в base64, JSON-колонки сохраняются как есть, остальные байтовые
+// значения - как строки.
+func encodeValue(columnType string, value interface{}) interface{} {
+	if date, ok := value.(time.Time); ok && strings.ToUpper(columnType) == "DATE" {
+		return date.Format(pkg.DateFormatISO)
+	}
+	data, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	switch strings.ToUpper(columnType) {
+	case "BYTEA":
+		return base64.StdEncoding.EncodeToString(data)
+	case "JSON", "JSONB":
+		return json.RawMessage(data)
+	default:
+		return string(data)
+	}
+}
+
+// decodeValue - обратное преобразование для encodeValue.
+func decodeValue(columnType string, value interface{}) (interface{}, error) {
+	switch strings.ToUpper(columnType) {
+	case "BYTEA":
+		s, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("wrong base64 value: %w", err)
+		}
+		return data, nil
+	case "JSON", "JSONB":
+		if value == nil {
+			return nil, nil
+		}
+		return json.Marshal(value)
+	default:
+		number, ok := value.(json.Number)
+		if !ok {
+			return value, nil
+		}
+		if i, err := number.Int64(); err == nil {
+			return i, nil
+		}
+		return number.Float64()
+	}
+}
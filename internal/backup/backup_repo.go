@@ -0,0 +1,449 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type BackupRepository struct {
+	db *sql.DB
+}
+
+func NewBackupRepository(db *sql.DB) *BackupRepository {
+	return &BackupRepository{
+		db: db,
+	}
+}
+
+// Backup пишет в w архив tar.gz: manifest.json с версией схемы, колонками и
+// контрольными суммами, и по одному NDJSON файлу на таблицу. Все таблицы читаются
+// из одного снимка базы.
+func (repo *BackupRepository) Backup(w io.Writer, opts Options) (*Manifest, error) {
+	op := "backup_repo.Backup"
+
+	tx, err := repo.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Credentials:   !opts.NoCredentials,
+	}
+	manifest.SchemaVersion, err = schemaVersion(tx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// размер файла в tar нужно знать заранее, поэтому таблицы сначала пишутся во временный каталог
+	dir, err := os.MkdirTemp("", "filmoteka-backup")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, table := range Tables {
+		if table.Credentials && opts.NoCredentials {
+			continue
+		}
+		tableManifest, err := dumpTable(tx, table, opts, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, table.Name, err)
+		}
+		manifest.Tables = append(manifest.Tables, *tableManifest)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = writeArchive(w, manifest, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return manifest, nil
+}
+
+func dumpTable(tx *sql.Tx, table Table, opts Options, dir string) (*TableManifest, error) {
+	orderBy := table.OrderBy
+	if orderBy == "" {
+		orderBy = "id"
+	}
+	rows, err := tx.Query(fmt.Sprintf("SELECT * FROM %s ORDER BY %s", pq.QuoteIdentifier(table.Name), orderBy))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	tableManifest := &TableManifest{
+		Name: table.Name,
+		File: table.Name + ".ndjson",
+	}
+	var keep []int
+	for i, columnType := range columnTypes {
+		if _, ok := table.CredentialColumns[columnType.Name()]; ok && opts.NoCredentials {
+			continue
+		}
+		keep = append(keep, i)
+		tableManifest.Columns = append(tableManifest.Columns, Column{
+			Name: columnType.Name(),
+			Type: columnType.DatabaseTypeName(),
+		})
+	}
+
+	file, err := os.Create(filepath.Join(dir, tableManifest.File))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sum := sha256.New()
+	encoder := json.NewEncoder(io.MultiWriter(file, sum))
+
+	values := make([]interface{}, len(columnTypes))
+	pointers := make([]interface{}, len(columnTypes))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, 0, len(keep))
+		for _, i := range keep {
+			row = append(row, encodeValue(columnTypes[i].DatabaseTypeName(), values[i]))
+		}
+		err = encoder.Encode(row)
+		if err != nil {
+			return nil, err
+		}
+		tableManifest.Rows++
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tableManifest.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	return tableManifest, file.Close()
+}
+
+func writeArchive(w io.Writer, manifest *Manifest, dir string) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = archive.WriteHeader(&tar.Header{Name: manifestName, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	_, err = archive.Write(data)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range manifest.Tables {
+		err = addFile(archive, filepath.Join(dir, table.File), table.File, manifest.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFile(archive *tar.Writer, path, name string, modTime time.Time) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	err = archive.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: info.Size(), ModTime: modTime})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(archive, file)
+	return err
+}
+
+// Restore загружает архив, созданный Backup, в пустую базу той же версии схемы.
+// Все таблицы загружаются в одной транзакции, которая откатывается, если
+// контрольная сумма или число строк какого-либо файла не совпадает с manifest.json.
+func (repo *BackupRepository) Restore(r io.Reader) (*Manifest, error) {
+	op := "backup_repo.Restore"
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+
+	manifest, err := readManifest(archive)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	version, err := schemaVersion(tx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if version != 0 && manifest.SchemaVersion != 0 && version != manifest.SchemaVersion {
+		return nil, fmt.Errorf("%s: archive has schema version %d, database has %d", op, manifest.SchemaVersion, version)
+	}
+
+	for _, table := range Tables {
+		var exists bool
+		err = tx.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", pq.QuoteIdentifier(table.Name))).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if exists {
+			return nil, fmt.Errorf("%s: database is not empty: table %s has rows", op, table.Name)
+		}
+	}
+
+	for _, tableManifest := range manifest.Tables {
+		header, err := archive.Next()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, tableManifest.File, err)
+		}
+		if header.Name != tableManifest.File {
+			return nil, fmt.Errorf("%s: expected %s in archive, got %s", op, tableManifest.File, header.Name)
+		}
+
+		err = restoreTable(tx, tableManifest, archive)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, tableManifest.Name, err)
+		}
+	}
+
+	for _, table := range Tables {
+		if !table.Serial {
+			continue
+		}
+		ids := []string{fmt.Sprintf("(SELECT MAX(id) FROM %s)", table.Name)}
+		for _, query := range table.ReservedIds {
+			ids = append(ids, "("+query+")")
+		}
+		_, err = tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(max_id, 1), max_id IS NOT NULL) FROM (SELECT GREATEST(%s) AS max_id) AS ids",
+			table.Name, strings.Join(ids, ", ")))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return manifest, nil
+}
+
+func readManifest(archive *tar.Reader) (*Manifest, error) {
+	header, err := archive.Next()
+	if err != nil {
+		return nil, fmt.Errorf("can't read archive: %w", err)
+	}
+	if header.Name != manifestName {
+		return nil, fmt.Errorf("archive must start with %s, got %s", manifestName, header.Name)
+	}
+
+	manifest := &Manifest{}
+	err = json.NewDecoder(archive).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode %s: %w", manifestName, err)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d", manifest.FormatVersion)
+	}
+
+	// имена таблиц и колонок попадают в SQL, поэтому принимаются только известные таблицы
+	for _, tableManifest := range manifest.Tables {
+		if _, ok := findTable(tableManifest.Name); !ok {
+			return nil, fmt.Errorf("unknown table %q in %s", tableManifest.Name, manifestName)
+		}
+	}
+	return manifest, nil
+}
+
+func findTable(name string) (Table, bool) {
+	for _, table := range Tables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return Table{}, false
+}
+
+func restoreTable(tx *sql.Tx, tableManifest TableManifest, r io.Reader) error {
+	table, _ := findTable(tableManifest.Name)
+
+	err := checkColumns(tx, tableManifest)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(tableManifest.Columns))
+	present := make(map[string]bool)
+	for _, column := range tableManifest.Columns {
+		columns = append(columns, pq.QuoteIdentifier(column.Name))
+		present[column.Name] = true
+	}
+	var defaults []func() (interface{}, error)
+	for name, value := range table.CredentialColumns {
+		if !present[name] {
+			columns = append(columns, pq.QuoteIdentifier(name))
+			defaults = append(defaults, value)
+		}
+	}
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		pq.QuoteIdentifier(table.Name), strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	sum := sha256.New()
+	decoder := json.NewDecoder(io.TeeReader(r, sum))
+	decoder.UseNumber()
+
+	var count int64
+	for {
+		var row []interface{}
+		err = decoder.Decode(&row)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %w", count+1, err)
+		}
+		if len(row) != len(tableManifest.Columns) {
+			return fmt.Errorf("row %d: expected %d values, got %d", count+1, len(tableManifest.Columns), len(row))
+		}
+
+		args := make([]interface{}, 0, len(columns))
+		for i, value := range row {
+			value, err = decodeValue(tableManifest.Columns[i].Type, value)
+			if err != nil {
+				return fmt.Errorf("row %d: %s: %w", count+1, tableManifest.Columns[i].Name, err)
+			}
+			args = append(args, value)
+		}
+		for _, value := range defaults {
+			arg, err := value()
+			if err != nil {
+				return fmt.Errorf("row %d: %w", count+1, err)
+			}
+			args = append(args, arg)
+		}
+
+		_, err = stmt.Exec(args...)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", count+1, err)
+		}
+		count++
+	}
+
+	if checksum := hex.EncodeToString(sum.Sum(nil)); checksum != tableManifest.SHA256 {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", tableManifest.SHA256, checksum)
+	}
+	if count != tableManifest.Rows {
+		return fmt.Errorf("expected %d rows, got %d", tableManifest.Rows, count)
+	}
+	return nil
+}
+
+// checkColumns проверяет, что все колонки таблицы из архива есть в базе. Это
+// главная проверка совместимости схемы: версия миграций известна не всегда.
+func checkColumns(tx *sql.Tx, tableManifest TableManifest) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT * FROM %s LIMIT 0", pq.QuoteIdentifier(tableManifest.Name)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+	for _, column := range tableManifest.Columns {
+		if !existing[column.Name] {
+			return fmt.Errorf("column %s is missing in database", column.Name)
+		}
+	}
+	return rows.Err()
+}
+
+// schemaVersion возвращает версию схемы из таблицы schema_migrations, которую ведет
+// migrate. Если база создана без migrate и таблицы нет, версия неизвестна и
+// возвращается 0: тогда совместимость проверяется только по колонкам таблиц.
+func schemaVersion(tx *sql.Tx) (int64, error) {
+	var exists bool
+	err := tx.QueryRow("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("can't get schema version: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int64
+	var dirty bool
+	err = tx.QueryRow("SELECT version, dirty FROM schema_migrations").Scan(&version, &dirty)
+	if err != nil {
+		return 0, fmt.Errorf("can't get schema version: %w", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema version %d is dirty", version)
+	}
+	return version, nil
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+
+	EntityFilms  = "films"
+	EntityActors = "actors"
+)
+
+var contentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+	FormatCSV:    "text/csv; charset=utf-8",
+}
+
+// FilmsCSVHeader совпадает с форматом импорта, поэтому выгрузку можно загрузить обратно.
+var FilmsCSVHeader = []string{"title", "description", "release_date", "rating", "actor_name", "actor_gender", "actor_birth_date"}
+
+var ActorsCSVHeader = []string{"name", "gender", "birth_date"}
+
+// ItemWriter пишет элементы выгрузки по одному, не накапливая их в памяти.
+type ItemWriter interface {
+	Write(item interface{}) error
+	Close() error
+}
+
+// NewWriter создает ItemWriter для формата format и сущности entity.
+func NewWriter(w io.Writer, format, entity string) (ItemWriter, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		switch entity {
+		case EntityFilms:
+			return &csvWriter{writer: csv.NewWriter(w), header: FilmsCSVHeader, rows: filmRows}, nil
+		case EntityActors:
+			return &csvWriter{writer: csv.NewWriter(w), header: ActorsCSVHeader, rows: actorRows}, nil
+		}
+		return nil, fmt.Errorf("unknown export entity %q: it must be films or actors", entity)
+	default:
+		return nil, fmt.Errorf("unknown export format %q: it must be json, ndjson or csv", format)
+	}
+}
+
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (jw *jsonWriter) Write(item interface{}) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	prefix := ","
+	if jw.count == 0 {
+		prefix = "["
+	}
+	jw.count++
+	_, err = io.WriteString(jw.w, prefix)
+	if err != nil {
+		return err
+	}
+	_, err = jw.w.Write(data)
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	end := "]"
+	if jw.count == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(jw.w, end)
+	return err
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(item interface{}) error {
+	return nw.encoder.Encode(item)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer      *csv.Writer
+	header      []string
+	rows        func(item interface{}) [][]string
+	wroteHeader bool
+}
+
+func (cw *csvWriter) Write(item interface{}) error {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if err := cw.writer.Write(cw.header); err != nil {
+			return err
+		}
+	}
+	// WriteAll сразу сбрасывает буфер csv.Writer, иначе чанки ответа уходили бы с задержкой
+	return cw.writer.WriteAll(cw.rows(item))
+}
+
+func (cw *csvWriter) Close() error {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if err := cw.writer.Write(cw.header); err != nil {
+			return err
+		}
+	}
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+// filmRows разворачивает фильм в строки CSV: по одной на каждого актера
+// или одну строку с пустыми колонками актера, если актеров нет.
+func filmRows(item interface{}) [][]string {
+	f := item.(*film.Film)
+	base := []string{f.Title, f.Description, f.ReleaseDate, strconv.Itoa(f.Rating)}
+	if len(f.Actors) == 0 {
+		return [][]string{append(base, "", "", "")}
+	}
+
+	rows := make([][]string, 0, len(f.Actors))
+	for _, a := range f.Actors {
+		row := append(append([]string{}, base...), a.Name, a.Gender, a.BirthDate)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func actorRows(item interface{}) [][]string {
+	a := item.(*actor.Actor)
+	return [][]string{{a.Name, a.Gender, a.BirthDate}}
+}
@@ -0,0 +1,127 @@
+package export
+
+import (
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// flushEvery - через сколько элементов ответ отправляется клиенту очередным чанком.
+const flushEvery = 100
+
+type FilmSource interface {
+	EachFilm(sortCol string, fn func(film *film.Film) error) error
+}
+
+type ActorSource interface {
+	EachActor(fn func(actor *actor.Actor) error) error
+}
+
+type ExportHandler struct {
+	FilmRepo  FilmSource
+	ActorRepo ActorSource
+}
+
+// @Summary Выгружает каталог
+// @Description Потоково (chunked) выгружает фильмы вместе с актерами или отдельно актеров в формате NDJSON, JSON или CSV. CSV фильмов совпадает с форматом импорта: одна строка на каждого актера фильма.
+// @Produce json
+// @Produce plain
+// @Param entity query string false "Что выгружать (films, actors), по умолчанию films"
+// @Param format query string false "Формат (ndjson, json, csv), по умолчанию ndjson"
+// @Param sort query string false "Столбец для сортировки фильмов (title, release_date, rating)"
+// @Success 200 {array} film.Film "Фильмы или актеры"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /user/film/export [get]
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	entity := query.Get("entity")
+	if entity == "" {
+		entity = EntityFilms
+	}
+	if entity != EntityFilms && entity != EntityActors {
+		log.Println("error exporting catalog: wrong entity")
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong entity: it must be empty, films or actors")
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = FormatNDJSON
+	}
+
+	sortCol := query.Get("sort")
+	if sortCol == "" {
+		sortCol = "title"
+	}
+	if !film.IsSortColumn(sortCol) {
+		log.Println("error exporting catalog: wrong sort column")
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong sort column: it must be empty, title, release_date or rating")
+		return
+	}
+
+	fw := &flushWriter{w: w}
+	writer, err := NewWriter(fw, format, entity)
+	if err != nil {
+		log.Println("error exporting catalog:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", entity, format))
+
+	count := 0
+	write := func(item interface{}) error {
+		err := writer.Write(item)
+		if err != nil {
+			return err
+		}
+		count++
+		if count%flushEvery == 0 {
+			fw.Flush()
+		}
+		return nil
+	}
+
+	if entity == EntityFilms {
+		err = h.FilmRepo.EachFilm(sortCol, func(f *film.Film) error { return write(f) })
+	} else {
+		err = h.ActorRepo.EachActor(func(a *actor.Actor) error { return write(a) })
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// часть ответа уже отправлена, поменять статус нельзя: клиент увидит оборванный файл
+		log.Println("error exporting catalog:", err)
+		if !fw.written {
+			pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't export catalog")
+		}
+		return
+	}
+
+	fw.Flush()
+	log.Println("catalog exported:", entity, format, count)
+}
+
+// flushWriter запоминает, начал ли уже отправляться ответ, и умеет сбрасывать его клиенту.
+type flushWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	fw.written = true
+	return fw.w.Write(p)
+}
+
+func (fw *flushWriter) Flush() {
+	if flusher, ok := fw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -1,11 +1,12 @@
 package film
 
 import (
+	"encoding/json"
 	"filmoteka/internal/actor"
 	"filmoteka/pkg"
 	"fmt"
-	"log"
-	"net/http"
+	"reflect"
+	"time"
 )
 
 type Film struct {
@@ -16,28 +17,86 @@ type Film struct {
 	Actors      []actor.Actor `json:"actors,omitempty"`
 }
 
+const (
+	RevisionInitial  = "initial"
+	RevisionUpdate   = "update"
+	RevisionRollback = "rollback"
+	RevisionDelete   = "delete"
+)
+
+// Revision - пронумерованный снимок фильма вместе с актерами. Ревизии создаются
+// при добавлении и каждом изменении фильма и никогда не переписываются. После
+// удаления фильма его история остается, последняя ревизия - delete.
+type Revision struct {
+	Revision int       `json:"revision"`
+	Action   string    `json:"action"`
+	UserID   *uint32   `json:"user_id,omitempty"`
+	Film     Film      `json:"film"`
+	Time     time.Time `json:"time"`
+}
+
+type RevisionsDiff struct {
+	From int             `json:"from"`
+	To   int             `json:"to"`
+	Diff json.RawMessage `json:"diff" swaggertype:"object"`
+}
+
 type ActorListWithFilms struct {
 	ActorInfo actor.Actor `json:"actor"`
 	Films     []Film      `json:"films"`
 }
 
-func (film *Film) Validate(w http.ResponseWriter) error {
-	err := pkg.DateValidation(film.ReleaseDate)
-	if err != nil {
-		log.Println("wrong film release date format: ", err)
-		http.Error(w, fmt.Sprintf("wrong film release date format"), http.StatusBadRequest)
-		return err
-	}
+var (
+	ErrActorInFilm    = pkg.NewError(pkg.ErrConflict, "actor is already in the film")
+	ErrActorNotInFilm = pkg.NewError(pkg.ErrNotFound, "actor is not in the film")
+	ErrNoFilms        = pkg.NewError(pkg.ErrNotFound, "no films found")
+)
+
+// ReferencedError возвращается при удалении фильма, в котором еще указаны актеры.
+// Такой фильм можно удалить только вместе со связями (cascade).
+type ReferencedError struct {
+	Actors []actor.Actor `json:"actors"`
+}
 
-	for _, actor := range film.Actors {
-		err = pkg.DateValidation(actor.BirthDate)
-		if err != nil {
-			log.Println("wrong actor birth date format: %w", err)
-			http.Error(w, fmt.Sprintf("wrong actor birth date format"), http.StatusBadRequest)
-			return err
+func (e *ReferencedError) Error() string {
+	return fmt.Sprintf("film is referenced by %d actors", len(e.Actors))
+}
+
+func (e *ReferencedError) Is(target error) bool {
+	return target == pkg.ErrConflict
+}
+
+// FilmConflict - ответ на удаление фильма, в котором еще указаны актеры.
+type FilmConflict struct {
+	pkg.Problem
+	Actors []actor.Actor `json:"actors"`
+}
+
+var sortColumns = map[string]bool{
+	"title":        true,
+	"release_date": true,
+	"rating":       true,
+}
+
+// SameCast сообщает, совпадают ли списки актеров без учета порядка и формата
+// дат рождения.
+func SameCast(a, b []actor.Actor) bool {
+	set := func(actors []actor.Actor) map[actor.Actor]bool {
+		result := make(map[actor.Actor]bool, len(actors))
+		for _, a := range actors {
+			if date, err := pkg.ParseDate(a.BirthDate); err == nil {
+				a.BirthDate = date.String()
+			}
+			result[a] = true
 		}
+		return result
 	}
-	return nil
+	return reflect.DeepEqual(set(a), set(b))
+}
+
+// IsSortColumn сообщает, можно ли сортировать список фильмов по колонке col.
+func IsSortColumn(col string) bool {
+	return sortColumns[col]
 }
 
 func ConvertMapToActorsListWithFilms(data map[actor.Actor][]Film) []ActorListWithFilms {
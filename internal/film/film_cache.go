@@ -0,0 +1,165 @@
+package film
+
+import (
+	"context"
+	"filmoteka/internal/actor"
+	"filmoteka/pkg"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CatalogChannel - канал NOTIFY, в который триггеры каталога сообщают о любом
+// изменении фильмов, актеров и их связей.
+const CatalogChannel = "catalog_changed"
+
+// CachedStorage - Storage, который отдает списки фильмов, поиск и список актеров
+// с фильмами из кэша. Все эти списки зависят от всего каталога, поэтому каждое
+// значение хранится вместе с версией каталога, для которой оно получено, и
+// отдается, только пока CatalogVersion не изменилась. Изменения через
+// CachedStorage и уведомления из CatalogChannel дополнительно сбрасывают кэш
+// разом. Остальные методы передаются в Storage без изменений.
+type CachedStorage struct {
+	Storage
+	cache pkg.Cache
+}
+
+// cachedValue - значение в кэше и версия каталога, прочитанная до его получения.
+type cachedValue struct {
+	version int64
+	value   interface{}
+}
+
+func NewCachedStorage(storage Storage, cache pkg.Cache) *CachedStorage {
+	return &CachedStorage{
+		Storage: storage,
+		cache:   cache,
+	}
+}
+
+// Invalidate сбрасывает все закэшированные списки.
+func (s *CachedStorage) Invalidate() {
+	s.cache.Purge()
+}
+
+func (s *CachedStorage) GetAllFilms(sortCol string) ([]Film, error) {
+	value, err := s.load("films:"+sortCol, func() (interface{}, error) {
+		return s.Storage.GetAllFilms(sortCol)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]Film), nil
+}
+
+func (s *CachedStorage) FindFilms(toFind string) ([]Film, error) {
+	value, err := s.load("find:"+toFind, func() (interface{}, error) {
+		return s.Storage.FindFilms(toFind)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]Film), nil
+}
+
+func (s *CachedStorage) ActorsListWithFilms() (map[actor.Actor][]Film, error) {
+	value, err := s.load("actors", func() (interface{}, error) {
+		return s.Storage.ActorsListWithFilms()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(map[actor.Actor][]Film), nil
+}
+
+func (s *CachedStorage) Add(ctx context.Context, film *Film) error {
+	defer s.Invalidate()
+	return s.Storage.Add(ctx, film)
+}
+
+func (s *CachedStorage) Update(ctx context.Context, filmId int64, newFilm *Film) (*Film, int64, error) {
+	defer s.Invalidate()
+	return s.Storage.Update(ctx, filmId, newFilm)
+}
+
+func (s *CachedStorage) Patch(ctx context.Context, filmId int64, patched *Film) (*Film, int64, error) {
+	defer s.Invalidate()
+	return s.Storage.Patch(ctx, filmId, patched)
+}
+
+func (s *CachedStorage) AddActor(ctx context.Context, filmId int64, newActor *actor.Actor) (*Film, int64, error) {
+	defer s.Invalidate()
+	return s.Storage.AddActor(ctx, filmId, newActor)
+}
+
+func (s *CachedStorage) RemoveActor(ctx context.Context, filmId int64, oldActor *actor.Actor) (*Film, int64, error) {
+	defer s.Invalidate()
+	return s.Storage.RemoveActor(ctx, filmId, oldActor)
+}
+
+func (s *CachedStorage) Delete(ctx context.Context, filmId int64, cascade bool) error {
+	defer s.Invalidate()
+	return s.Storage.Delete(ctx, filmId, cascade)
+}
+
+func (s *CachedStorage) Rollback(ctx context.Context, filmId int64, revision int) error {
+	defer s.Invalidate()
+	return s.Storage.Rollback(ctx, filmId, revision)
+}
+
+// Listen сбрасывает кэш по уведомлениям из CatalogChannel, пока не отменен ctx.
+// Так значения, устаревшие после изменений другими экземплярами сервера,
+// импортом или через репозиторий актеров, не занимают место в кэше до проверки
+// версии. Уведомления, пришедшие во время разрыва соединения, теряются, поэтому
+// после переподключения кэш тоже сбрасывается.
+func (s *CachedStorage) Listen(ctx context.Context, dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("catalog listener:", err)
+		}
+	})
+	defer listener.Close()
+
+	err := listener.Listen(CatalogChannel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-listener.Notify:
+			// nil приходит после переподключения
+			s.Invalidate()
+		case <-time.After(time.Minute):
+			go listener.Ping()
+		}
+	}
+}
+
+// load возвращает значение key из кэша, если оно получено для текущей версии
+// каталога, или результат fn. Версия читается до fn, поэтому значение, которое
+// застало изменение во время запроса, помечается старой версией и при
+// следующем обращении запрашивается заново. Без версии кэш не используется.
+func (s *CachedStorage) load(key string, fn func() (interface{}, error)) (interface{}, error) {
+	version, _, err := s.Storage.CatalogVersion()
+	if err != nil {
+		log.Println("catalog cache: can't get catalog version:", err)
+		return fn()
+	}
+
+	if cached, ok := s.cache.Get(key); ok {
+		if entry := cached.(cachedValue); entry.version == version {
+			return entry.value, nil
+		}
+	}
+
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Set(key, cachedValue{version: version, value: value})
+	return value, nil
+}
@@ -1,36 +1,52 @@
 package film
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"filmoteka/internal/actor"
 	"filmoteka/pkg"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Storage interface {
-	Add(film *Film) error
+	Add(ctx context.Context, film *Film) error
 	GetFilmId(film *Film) (int64, error)
-	Update(filmId int64, newFilm *Film) error
-	Delete(filmId int64) error
+	Update(ctx context.Context, filmId int64, newFilm *Film) (*Film, int64, error)
+	GetFilm(filmId int64) (*Film, int64, error)
+	Patch(ctx context.Context, filmId int64, patched *Film) (*Film, int64, error)
+	AddActor(ctx context.Context, filmId int64, newActor *actor.Actor) (*Film, int64, error)
+	RemoveActor(ctx context.Context, filmId int64, oldActor *actor.Actor) (*Film, int64, error)
+	Delete(ctx context.Context, filmId int64, cascade bool) error
 	GetAllFilms(sortCol string) ([]Film, error)
 	FindFilms(toFind string) ([]Film, error)
 	ActorsListWithFilms() (map[actor.Actor][]Film, error)
+	Revisions(filmId int64) ([]Revision, error)
+	Revision(filmId int64, revision int) (*Revision, error)
+	Rollback(ctx context.Context, filmId int64, revision int) error
+	CatalogVersion() (int64, time.Time, error)
 }
 
 type FilmHandler struct {
-	FilmRepo Storage
+	FilmRepo  Storage
+	ActorRepo actor.Storage
 }
 
 // @Summary Добавляет фильм
-// @Description Добавляет новый фильм в базу данных на основе переданных данных.
+// @Description Добавляет новый фильм в базу данных на основе переданных данных. Фильм от пользователя без прав администратора сохраняется как заявка и попадает в каталог после модерации.
 // @Accept json
 // @Produce json
 // @Param film body Film true "Данные фильма"
 // @Success 201 {string} string "film added"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Success 202 {string} string "film submitted for moderation"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 409 {object} pkg.Problem "Film already exists"
+// @Failure 422 {object} pkg.Problem "Film violates data constraints"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /user/film/add [post]
 func (h *FilmHandler) AddFilm(w http.ResponseWriter, r *http.Request) {
 	var film Film
@@ -39,32 +55,20 @@ func (h *FilmHandler) AddFilm(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&film)
 	if err != nil {
 		log.Println("error decoding request JSON:", err)
-		http.Error(w, "can't decode request JSON", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
 		return
 	}
 
 	defer pkg.CloseBody(r)
 
-	err = pkg.DateValidation(film.ReleaseDate)
-	if err != nil {
-		log.Println("wrong film release date format: ", err)
-		http.Error(w, fmt.Sprintf("wrong film release date format"), http.StatusBadRequest)
+	if errs := pkg.Validate(&film); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
 		return
 	}
 
-	for _, actor := range film.Actors {
-		err = pkg.DateValidation(actor.BirthDate)
-		if err != nil {
-			log.Println("wrong actor birth date format: %w", err)
-			http.Error(w, fmt.Sprintf("wrong actor birth date format"), http.StatusBadRequest)
-			return
-		}
-	}
-
-	err = h.FilmRepo.Add(&film)
+	err = h.FilmRepo.Add(r.Context(), &film)
 	if err != nil {
-		log.Println("error adding film:", err)
-		http.Error(w, "can't add film: film already exists", http.StatusInternalServerError)
+		pkg.WriteError(w, r, err, "can't add film")
 		return
 	}
 
@@ -73,14 +77,55 @@ func (h *FilmHandler) AddFilm(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+// @Summary Возвращает фильм
+// @Description Возвращает фильм вместе с актерами по id или по названию и дате выхода. Версия фильма возвращается в заголовке ETag, ее можно передать в If-Match при изменении или в If-None-Match, чтобы получить 304, если фильм не изменился. Адрес фильма по id возвращается в заголовке Content-Location.
+// @Produce json
+// @Param id path int true "Id фильма из Content-Location"
+// @Param title query string false "Название фильма"
+// @Param release_date query string false "Дата выхода фильма"
+// @Param If-None-Match header string false "ETag фильма из предыдущего ответа"
+// @Success 200 {object} Film "Фильм"
+// @Success 304 {string} string "Not modified"
+// @Header 200 {string} ETag "Версия фильма"
+// @Header 200 {string} Content-Location "Адрес фильма по id"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film not found"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /user/film [get]
+// @Router /user/films/{id} [get]
+func (h *FilmHandler) GetFilm(w http.ResponseWriter, r *http.Request) {
+	filmId, ok := h.filmIdFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	film, version, err := h.FilmRepo.GetFilm(filmId)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get film")
+		return
+	}
+
+	if pkg.NotModified(w, r, pkg.ETag(version), time.Time{}) {
+		return
+	}
+
+	h.writeFilm(w, r, filmId, film, version)
+}
+
 // @Summary Обновляет информацию о фильме
-// @Description Обновляет информацию о фильме в базе данных на основе переданных данных.
+// @Description Полностью заменяет фильм, включая список актеров: актеры, которых нет в новой информации, убираются из фильма, а новые актеры добавляются в базу. Возвращает обновленный фильм. Если передан If-Match, фильм обновляется, только если его версия не изменилась, иначе возвращается 412.
 // @Accept json
 // @Produce json
 // @Param filmInfo body []Film true "Старая и новая информация о фильме"
-// @Success 200 {string} string "film updated"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Param If-Match header string false "ETag фильма"
+// @Success 200 {object} Film "Обновленный фильм"
+// @Header 200 {string} ETag "Новая версия фильма"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film not found"
+// @Failure 409 {object} pkg.Problem "Film already exists"
+// @Failure 412 {object} pkg.Problem "Film was modified"
+// @Failure 422 {object} pkg.Problem "Film violates data constraints"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /admin/film/update [put]
 func (h *FilmHandler) UpdateFilm(w http.ResponseWriter, r *http.Request) {
 	var filmInfo []Film
@@ -89,7 +134,12 @@ func (h *FilmHandler) UpdateFilm(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&filmInfo)
 	if err != nil {
 		log.Println("error decoding request JSON:", err)
-		http.Error(w, "can't decode request JSON", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
+		return
+	}
+	if len(filmInfo) != 2 {
+		log.Println("error updating film: expected old and new film, got", len(filmInfo))
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "request must contain old and new film")
 		return
 	}
 
@@ -98,78 +148,272 @@ func (h *FilmHandler) UpdateFilm(w http.ResponseWriter, r *http.Request) {
 
 	defer pkg.CloseBody(r)
 
-	err = pkg.DateValidation(newFilm.ReleaseDate)
+	if errs := pkg.Validate(&newFilm); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return
+	}
+
+	oldFilmId, err := h.FilmRepo.GetFilmId(&oldFilm)
 	if err != nil {
-		log.Println("wrong film release date format: ", err)
-		http.Error(w, fmt.Sprintf("wrong film release date format"), http.StatusBadRequest)
+		pkg.WriteError(w, r, err, "can't find film")
 		return
 	}
 
-	for _, actor := range newFilm.Actors {
-		err = pkg.DateValidation(actor.BirthDate)
+	updated, version, err := h.FilmRepo.Update(pkg.IfMatch(r), oldFilmId, &newFilm)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't update film")
+		return
+	}
+
+	h.writeFilm(w, r, oldFilmId, updated, version)
+}
+
+// @Summary Частично обновляет фильм
+// @Description Применяет к фильму, найденному по названию и дате выхода, JSON Merge Patch (RFC 7396) или JSON Patch (RFC 6902) в зависимости от Content-Type. Сохраняются только изменившиеся поля, актеры заменяются, только если изменился их список. Возвращает обновленный фильм. Без If-Match патч сохраняется, только если фильм не изменили после его чтения сервером, иначе возвращается 412.
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param title query string true "Название фильма"
+// @Param release_date query string true "Дата выхода фильма"
+// @Param patch body object true "Патч"
+// @Param If-Match header string false "ETag фильма"
+// @Success 200 {object} Film "Обновленный фильм"
+// @Header 200 {string} ETag "Новая версия фильма"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film not found"
+// @Failure 409 {object} pkg.Problem "Patch test failed"
+// @Failure 412 {object} pkg.Problem "Film was modified"
+// @Failure 415 {object} pkg.Problem "Unsupported patch type"
+// @Failure 422 {object} pkg.Problem "Film violates data constraints"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/film/patch [patch]
+func (h *FilmHandler) PatchFilm(w http.ResponseWriter, r *http.Request) {
+	filmId, ok := h.filmIdFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	current, version, err := h.FilmRepo.GetFilm(filmId)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get film")
+		return
+	}
+
+	var patched Film
+	if !pkg.DecodePatch(w, r, current, &patched) {
+		return
+	}
+
+	if errs := pkg.Validate(&patched); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return
+	}
+
+	ctx := pkg.IfMatch(r)
+	if r.Header.Get("If-Match") == "" {
+		// патч применен к прочитанной версии, поэтому она не должна измениться до записи
+		ctx = pkg.WithIfMatch(ctx, pkg.ETag(version))
+	}
+
+	updated, version, err := h.FilmRepo.Patch(ctx, filmId, &patched)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't update film")
+		return
+	}
+
+	h.writeFilm(w, r, filmId, updated, version)
+}
+
+// @Summary Добавляет актера в фильм
+// @Description Добавляет актера в фильм, найденный по названию и дате выхода, или по id фильма и актера в пути. Актер из тела запроса, которого еще нет в базе, создается. Возвращает обновленный фильм.
+// @Accept json
+// @Produce json
+// @Param id path int true "Id фильма из Content-Location"
+// @Param actorId path int true "Id актера из Content-Location"
+// @Param title query string false "Название фильма"
+// @Param release_date query string false "Дата выхода фильма"
+// @Param actor body actor.Actor false "Данные актера, если он не указан в пути"
+// @Param If-Match header string false "ETag фильма"
+// @Success 200 {object} Film "Обновленный фильм"
+// @Header 200 {string} ETag "Новая версия фильма"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film not found"
+// @Failure 404 {object} pkg.Problem "Actor not found"
+// @Failure 409 {object} pkg.Problem "Actor is already in the film"
+// @Failure 412 {object} pkg.Problem "Film was modified"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/film/actor [post]
+// @Router /admin/films/{id}/actors/{actorId} [post]
+func (h *FilmHandler) AddFilmActor(w http.ResponseWriter, r *http.Request) {
+	filmId, newActor, ok := h.filmActorFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	updated, version, err := h.FilmRepo.AddActor(pkg.IfMatch(r), filmId, newActor)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't add actor to film")
+		return
+	}
+
+	h.writeFilm(w, r, filmId, updated, version)
+}
+
+// @Summary Убирает актера из фильма
+// @Description Убирает актера из фильма, найденного по названию и дате выхода, или по id фильма и актера в пути. Сам актер остается в базе. Возвращает обновленный фильм.
+// @Accept json
+// @Produce json
+// @Param id path int true "Id фильма из Content-Location"
+// @Param actorId path int true "Id актера из Content-Location"
+// @Param title query string false "Название фильма"
+// @Param release_date query string false "Дата выхода фильма"
+// @Param actor body actor.Actor false "Данные актера, если он не указан в пути"
+// @Param If-Match header string false "ETag фильма"
+// @Success 200 {object} Film "Обновленный фильм"
+// @Header 200 {string} ETag "Новая версия фильма"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Actor is not in the film"
+// @Failure 412 {object} pkg.Problem "Film was modified"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/film/actor [delete]
+// @Router /admin/films/{id}/actors/{actorId} [delete]
+func (h *FilmHandler) RemoveFilmActor(w http.ResponseWriter, r *http.Request) {
+	filmId, oldActor, ok := h.filmActorFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	updated, version, err := h.FilmRepo.RemoveActor(pkg.IfMatch(r), filmId, oldActor)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't remove actor from film")
+		return
+	}
+
+	h.writeFilm(w, r, filmId, updated, version)
+}
+
+// filmActorFromRequest находит фильм и актера по id из пути
+// /admin/films/{id}/actors/{actorId} или фильм по параметрам запроса, а актера
+// в теле. При ошибке ответ уже записан в w.
+func (h *FilmHandler) filmActorFromRequest(w http.ResponseWriter, r *http.Request) (int64, *actor.Actor, bool) {
+	if pkg.PathValue(r, "actorId") != "" {
+		filmId, ok := pkg.PathID(w, r, "id")
+		if !ok {
+			return 0, nil, false
+		}
+		actorId, ok := pkg.PathID(w, r, "actorId")
+		if !ok {
+			return 0, nil, false
+		}
+		a, _, err := h.ActorRepo.Get(actorId)
 		if err != nil {
-			log.Println("wrong actor birth date format: %w", err)
-			http.Error(w, fmt.Sprintf("wrong actor birth date format"), http.StatusBadRequest)
-			return
+			pkg.WriteError(w, r, err, "can't get actor")
+			return 0, nil, false
 		}
+		return filmId, a, true
 	}
 
-	oldFilmId, err := h.FilmRepo.GetFilmId(&oldFilm)
+	filmId, ok := h.filmIdFromQuery(w, r)
+	if !ok {
+		return 0, nil, false
+	}
+
+	var a actor.Actor
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&a)
 	if err != nil {
-		log.Println("error getting film id:", err)
-		http.Error(w, "can't find film", http.StatusInternalServerError)
-		return
+		log.Println("error decoding request JSON:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
+		return 0, nil, false
+	}
+
+	defer pkg.CloseBody(r)
+
+	if errs := pkg.Validate(&a); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return 0, nil, false
 	}
 
-	err = h.FilmRepo.Update(oldFilmId, &newFilm)
+	return filmId, &a, true
+}
+
+// FilmPath возвращает адрес фильма по id. Он передается в заголовке
+// Content-Location ответов с фильмом, от него строятся адреса ревизий.
+func FilmPath(filmId int64) string {
+	return "/user/films/" + strconv.FormatInt(filmId, 10)
+}
+
+func (h *FilmHandler) writeFilm(w http.ResponseWriter, r *http.Request, filmId int64, film *Film, version int64) {
+	resp, err := json.Marshal(film)
 	if err != nil {
-		log.Println("error updating film:", err)
-		http.Error(w, "can't update film", http.StatusInternalServerError)
+		log.Println("error marshalling film:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal film")
 		return
 	}
 
-	w.Write([]byte("film updated"))
-	w.WriteHeader(http.StatusOK)
+	pkg.SetETag(w, version)
+	w.Header().Set("Content-Location", FilmPath(filmId))
+	pkg.WriteJSON(w, http.StatusOK, resp)
 }
 
 // @Summary Удаляет фильм
-// @Description Удаляет фильм из базы данных на основе переданных данных.
+// @Description Удаляет фильм из базы данных на основе переданных данных. Фильм, в котором указаны актеры, удаляется только с cascade=true вместе со связями, иначе возвращается 409 со списком актеров. Если передан If-Match, фильм удаляется, только если его версия не изменилась, иначе возвращается 412.
 // @Accept json
 // @Produce json
 // @Param film body Film true "Данные фильма"
+// @Param cascade query boolean false "Удалить фильм вместе со связями с актерами"
+// @Param If-Match header string false "ETag фильма"
 // @Success 200 {string} string "film deleted"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film not found"
+// @Failure 409 {object} FilmConflict "Film has actors"
+// @Failure 412 {object} pkg.Problem "Film was modified"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /admin/film/delete [delete]
 func (h *FilmHandler) DeleteFilm(w http.ResponseWriter, r *http.Request) {
 	var film Film
 
+	var cascade bool
+	var err error
+	if s := r.URL.Query().Get("cascade"); s != "" {
+		cascade, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Println("error deleting film: wrong cascade", err)
+			pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong cascade: it must be true or false")
+			return
+		}
+	}
+
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&film)
+	err = decoder.Decode(&film)
 	if err != nil {
 		log.Println("error decoding request JSON:", err)
-		http.Error(w, "can't decode request JSON", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
 		return
 	}
 
 	defer pkg.CloseBody(r)
 
-	err = film.Validate(w)
-	if err != nil {
+	if errs := pkg.ValidateFields(&film, "title", "release_date"); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
 		return
 	}
 
 	filmId, err := h.FilmRepo.GetFilmId(&film)
 	if err != nil {
-		log.Println("error getting film id:", err)
-		http.Error(w, "can't find film", http.StatusInternalServerError)
+		pkg.WriteError(w, r, err, "can't find film")
 		return
 	}
 
-	err = h.FilmRepo.Delete(filmId)
-	if err != nil {
+	err = h.FilmRepo.Delete(pkg.IfMatch(r), filmId, cascade)
+	var refErr *ReferencedError
+	if errors.As(err, &refErr) {
 		log.Println("error deleting film:", err)
-		http.Error(w, "can't delete film", http.StatusInternalServerError)
+		conflict := FilmConflict{Problem: *pkg.NewProblem(r, http.StatusConflict, refErr.Error()), Actors: refErr.Actors}
+		pkg.WriteProblemJSON(w, http.StatusConflict, conflict)
+		return
+	}
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't delete film")
 		return
 	}
 
@@ -178,12 +422,17 @@ func (h *FilmHandler) DeleteFilm(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Получает список всех фильмов
-// @Description Возвращает список всех фильмов из базы данных, с возможностью сортировки по указанному столбцу (по умолчанию сортировка по названию).
+// @Description Возвращает список всех фильмов из базы данных, с возможностью сортировки по указанному столбцу (по умолчанию сортировка по названию). Если каталог не изменился с предыдущего ответа, возвращается 304.
 // @Produce json
 // @Param sort query string false "Столбец для сортировки (title, release_date, rating)"
+// @Param If-None-Match header string false "ETag из предыдущего ответа"
+// @Param If-Modified-Since header string false "Last-Modified из предыдущего ответа"
 // @Success 200 {array} Film "Список фильмов"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Success 304 {string} string "Not modified"
+// @Header 200 {string} ETag "Версия каталога"
+// @Header 200 {string} Last-Modified "Время последнего изменения каталога"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /user/films [get]
 func (h *FilmHandler) GetAllFilms(w http.ResponseWriter, r *http.Request) {
 	sortCol := r.URL.Query().Get("sort")
@@ -191,23 +440,27 @@ func (h *FilmHandler) GetAllFilms(w http.ResponseWriter, r *http.Request) {
 	if sortCol == "" {
 		sortCol = "title"
 	}
-	if sortCol != "title" && sortCol != "release_date" && sortCol != "rating" {
+	if !IsSortColumn(sortCol) {
 		log.Println("error getting all films: wrong sort column")
-		http.Error(w, "wrong sort column: it must be empty, title, release_date or rating", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong sort column: it must be empty, title, release_date or rating")
+		return
+	}
+
+	if h.catalogNotModified(w, r) {
 		return
 	}
 
 	films, err := h.FilmRepo.GetAllFilms(sortCol)
 	if err != nil {
 		log.Println("error getting all films:", err)
-		http.Error(w, "can't get all films", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't get all films")
 		return
 	}
 
 	resp, err := json.Marshal(films)
 	if err != nil {
 		log.Println("error marshalling films:", err)
-		http.Error(w, "can't marshal films", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal films")
 		return
 	}
 
@@ -216,32 +469,41 @@ func (h *FilmHandler) GetAllFilms(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Находит фильмы по строке поиска
-// @Description Поиск фильмов в базе данных по указанной строке поиска.
+// @Description Поиск фильмов в базе данных по указанной строке поиска. Если каталог не изменился с предыдущего ответа, возвращается 304.
 // @Produce json
 // @Param find query string true "Строка поиска"
+// @Param If-None-Match header string false "ETag из предыдущего ответа"
+// @Param If-Modified-Since header string false "Last-Modified из предыдущего ответа"
 // @Success 200 {array} Film "Найденные фильмы"
-// @Failure 400 {string} string "Bad request"
-// @Failure 500 {string} string "Internal server error"
+// @Success 304 {string} string "Not modified"
+// @Header 200 {string} ETag "Версия каталога"
+// @Header 200 {string} Last-Modified "Время последнего изменения каталога"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "No films found"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /user/films/find [get]
 func (h *FilmHandler) FindFilms(w http.ResponseWriter, r *http.Request) {
 	toFind := r.URL.Query().Get("find")
 	if toFind == "" {
 		log.Println("error finding films: empty find string")
-		http.Error(w, "empty find string", http.StatusBadRequest)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "empty find string")
+		return
+	}
+
+	if h.catalogNotModified(w, r) {
 		return
 	}
 
 	films, err := h.FilmRepo.FindFilms(toFind)
 	if err != nil {
-		log.Println("error finding films:", err)
-		http.Error(w, "can't find films", http.StatusInternalServerError)
+		pkg.WriteError(w, r, err, "can't find films")
 		return
 	}
 
 	resp, err := json.Marshal(films)
 	if err != nil {
 		log.Println("error marshalling films:", err)
-		http.Error(w, "can't marshal films", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal films")
 		return
 	}
 
@@ -250,26 +512,49 @@ func (h *FilmHandler) FindFilms(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Получает список актеров с их фильмами
-// @Description Возвращает список всех актеров вместе с их фильмами из базы данных.
+// @Description Возвращает список всех актеров вместе с их фильмами из базы данных. Если каталог не изменился с предыдущего ответа, возвращается 304.
 // @Produce json
+// @Param If-None-Match header string false "ETag из предыдущего ответа"
+// @Param If-Modified-Since header string false "Last-Modified из предыдущего ответа"
 // @Success 200 {array} ActorListWithFilms "Список актеров с фильмами"
-// @Failure 500 {string} string "Internal server error"
+// @Success 304 {string} string "Not modified"
+// @Header 200 {string} ETag "Версия каталога"
+// @Header 200 {string} Last-Modified "Время последнего изменения каталога"
+// @Failure 500 {object} pkg.Problem "Internal server error"
 // @Router /user/actors [get]
 func (h *FilmHandler) ActorsListWithFilms(w http.ResponseWriter, r *http.Request) {
+	if h.catalogNotModified(w, r) {
+		return
+	}
+
 	actorsList, err := h.FilmRepo.ActorsListWithFilms()
 	if err != nil {
 		log.Println("error getting actors list with films:", err)
-		http.Error(w, "can't get actors list with films", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't get actors list with films")
 		return
 	}
 	actorsListWithFilms := ConvertMapToActorsListWithFilms(actorsList)
 	resp, err := json.Marshal(actorsListWithFilms)
 	if err != nil {
 		log.Println("error marshalling actors list with films:", err)
-		http.Error(w, "can't marshal actors list with films", http.StatusInternalServerError)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal actors list with films")
 		return
 	}
 
 	w.Write(resp)
 	w.WriteHeader(http.StatusOK)
 }
+
+// catalogNotModified проверяет условный запрос к спискам каталога по счетчику
+// изменений. Счетчик читается до самого списка, поэтому при изменении между
+// запросами ответ получит старый ETag и просто перепроверится в следующий раз.
+func (h *FilmHandler) catalogNotModified(w http.ResponseWriter, r *http.Request) bool {
+	version, modified, err := h.FilmRepo.CatalogVersion()
+	if err != nil {
+		// без версии ответ отдается целиком и без валидаторов
+		log.Println("error getting catalog version:", err)
+		w.Header().Set("Cache-Control", "no-cache")
+		return false
+	}
+	return pkg.NotModified(w, r, pkg.WeakETag(version), modified)
+}
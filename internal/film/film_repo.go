@@ -1,9 +1,15 @@
 package film
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"filmoteka/internal/actor"
+	"filmoteka/internal/audit"
+	"filmoteka/pkg"
 	"fmt"
+	"reflect"
+	"time"
 )
 
 type FilmRepository struct {
@@ -11,109 +17,454 @@ type FilmRepository struct {
 	db        *sql.DB
 }
 
+// NewFilmRepository создает репозиторий фильмов. Каскадное удаление актера через
+// actorRepo после этого записывается в историю фильмов.
 func NewFilmRepository(actorRepo *actor.ActorRepository, db *sql.DB) *FilmRepository {
+	actorRepo.SetFilmHistory(recordCastChange)
 	return &FilmRepository{
 		actorRepo: actorRepo,
 		db:        db,
 	}
 }
 
-func (repo *FilmRepository) Add(film *Film) error {
+// Add добавляет фильм вместе с актерами в одной транзакции: новые актеры
+// создаются, а при любой ошибке не сохраняется ничего.
+func (repo *FilmRepository) Add(ctx context.Context, film *Film) error {
 	op := "film_repo.Add"
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer tx.Rollback()
+
+	_, err = Insert(ctx, tx, repo.actorRepo, film)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	return nil
+}
 
-	row := repo.db.QueryRow("INSERT INTO film(title, description, release_date, rating) VALUES($1, $2, $3, $4) RETURNING id",
-		film.Title, film.Description, film.ReleaseDate, film.Rating)
+// Insert добавляет фильм вместе с актерами в транзакции tx и записывает его
+// первую ревизию и запись аудита. Возвращает id нового фильма.
+func Insert(ctx context.Context, tx *sql.Tx, actorRepo *actor.ActorRepository, film *Film) (int64, error) {
+	row := tx.QueryRow(`INSERT INTO film(title, description, release_date, release_date_precision, release_date_approx, rating)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING id`,
+		film.Title, film.Description, pkg.DBDate(film.ReleaseDate), pkg.DBPrecision(film.ReleaseDate), pkg.DBApproximate(film.ReleaseDate), film.Rating)
 
 	var filmId int64
 	err := row.Scan(&filmId)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, err
 	}
-	for _, actor := range film.Actors {
-		actorId, err := repo.actorRepo.GetActorId(&actor)
+
+	err = addCast(tx, actorRepo.WithTx(tx), filmId, film.Actors)
+	if err != nil {
+		return 0, err
+	}
+
+	err = RecordCreate(ctx, tx, filmId)
+	if err != nil {
+		return 0, err
+	}
+
+	return filmId, nil
+}
+
+// recordCastChange выполняет fn, меняющую состав фильмов filmIds в транзакции tx,
+// и записывает ревизию и аудит каждого из них.
+func recordCastChange(ctx context.Context, tx *sql.Tx, filmIds []int64, fn func() error) error {
+	befores := make([]*Film, len(filmIds))
+	for i, filmId := range filmIds {
+		before, err := GetFilmById(tx, filmId)
 		if err != nil {
-			repo.actorRepo.Add(&actor)
-			actorId, err = repo.actorRepo.GetActorId(&actor)
+			return err
 		}
-		_, err = repo.db.Exec("INSERT INTO film_actor(film_id, actor_id) VALUES($1, $2)", filmId, actorId)
+		befores[i] = before
+	}
+
+	err := fn()
+	if err != nil {
+		return err
+	}
+
+	for i, filmId := range filmIds {
+		after, err := GetFilmById(tx, filmId)
+		if err != nil {
+			return err
+		}
+		err = AddRevision(ctx, tx, filmId, RevisionUpdate, befores[i], after)
+		if err != nil {
+			return err
+		}
+		err = RecordAudit(ctx, tx, filmId, audit.ActionUpdate, befores[i], after)
 		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+			return err
 		}
 	}
-
 	return nil
 }
 
+// RecordCreate записывает первую ревизию и запись аудита фильма filmId, только
+// что добавленного в транзакции tx.
+func RecordCreate(ctx context.Context, tx *sql.Tx, filmId int64) error {
+	after, err := GetFilmById(tx, filmId)
+	if err != nil {
+		return err
+	}
+
+	err = AddRevision(ctx, tx, filmId, RevisionInitial, nil, after)
+	if err != nil {
+		return err
+	}
+
+	return RecordAudit(ctx, tx, filmId, audit.ActionCreate, nil, after)
+}
+
 func (repo *FilmRepository) GetFilmId(film *Film) (int64, error) {
 	op := "film_repo.GetByID"
-	row := repo.db.QueryRow("SELECT id FROM film where title = $1 and release_date = $2",
-		film.Title, film.ReleaseDate)
+	row := repo.db.QueryRow(`SELECT id FROM film
+		WHERE title = $1 AND release_date = $2 AND release_date_precision = $3 AND release_date_approx = $4`,
+		film.Title, pkg.DBDate(film.ReleaseDate), pkg.DBPrecision(film.ReleaseDate), pkg.DBApproximate(film.ReleaseDate))
 	var filmId int64
 	err := row.Scan(&filmId)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	return filmId, nil
 }
 
-func (repo *FilmRepository) Update(filmId int64, newFilm *Film) error {
+// Update полностью заменяет фильм, включая список актеров, и возвращает его новое
+// состояние и версию.
+func (repo *FilmRepository) Update(ctx context.Context, filmId int64, newFilm *Film) (*Film, int64, error) {
 	op := "film_repo.UpdateFilm"
-	_, err := repo.db.Query("UPDATE film SET title = $1, description = $2, release_date = $3, rating = $4 WHERE id = $5",
-		newFilm.Title, newFilm.Description, newFilm.ReleaseDate, newFilm.Rating, filmId)
+	after, version, err := repo.change(ctx, filmId, RevisionUpdate, func(tx *sql.Tx, before *Film) error {
+		_, err := tx.Exec(`UPDATE film SET title = $1, description = $2, release_date = $3, release_date_precision = $4, release_date_approx = $5, rating = $6
+		WHERE id = $7`,
+			newFilm.Title, newFilm.Description, pkg.DBDate(newFilm.ReleaseDate), pkg.DBPrecision(newFilm.ReleaseDate), pkg.DBApproximate(newFilm.ReleaseDate),
+			newFilm.Rating, filmId)
+		if err != nil {
+			return err
+		}
+		return ReplaceCast(tx, repo.actorRepo, filmId, newFilm.Actors)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return after, version, nil
+}
+
+// AddActor добавляет актера в фильм, создавая его в базе, если такого актера еще нет.
+// Если актер уже есть в фильме, возвращается ErrActorInFilm.
+func (repo *FilmRepository) AddActor(ctx context.Context, filmId int64, newActor *actor.Actor) (*Film, int64, error) {
+	op := "film_repo.AddActor"
+	after, version, err := repo.change(ctx, filmId, RevisionUpdate, func(tx *sql.Tx, before *Film) error {
+		actorId, err := repo.actorRepo.WithTx(tx).GetOrAdd(newActor)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec("INSERT INTO film_actor(film_id, actor_id) VALUES($1, $2) ON CONFLICT DO NOTHING", filmId, actorId)
+		if err != nil {
+			return err
+		}
+		return expectAffected(res, ErrActorInFilm)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return after, version, nil
+}
+
+// RemoveActor убирает актера из фильма, сам актер остается в базе. Если актера
+// нет в фильме, возвращается ErrActorNotInFilm.
+func (repo *FilmRepository) RemoveActor(ctx context.Context, filmId int64, oldActor *actor.Actor) (*Film, int64, error) {
+	op := "film_repo.RemoveActor"
+	after, version, err := repo.change(ctx, filmId, RevisionUpdate, func(tx *sql.Tx, before *Film) error {
+		actorId, err := repo.actorRepo.WithTx(tx).GetActorId(oldActor)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrActorNotInFilm
+		}
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec("DELETE FROM film_actor WHERE film_id = $1 AND actor_id = $2", filmId, actorId)
+		if err != nil {
+			return err
+		}
+		return expectAffected(res, ErrActorNotInFilm)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return after, version, nil
+}
+
+// Patch сохраняет фильм patched, полученный из текущего состояния: в UPDATE
+// попадают только изменившиеся колонки, а актеры заменяются, только если
+// изменился их список.
+func (repo *FilmRepository) Patch(ctx context.Context, filmId int64, patched *Film) (*Film, int64, error) {
+	op := "film_repo.Patch"
+	after, version, err := repo.change(ctx, filmId, RevisionUpdate, func(tx *sql.Tx, before *Film) error {
+		set := &pkg.Assignments{}
+		if patched.Title != before.Title {
+			set.Set("title", patched.Title)
+		}
+		if patched.Description != before.Description {
+			set.Set("description", patched.Description)
+		}
+		if !pkg.SameDate(patched.ReleaseDate, before.ReleaseDate) {
+			set.Set("release_date", pkg.DBDate(patched.ReleaseDate))
+			set.Set("release_date_precision", pkg.DBPrecision(patched.ReleaseDate))
+			set.Set("release_date_approx", pkg.DBApproximate(patched.ReleaseDate))
+		}
+		if patched.Rating != before.Rating {
+			set.Set("rating", patched.Rating)
+		}
+		if !set.Empty() {
+			query, args := set.Update("film", filmId)
+			_, err := tx.Exec(query, args...)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !SameCast(before.Actors, patched.Actors) {
+			return ReplaceCast(tx, repo.actorRepo, filmId, patched.Actors)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return after, version, nil
+}
+
+// GetFilm возвращает фильм вместе с актерами и его версию.
+func (repo *FilmRepository) GetFilm(filmId int64) (*Film, int64, error) {
+	op := "film_repo.GetFilm"
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer tx.Rollback()
+
+	film, err := GetFilmById(tx, filmId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	var version int64
+	err = tx.QueryRow("SELECT version FROM film WHERE id = $1", filmId).Scan(&version)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return film, version, nil
+}
+
+// CatalogVersion возвращает счетчик изменений каталога и время последнего
+// изменения. Счетчик увеличивается триггерами на любое изменение фильмов,
+// актеров и их связей.
+func (repo *FilmRepository) CatalogVersion() (int64, time.Time, error) {
+	op := "film_repo.CatalogVersion"
+	var version int64
+	var modified time.Time
+	err := repo.db.QueryRow("SELECT version, modified_at FROM catalog_version").Scan(&version, &modified)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return version, modified, nil
+}
+
+// change выполняет изменение фильма fn в транзакции, записывает ревизию и аудит
+// и возвращает новое состояние фильма и его версию. Версия проверяется по
+// условию If-Match из ctx. Если фильм не изменился, транзакция откатывается,
+// и ни ревизия, ни новая версия не появляются. action - RevisionUpdate или
+// RevisionRollback, им же помечается запись аудита.
+func (repo *FilmRepository) change(ctx context.Context, filmId int64, action string, fn func(tx *sql.Tx, before *Film) error) (*Film, int64, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	version, err := pkg.BumpVersion(ctx, tx, "film", filmId)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, 0, err
 	}
 
+	before, err := GetFilmById(tx, filmId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = fn(tx, before)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	after, err := GetFilmById(tx, filmId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if reflect.DeepEqual(before, after) {
+		return after, version - 1, nil
+	}
+
+	err = AddRevision(ctx, tx, filmId, action, before, after)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = RecordAudit(ctx, tx, filmId, action, before, after)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return after, version, nil
+}
+
+func expectAffected(res sql.Result, errNone error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errNone
+	}
 	return nil
 }
 
-func (repo *FilmRepository) Delete(filmId int64) error {
+// Delete удаляет фильм. Если в фильме указаны актеры, без cascade возвращается
+// *ReferencedError со списком актеров, а с cascade фильм удаляется вместе со связями.
+func (repo *FilmRepository) Delete(ctx context.Context, filmId int64, cascade bool) error {
 	op := "film_repo.DeleteFilm"
 	tx, err := repo.db.Begin()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
-	defer func() {
+	defer tx.Rollback()
+
+	_, err = pkg.BumpVersion(ctx, tx, "film", filmId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	before, err := GetFilmById(tx, filmId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	if len(before.Actors) > 0 && !cascade {
+		return fmt.Errorf("%s: %w", op, &ReferencedError{Actors: before.Actors})
+	}
+
+	// история фильма остается после удаления, последняя ревизия хранит его
+	// состояние на момент удаления
+	err = AddRevision(ctx, tx, filmId, RevisionDelete, before, before)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	if len(before.Actors) > 0 {
+		_, err = tx.Exec("DELETE FROM film_actor WHERE film_id = $1", filmId)
 		if err != nil {
-			tx.Rollback()
-			return
+			return fmt.Errorf("%s: %w", op, pkg.DBError(err))
 		}
-		err = tx.Commit()
-	}()
+	}
+	_, err = tx.Exec("DELETE FROM film WHERE id = $1", filmId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
 
-	_, err = tx.Exec("DELETE FROM film_actor WHERE film_id = $1", filmId)
+	err = RecordAudit(ctx, tx, filmId, audit.ActionDelete, before, nil)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
-	_, err = tx.Exec("DELETE FROM film WHERE id = $1", filmId)
+
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 
 	return nil
 }
 
+// GetFilmById читает фильм вместе с актерами внутри транзакции, чтобы снимок
+// состояния для аудита был согласован с самим изменением. Строка фильма
+// блокируется до конца транзакции, чтобы параллельные изменения не получили
+// одинаковый номер ревизии.
+func GetFilmById(tx *sql.Tx, filmId int64) (*Film, error) {
+	op := "film_repo.GetFilmById"
+
+	var film Film
+	row := tx.QueryRow("SELECT title, description, partial_date(release_date, release_date_precision, release_date_approx), rating FROM film WHERE id = $1 FOR UPDATE", filmId)
+	err := row.Scan(&film.Title, &film.Description, pkg.ScanDate(&film.ReleaseDate), &film.Rating)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	rows, err := tx.Query(`
+    SELECT a.name, a.gender, partial_date(a.birth_date, a.birth_date_precision, a.birth_date_approx)
+    FROM actor a
+    JOIN film_actor fa ON fa.actor_id = a.id
+    WHERE fa.film_id = $1
+    ORDER BY a.name`, filmId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var actor actor.Actor
+		err := rows.Scan(&actor.Name, &actor.Gender, pkg.ScanDate(&actor.BirthDate))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+		film.Actors = append(film.Actors, actor)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	return &film, nil
+}
+
+// RecordAudit пишет запись аудита об изменении фильма в транзакцию tx.
+func RecordAudit(ctx context.Context, tx *sql.Tx, filmId int64, action string, before, after *Film) error {
+	entry, err := audit.NewEntry(ctx, audit.EntityFilm, filmId, action, before, after)
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, entry)
+}
+
 func (repo *FilmRepository) GetAllFilms(sortCol string) ([]Film, error) {
 	op := "film_repo.GetAllFilms"
 
-	var validCols = map[string]bool{
-		"title":        true,
-		"release_date": true,
-		"rating":       true,
-	}
-	if !validCols[sortCol] {
+	if !IsSortColumn(sortCol) {
 		return nil, fmt.Errorf("%s: invalid column name: %s", op, sortCol)
 	}
 
-	stmt, err := repo.db.Prepare(fmt.Sprintf("SELECT title, description, release_date, rating FROM film ORDER BY %s", sortCol))
+	stmt, err := repo.db.Prepare(fmt.Sprintf("SELECT title, description, partial_date(release_date, release_date_precision, release_date_approx), rating FROM film ORDER BY %s", sortCol))
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	defer stmt.Close()
 
 	rows, err := stmt.Query()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 
 	defer rows.Close()
@@ -121,36 +472,94 @@ func (repo *FilmRepository) GetAllFilms(sortCol string) ([]Film, error) {
 	var films []Film
 	for rows.Next() {
 		var film Film
-		err := rows.Scan(&film.Title, &film.Description, &film.ReleaseDate, &film.Rating)
+		err := rows.Scan(&film.Title, &film.Description, pkg.ScanDate(&film.ReleaseDate), &film.Rating)
 		film.Actors = nil
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 		}
 		films = append(films, film)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	return films, nil
 }
 
+// EachFilm по очереди передает в fn все фильмы вместе с актерами, не загружая
+// весь каталог в память. Фильмы отсортированы по sortCol, актеры - по имени.
+func (repo *FilmRepository) EachFilm(sortCol string, fn func(film *Film) error) error {
+	op := "film_repo.EachFilm"
+
+	if !IsSortColumn(sortCol) {
+		return fmt.Errorf("%s: invalid column name: %s", op, sortCol)
+	}
+
+	rows, err := repo.db.Query(fmt.Sprintf(`
+    SELECT f.id, f.title, f.description, partial_date(f.release_date, f.release_date_precision, f.release_date_approx), f.rating,
+        a.name, a.gender, partial_date(a.birth_date, a.birth_date_precision, a.birth_date_approx)
+    FROM film f
+    LEFT JOIN film_actor fa ON fa.film_id = f.id
+    LEFT JOIN actor a ON a.id = fa.actor_id
+    ORDER BY f.%s, f.id, a.name`, sortCol))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	var current *Film
+	var currentId int64
+	for rows.Next() {
+		var filmId int64
+		var film Film
+		var name, gender sql.NullString
+		var birthDate string
+		err := rows.Scan(&filmId, &film.Title, &film.Description, pkg.ScanDate(&film.ReleaseDate), &film.Rating, &name, &gender, pkg.ScanDate(&birthDate))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+
+		if current == nil || filmId != currentId {
+			if current != nil {
+				if err := fn(current); err != nil {
+					return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+				}
+			}
+			current, currentId = &film, filmId
+		}
+		if name.Valid {
+			current.Actors = append(current.Actors, actor.Actor{Name: name.String, Gender: gender.String, BirthDate: birthDate})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	if current != nil {
+		if err := fn(current); err != nil {
+			return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+	}
+	return nil
+}
+
 func (repo *FilmRepository) FindFilms(toFind string) ([]Film, error) {
 	op := "film_repo.FindFilm"
 
 	var films []Film
 	var err error
 	if films, err = repo.FindFilmsByTitle(toFind); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	if len(films) == 0 {
 		if films, err = repo.FindFilmsByActor(toFind); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 		}
 	}
 
 	if len(films) == 0 {
-		return nil, fmt.Errorf("%s: %w", op, fmt.Errorf("no films found"))
+		return nil, fmt.Errorf("%s: %w", op, ErrNoFilms)
 	}
 
 	return films, nil
@@ -159,9 +568,9 @@ func (repo *FilmRepository) FindFilms(toFind string) ([]Film, error) {
 func (repo FilmRepository) ActorsListWithFilms() (map[actor.Actor][]Film, error) {
 	op := "film_repo.ActorListWithFilms"
 
-	rows, err := repo.db.Query(`SELECT distinct name, gender, birth_date from actor`)
+	rows, err := repo.db.Query(`SELECT distinct name, gender, partial_date(birth_date, birth_date_precision, birth_date_approx) from actor`)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	defer rows.Close()
 
@@ -169,13 +578,13 @@ func (repo FilmRepository) ActorsListWithFilms() (map[actor.Actor][]Film, error)
 
 	for rows.Next() {
 		var actor actor.Actor
-		err := rows.Scan(&actor.Name, &actor.Gender, &actor.BirthDate)
+		err := rows.Scan(&actor.Name, &actor.Gender, pkg.ScanDate(&actor.BirthDate))
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 		}
 		actorFilms, err := repo.FindFilmsByActor(actor.Name)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 		}
 		actorsWithFilms[actor] = actorFilms
 	}
@@ -185,29 +594,27 @@ func (repo FilmRepository) ActorsListWithFilms() (map[actor.Actor][]Film, error)
 func (repo *FilmRepository) FindFilmsByTitle(titleFragment string) ([]Film, error) {
 	op := "film_repo.FindFilmsByTitleFragment"
 
-	query := fmt.Sprintf(`
-        SELECT f.title, f.description, f.release_date, f.rating
+	rows, err := repo.db.Query(`
+        SELECT f.title, f.description, partial_date(f.release_date, f.release_date_precision, f.release_date_approx), f.rating
         FROM film f
-        WHERE f.title LIKE '%%%s%%'`, titleFragment)
-
-	rows, err := repo.db.Query(query)
+        WHERE f.title LIKE '%' || $1 || '%' ESCAPE '\'`, pkg.EscapeLike(titleFragment))
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	defer rows.Close()
 
 	var films []Film
 	for rows.Next() {
 		var film Film
-		err := rows.Scan(&film.Title, &film.Description, &film.ReleaseDate, &film.Rating)
+		err := rows.Scan(&film.Title, &film.Description, pkg.ScanDate(&film.ReleaseDate), &film.Rating)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 		}
 		films = append(films, film)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 
 	return films, nil
@@ -216,8 +623,8 @@ func (repo *FilmRepository) FindFilmsByTitle(titleFragment string) ([]Film, erro
 func (repo *FilmRepository) FindFilmsByActor(actorName string) ([]Film, error) {
 	op := "film_repo.FindFilmsByActor"
 
-	query := fmt.Sprintf(`
-    SELECT f.title, f.description, f.release_date, f.rating
+	rows, err := repo.db.Query(`
+    SELECT f.title, f.description, partial_date(f.release_date, f.release_date_precision, f.release_date_approx), f.rating
     FROM film f
     WHERE f.id IN (
         SELECT film_id 
@@ -225,28 +632,26 @@ func (repo *FilmRepository) FindFilmsByActor(actorName string) ([]Film, error) {
         WHERE actor_id IN (
             SELECT id 
             FROM actor 
-            WHERE name LIKE '%%%s%%'
+            WHERE name LIKE '%' || $1 || '%' ESCAPE '\'
         )
-    )`, actorName)
-
-	rows, err := repo.db.Query(query)
+    )`, pkg.EscapeLike(actorName))
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 	defer rows.Close()
 
 	var films []Film
 	for rows.Next() {
 		var film Film
-		err := rows.Scan(&film.Title, &film.Description, &film.ReleaseDate, &film.Rating)
+		err := rows.Scan(&film.Title, &film.Description, pkg.ScanDate(&film.ReleaseDate), &film.Rating)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 		}
 		films = append(films, film)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
 	}
 
 	return films, nil
@@ -0,0 +1,204 @@
+package film
+
+import (
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/audit"
+	"filmoteka/pkg"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// @Summary Получает историю изменений фильма
+// @Description Возвращает все ревизии фильма, найденного по id или по названию и дате выхода, начиная с первой. По id доступна и история удаленного фильма, его последняя ревизия - delete.
+// @Produce json
+// @Param id path int true "Id фильма из Content-Location"
+// @Param title query string false "Название фильма"
+// @Param release_date query string false "Дата выхода фильма"
+// @Success 200 {array} Revision "Ревизии фильма"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film not found"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /user/film/revisions [get]
+// @Router /user/films/{id}/revisions [get]
+func (h *FilmHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	filmId, ok := h.filmIdFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.FilmRepo.Revisions(filmId)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get film revisions")
+		return
+	}
+
+	resp, err := json.Marshal(revisions)
+	if err != nil {
+		log.Println("error marshalling film revisions:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal film revisions")
+		return
+	}
+
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary Сравнивает две ревизии фильма
+// @Description Возвращает поля фильма, которые отличаются между ревизиями from и to.
+// @Produce json
+// @Param id path int true "Id фильма из Content-Location"
+// @Param title query string false "Название фильма"
+// @Param release_date query string false "Дата выхода фильма"
+// @Param from query int true "Номер первой ревизии"
+// @Param to query int true "Номер второй ревизии"
+// @Success 200 {object} RevisionsDiff "Различия между ревизиями"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film or revision not found"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /user/film/revisionsDiff [get]
+// @Router /user/films/{id}/revisions/diff [get]
+func (h *FilmHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	from, err := revisionFromQuery(r, "from")
+	if err != nil {
+		log.Println("error diffing film revisions:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := revisionFromQuery(r, "to")
+	if err != nil {
+		log.Println("error diffing film revisions:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filmId, ok := h.filmIdFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	fromRevision, err := h.FilmRepo.Revision(filmId, from)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get film revision")
+		return
+	}
+	toRevision, err := h.FilmRepo.Revision(filmId, to)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get film revision")
+		return
+	}
+
+	fromData, err := json.Marshal(fromRevision.Film)
+	if err != nil {
+		log.Println("error marshalling film revision:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal film revision")
+		return
+	}
+	toData, err := json.Marshal(toRevision.Film)
+	if err != nil {
+		log.Println("error marshalling film revision:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal film revision")
+		return
+	}
+
+	diff, err := audit.Diff(fromData, toData)
+	if err != nil {
+		log.Println("error diffing film revisions:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't diff film revisions")
+		return
+	}
+
+	resp, err := json.Marshal(RevisionsDiff{From: from, To: to, Diff: diff})
+	if err != nil {
+		log.Println("error marshalling film revisions diff:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal film revisions diff")
+		return
+	}
+
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
+
+// @Summary Откатывает фильм к ревизии
+// @Description Возвращает поля и актеров фильма к состоянию указанной ревизии. Откат сохраняется как новая ревизия. Если передан If-Match, откат выполняется, только если версия фильма не изменилась, иначе возвращается 412.
+// @Produce json
+// @Param id path int true "Id фильма из Content-Location"
+// @Param title query string false "Название фильма"
+// @Param release_date query string false "Дата выхода фильма"
+// @Param revision query int true "Номер ревизии"
+// @Param If-Match header string false "ETag фильма"
+// @Success 200 {string} string "film rolled back"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Film or revision not found"
+// @Failure 412 {object} pkg.Problem "Film was modified"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/film/rollback [put]
+// @Router /admin/films/{id}/rollback [put]
+func (h *FilmHandler) RollbackFilm(w http.ResponseWriter, r *http.Request) {
+	revision, err := revisionFromQuery(r, "revision")
+	if err != nil {
+		log.Println("error rolling back film:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filmId, ok := h.filmIdFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	_, err = h.FilmRepo.Revision(filmId, revision)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get film revision")
+		return
+	}
+
+	err = h.FilmRepo.Rollback(pkg.IfMatch(r), filmId, revision)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't roll back film")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("film rolled back"))
+}
+
+// filmIdFromRequest возвращает id фильма из пути /user/films/{id}/... или
+// находит фильм по параметрам title и release_date. Id из пути не проверяется
+// на существование фильма: ревизии удаленного фильма тоже доступны по нему.
+// При ошибке ответ уже записан в w.
+func (h *FilmHandler) filmIdFromRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if pkg.PathValue(r, "id") != "" {
+		return pkg.PathID(w, r, "id")
+	}
+	return h.filmIdFromQuery(w, r)
+}
+
+// filmIdFromQuery находит фильм по параметрам title и release_date. При ошибке
+// ответ уже записан в w.
+func (h *FilmHandler) filmIdFromQuery(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	film := Film{
+		Title:       r.URL.Query().Get("title"),
+		ReleaseDate: r.URL.Query().Get("release_date"),
+	}
+
+	if errs := pkg.ValidateFields(&film, "title", "release_date"); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return 0, false
+	}
+
+	filmId, err := h.FilmRepo.GetFilmId(&film)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't find film")
+		return 0, false
+	}
+
+	return filmId, true
+}
+
+func revisionFromQuery(r *http.Request, name string) (int, error) {
+	revision, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || revision <= 0 {
+		return 0, errors.New("wrong " + name + ": it must be a positive revision number")
+	}
+	return revision, nil
+}
@@ -0,0 +1,180 @@
+package film
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/auth"
+	"filmoteka/pkg"
+	"fmt"
+)
+
+func (repo *FilmRepository) Revisions(filmId int64) ([]Revision, error) {
+	op := "film_repo.Revisions"
+
+	rows, err := repo.db.Query("SELECT revision, action, user_id, data, created_at FROM film_revision WHERE film_id = $1 ORDER BY revision", filmId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	// У фильмов, созданных до появления ревизий, список пуст, поэтому пустой
+	// результат отличаем от несуществующего фильма.
+	if len(revisions) == 0 {
+		var id int64
+		err := repo.db.QueryRow("SELECT id FROM film WHERE id = $1", filmId).Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+		return []Revision{}, nil
+	}
+
+	return revisions, nil
+}
+
+func (repo *FilmRepository) Revision(filmId int64, revision int) (*Revision, error) {
+	op := "film_repo.Revision"
+
+	row := repo.db.QueryRow("SELECT revision, action, user_id, data, created_at FROM film_revision WHERE film_id = $1 AND revision = $2",
+		filmId, revision)
+	rev, err := scanRevision(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return rev, nil
+}
+
+// Rollback возвращает фильм (поля и актеров) к состоянию указанной ревизии.
+// Сам откат тоже становится новой ревизией, поэтому история не теряется. Откат
+// к состоянию, совпадающему с текущим, ничего не меняет.
+func (repo *FilmRepository) Rollback(ctx context.Context, filmId int64, revision int) error {
+	op := "film_repo.Rollback"
+	_, _, err := repo.change(ctx, filmId, RevisionRollback, func(tx *sql.Tx, before *Film) error {
+		row := tx.QueryRow("SELECT revision, action, user_id, data, created_at FROM film_revision WHERE film_id = $1 AND revision = $2",
+			filmId, revision)
+		target, err := scanRevision(row)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE film SET title = $1, description = $2, release_date = $3, release_date_precision = $4, release_date_approx = $5, rating = $6
+		WHERE id = $7`,
+			target.Film.Title, target.Film.Description, pkg.DBDate(target.Film.ReleaseDate), pkg.DBPrecision(target.Film.ReleaseDate),
+			pkg.DBApproximate(target.Film.ReleaseDate), target.Film.Rating, filmId)
+		if err != nil {
+			return err
+		}
+		return ReplaceCast(tx, repo.actorRepo, filmId, target.Film.Actors)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return nil
+}
+
+// AddRevision сохраняет состояние after очередной ревизией. Первая ревизия
+// пишется при добавлении фильма (before равен nil). У фильмов, добавленных до
+// появления ревизий, истории нет: для них сначала первой ревизией записывается
+// состояние before.
+func AddRevision(ctx context.Context, tx *sql.Tx, filmId int64, action string, before, after *Film) error {
+	op := "film_repo.AddRevision"
+
+	var last int
+	err := tx.QueryRow("SELECT COALESCE(MAX(revision), 0) FROM film_revision WHERE film_id = $1", filmId).Scan(&last)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	if last == 0 && before != nil {
+		last++
+		err = insertRevision(tx, filmId, last, RevisionInitial, nil, before)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+		}
+	}
+
+	var userId *uint32
+	if sess, err := auth.SessionFromContext(ctx); err == nil {
+		userId = &sess.UserID
+	}
+
+	err = insertRevision(tx, filmId, last+1, action, userId, after)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	return nil
+}
+
+func insertRevision(tx *sql.Tx, filmId int64, revision int, action string, userId *uint32, film *Film) error {
+	data, err := json.Marshal(film)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO film_revision(film_id, revision, action, user_id, data) VALUES($1, $2, $3, $4, $5)",
+		filmId, revision, action, userId, string(data))
+	return err
+}
+
+// ReplaceCast заменяет список актеров фильма, добавляя в базу актеров, которых в ней еще нет.
+func ReplaceCast(tx *sql.Tx, actorRepo *actor.ActorRepository, filmId int64, actors []actor.Actor) error {
+	_, err := tx.Exec("DELETE FROM film_actor WHERE film_id = $1", filmId)
+	if err != nil {
+		return err
+	}
+	return addCast(tx, actorRepo.WithTx(tx), filmId, actors)
+}
+
+// addCast связывает фильм с актерами. actorRepo должен работать в транзакции tx.
+func addCast(tx *sql.Tx, actorRepo *actor.ActorRepository, filmId int64, actors []actor.Actor) error {
+	for _, actor := range actors {
+		actorId, err := actorRepo.GetOrAdd(&actor)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO film_actor(film_id, actor_id) VALUES($1, $2)", filmId, actorId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRevision(row scanner) (*Revision, error) {
+	var revision Revision
+	var userId sql.NullInt64
+	var data []byte
+	err := row.Scan(&revision.Revision, &revision.Action, &userId, &data, &revision.Time)
+	if err != nil {
+		return nil, err
+	}
+	if userId.Valid {
+		id := uint32(userId.Int64)
+		revision.UserID = &id
+	}
+	err = json.Unmarshal(data, &revision.Film)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
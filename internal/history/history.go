@@ -0,0 +1,252 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"filmoteka/pkg"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SourceLetterboxd = "letterboxd"
+	SourceTrakt      = "trakt"
+
+	StatusMatched   = "matched"
+	StatusCreated   = "created"
+	StatusUnmatched = "unmatched"
+	StatusError     = "error"
+)
+
+var (
+	ErrNoFilm    = pkg.NewError(pkg.ErrNotFound, "film not found")
+	ErrAmbiguous = errors.New("several films match title and year")
+	// ErrNoDescription - фильм нельзя создать: описание в каталоге обязательно,
+	// а в выгрузке его нет (в Letterboxd его нет никогда).
+	ErrNoDescription = errors.New("no film description in export")
+)
+
+// Entry - оценка и/или просмотр фильма из файла экспорта. Row - номер строки CSV
+// или номер элемента JSON-массива, начиная с 1. Rating равен 0, если оценки нет,
+// WatchedAt - дата просмотра в ISO 8601 или пустая строка. Description - описание
+// фильма, если оно есть в выгрузке; без него фильм нельзя создать.
+type Entry struct {
+	Row         int
+	Title       string
+	Year        string
+	Description string
+	Rating      int
+	WatchedAt   string
+	Err         error
+}
+
+// Match - запись истории, сопоставленная с фильмом каталога.
+type Match struct {
+	FilmID int64
+	Entry  Entry
+}
+
+type RowResult struct {
+	Row    int    `json:"row"`
+	Title  string `json:"title,omitempty"`
+	Year   string `json:"year,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Matched   int         `json:"matched"`
+	Created   int         `json:"created"`
+	Unmatched int         `json:"unmatched"`
+	Errors    int         `json:"errors"`
+	Rows      []RowResult `json:"rows"`
+}
+
+// NotSaved - ответ на импорт, история которого не сохранилась. Фильмы, созданные
+// до ошибки, остаются в каталоге и перечислены в Created.
+type NotSaved struct {
+	pkg.Problem
+	Created []RowResult `json:"created,omitempty"`
+}
+
+// Item - фильм из истории пользователя с его оценкой и датами просмотров.
+type Item struct {
+	Title       string   `json:"title"`
+	ReleaseDate string   `json:"release_date"`
+	Rating      int      `json:"rating,omitempty"`
+	WatchedAt   []string `json:"watched_at,omitempty"`
+}
+
+func (report *Report) Add(result RowResult) {
+	switch result.Status {
+	case StatusMatched:
+		report.Matched++
+	case StatusCreated:
+		report.Created++
+	case StatusUnmatched:
+		report.Unmatched++
+	case StatusError:
+		report.Errors++
+	}
+	report.Rows = append(report.Rows, result)
+}
+
+// Parse читает файл экспорта source: CSV Letterboxd (ratings.csv, diary.csv,
+// watched.csv) или JSON Trakt (history, watched или ratings фильмов).
+// Ошибки отдельных строк попадают в Entry.Err.
+func Parse(r io.Reader, source string) ([]Entry, error) {
+	switch source {
+	case SourceLetterboxd:
+		return parseLetterboxd(r)
+	case SourceTrakt:
+		return parseTrakt(r)
+	default:
+		return nil, fmt.Errorf("unknown source %q: it must be letterboxd or trakt", source)
+	}
+}
+
+func parseLetterboxd(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read csv header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"Name", "Year"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("no %s column in csv header", name)
+		}
+	}
+
+	// в diary.csv дата просмотра в колонке Watched Date, в watched.csv - в Date,
+	// а в ratings.csv Date - дата оценки, а не просмотра
+	watchedColumn := ""
+	if _, ok := columns["Watched Date"]; ok {
+		watchedColumn = "Watched Date"
+	} else if _, ok := columns["Rating"]; !ok {
+		watchedColumn = "Date"
+	}
+
+	var entries []Entry
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				entries = append(entries, Entry{Row: parseErr.Line, Err: err})
+				continue
+			}
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		get := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		entry := Entry{Row: line, Title: get("Name"), Year: get("Year")}
+		if stars := get("Rating"); stars != "" {
+			value, err := strconv.ParseFloat(stars, 64)
+			if err != nil {
+				entry.Err = fmt.Errorf("wrong rating %q", stars)
+			}
+			// Letterboxd ставит от 0.5 до 5 звезд с шагом 0.5
+			entry.Rating = int(math.Round(value * 2))
+		}
+		if date := get(watchedColumn); watchedColumn != "" && date != "" {
+			entry.WatchedAt, err = convertDate("2006-01-02", date)
+			if err != nil && entry.Err == nil {
+				entry.Err = err
+			}
+		}
+		validate(&entry)
+		entries = append(entries, entry)
+	}
+}
+
+type traktItem struct {
+	Rating        int    `json:"rating"`
+	WatchedAt     string `json:"watched_at"`
+	LastWatchedAt string `json:"last_watched_at"`
+	Movie         *struct {
+		Title    string `json:"title"`
+		Year     int    `json:"year"`
+		Overview string `json:"overview"`
+	} `json:"movie"`
+}
+
+func parseTrakt(r io.Reader) ([]Entry, error) {
+	var items []json.RawMessage
+	err := json.NewDecoder(r).Decode(&items)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode json array: %w", err)
+	}
+
+	var entries []Entry
+	for i, data := range items {
+		var item traktItem
+		err := json.Unmarshal(data, &item)
+		if err != nil {
+			entries = append(entries, Entry{Row: i + 1, Err: err})
+			continue
+		}
+		// сериалы и эпизоды в каталоге не хранятся
+		if item.Movie == nil {
+			continue
+		}
+
+		entry := Entry{Row: i + 1, Title: item.Movie.Title, Description: item.Movie.Overview, Rating: item.Rating}
+		if item.Movie.Year != 0 {
+			entry.Year = strconv.Itoa(item.Movie.Year)
+		}
+		watchedAt := item.WatchedAt
+		if watchedAt == "" {
+			watchedAt = item.LastWatchedAt
+		}
+		if watchedAt != "" {
+			entry.WatchedAt, entry.Err = convertDate(time.RFC3339, watchedAt)
+		}
+		validate(&entry)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func validate(entry *Entry) {
+	if entry.Err != nil {
+		return
+	}
+	switch {
+	case entry.Title == "":
+		entry.Err = errors.New("empty title")
+	case len(entry.Year) != 4:
+		entry.Err = fmt.Errorf("wrong year %q", entry.Year)
+	case entry.Rating < 0 || entry.Rating > 10:
+		entry.Err = fmt.Errorf("wrong rating %d: it must be between 1 and 10", entry.Rating)
+	}
+}
+
+func convertDate(layout, value string) (string, error) {
+	date, err := time.Parse(layout, value)
+	if err != nil {
+		return "", fmt.Errorf("wrong date %q", value)
+	}
+	return date.Format(pkg.DateFormatISO), nil
+}
@@ -0,0 +1,204 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/auth"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// DefaultRating - рейтинг фильма, созданного при импорте по записи без оценки.
+const DefaultRating = 5
+
+type Storage interface {
+	FindFilm(title, year string) (int64, error)
+	Save(userId uint32, source string, matches []Match) error
+	Get(userId uint32) ([]Item, error)
+}
+
+type HistoryHandler struct {
+	HistoryRepo Storage
+	FilmRepo    film.Storage
+}
+
+// @Summary Импортирует личную историю из Letterboxd или Trakt
+// @Description Загружает оценки и даты просмотров текущего пользователя из экспорта Letterboxd (ratings.csv, diary.csv или watched.csv) или Trakt (JSON истории, просмотренных или оцененных фильмов). Фильмы сопоставляются с каталогом по названию и году выхода. Оценки Letterboxd (0.5-5 звезд) переводятся в шкалу от 1 до 10. С create=true администратор может создать отсутствующие фильмы с описанием из выгрузки (overview в Trakt), иначе они попадают в отчет как unmatched. Фильм без описания не создается, строка попадает в отчет как error.
+// @Accept plain
+// @Produce json
+// @Param source query string true "Источник (letterboxd, trakt)"
+// @Param create query boolean false "Создавать отсутствующие в каталоге фильмы (только для администратора)"
+// @Param file body string true "Содержимое файла экспорта"
+// @Success 200 {object} Report "Отчет по каждой строке"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 401 {object} pkg.Problem "No auth"
+// @Failure 403 {object} pkg.Problem "Only admins can create films"
+// @Failure 500 {object} NotSaved "Internal server error, created films are listed in created"
+// @Router /user/history/import [post]
+func (h *HistoryHandler) ImportHistory(w http.ResponseWriter, r *http.Request) {
+	defer pkg.CloseBody(r)
+
+	sess, err := auth.SessionFromContext(r.Context())
+	if err != nil {
+		pkg.WriteProblem(w, r, http.StatusUnauthorized, "No auth")
+		return
+	}
+
+	query := r.URL.Query()
+	var create bool
+	if s := query.Get("create"); s != "" {
+		create, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Println("error importing history: wrong create", err)
+			pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong create: it must be true or false")
+			return
+		}
+	}
+	// фильмы от обычных пользователей добавляются только через модерацию
+	if create && !sess.IsAdmin {
+		log.Println("error importing history: create by non-admin user", sess.UserID)
+		pkg.WriteProblem(w, r, http.StatusForbidden, "only admins can create films")
+		return
+	}
+
+	source := query.Get("source")
+	entries, err := Parse(r.Body, source)
+	if err != nil {
+		log.Println("error parsing history file:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := &Report{Rows: []RowResult{}}
+	var matches []Match
+	var created []RowResult
+	for _, entry := range entries {
+		result := RowResult{Row: entry.Row, Title: entry.Title, Year: entry.Year}
+		if entry.Err != nil {
+			result.Status = StatusError
+			result.Error = entry.Err.Error()
+			report.Add(result)
+			continue
+		}
+
+		filmId, err := h.HistoryRepo.FindFilm(entry.Title, entry.Year)
+		switch {
+		case err == nil:
+			result.Status = StatusMatched
+		case errors.Is(err, ErrNoFilm) && create:
+			filmId, err = h.createFilm(r.Context(), entry)
+			if err != nil {
+				log.Println("error creating film from history:", err)
+				result.Status = StatusError
+				result.Error = "can't create film"
+				if errors.Is(err, ErrNoDescription) {
+					result.Error += ": " + ErrNoDescription.Error()
+				}
+				report.Add(result)
+				continue
+			}
+			result.Status = StatusCreated
+			created = append(created, result)
+		case errors.Is(err, ErrNoFilm) || errors.Is(err, ErrAmbiguous):
+			result.Status = StatusUnmatched
+			if errors.Is(err, ErrAmbiguous) {
+				result.Error = ErrAmbiguous.Error()
+			}
+			report.Add(result)
+			continue
+		default:
+			log.Println("error matching history film:", err)
+			writeNotSaved(w, r, created)
+			return
+		}
+
+		matches = append(matches, Match{FilmID: filmId, Entry: entry})
+		report.Add(result)
+	}
+
+	err = h.HistoryRepo.Save(sess.UserID, source, matches)
+	if err != nil {
+		log.Println("error saving history:", err)
+		writeNotSaved(w, r, created)
+		return
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		log.Println("error marshalling history report:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal history report")
+		return
+	}
+
+	log.Printf("history imported for user %d: matched %d, created %d, unmatched %d, errors %d",
+		sess.UserID, report.Matched, report.Created, report.Unmatched, report.Errors)
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
+
+// writeNotSaved отвечает 500 на импорт, который не удалось завершить. Фильмы
+// создаются до сохранения истории, поэтому клиенту сообщается, какие из них
+// уже есть в каталоге.
+func writeNotSaved(w http.ResponseWriter, r *http.Request, created []RowResult) {
+	problem := &NotSaved{
+		Problem: *pkg.NewProblem(r, http.StatusInternalServerError, "can't import history"),
+		Created: created,
+	}
+	pkg.WriteProblemJSON(w, http.StatusInternalServerError, problem)
+}
+
+func (h *HistoryHandler) createFilm(ctx context.Context, entry Entry) (int64, error) {
+	if entry.Description == "" {
+		return 0, ErrNoDescription
+	}
+
+	newFilm := film.Film{
+		Title:       entry.Title,
+		Description: entry.Description,
+		ReleaseDate: entry.Year, // в выгрузках известен только год
+		Rating:      entry.Rating,
+	}
+	if newFilm.Rating == 0 {
+		newFilm.Rating = DefaultRating
+	}
+
+	err := h.FilmRepo.Add(ctx, &newFilm)
+	if err != nil {
+		return 0, err
+	}
+	return h.FilmRepo.GetFilmId(&newFilm)
+}
+
+// @Summary Получает личную историю
+// @Description Возвращает фильмы, которые текущий пользователь оценил или посмотрел, с оценкой и датами просмотров.
+// @Produce json
+// @Success 200 {array} Item "История пользователя"
+// @Failure 401 {object} pkg.Problem "No auth"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /user/history [get]
+func (h *HistoryHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	sess, err := auth.SessionFromContext(r.Context())
+	if err != nil {
+		pkg.WriteProblem(w, r, http.StatusUnauthorized, "No auth")
+		return
+	}
+
+	items, err := h.HistoryRepo.Get(sess.UserID)
+	if err != nil {
+		log.Println("error getting history:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't get history")
+		return
+	}
+
+	resp, err := json.Marshal(items)
+	if err != nil {
+		log.Println("error marshalling history:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal history")
+		return
+	}
+
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,129 @@
+package history
+
+import (
+	"database/sql"
+	"filmoteka/pkg"
+	"fmt"
+)
+
+type HistoryRepository struct {
+	db *sql.DB
+}
+
+func NewHistoryRepository(db *sql.DB) *HistoryRepository {
+	return &HistoryRepository{
+		db: db,
+	}
+}
+
+// FindFilm ищет фильм по названию без учета регистра и году выхода.
+func (repo *HistoryRepository) FindFilm(title, year string) (int64, error) {
+	op := "history_repo.FindFilm"
+
+	rows, err := repo.db.Query("SELECT id FROM film WHERE lower(title) = lower($1) AND EXTRACT(YEAR FROM release_date) = $2 LIMIT 2",
+		title, year)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("%s: %w", op, ErrNoFilm)
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, fmt.Errorf("%s: %w", op, ErrAmbiguous)
+	}
+}
+
+// Save сохраняет оценки и просмотры пользователя в одной транзакции. Повторный
+// импорт того же файла не создает дубликатов: оценка перезаписывается, а
+// просмотр с той же датой пропускается.
+func (repo *HistoryRepository) Save(userId uint32, source string, matches []Match) error {
+	op := "history_repo.Save"
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, match := range matches {
+		if match.Entry.Rating != 0 {
+			_, err = tx.Exec(`INSERT INTO user_film_rating(user_id, film_id, rating, source) VALUES($1, $2, $3, $4)
+				ON CONFLICT (user_id, film_id) DO UPDATE SET rating = EXCLUDED.rating, source = EXCLUDED.source, updated_at = now()`,
+				userId, match.FilmID, match.Entry.Rating, source)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		if match.Entry.WatchedAt != "" {
+			_, err = tx.Exec(`INSERT INTO user_film_watch(user_id, film_id, watched_at, source) VALUES($1, $2, $3, $4)
+				ON CONFLICT DO NOTHING`,
+				userId, match.FilmID, match.Entry.WatchedAt, source)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Get возвращает историю пользователя, отсортированную по названию фильма.
+func (repo *HistoryRepository) Get(userId uint32) ([]Item, error) {
+	op := "history_repo.Get"
+
+	rows, err := repo.db.Query(`SELECT f.id, f.title, partial_date(f.release_date, f.release_date_precision, f.release_date_approx),
+		COALESCE(r.rating, 0), w.watched_at
+		FROM film f
+		LEFT JOIN user_film_rating r ON r.film_id = f.id AND r.user_id = $1
+		LEFT JOIN user_film_watch w ON w.film_id = f.id AND w.user_id = $1
+		WHERE r.user_id IS NOT NULL OR w.user_id IS NOT NULL
+		ORDER BY f.title, f.id, w.id`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	var lastId int64
+	for rows.Next() {
+		var filmId int64
+		var item Item
+		var watchedAt string
+		err = rows.Scan(&filmId, &item.Title, pkg.ScanDate(&item.ReleaseDate), &item.Rating, pkg.ScanDate(&watchedAt))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(items) == 0 || filmId != lastId {
+			items = append(items, item)
+			lastId = filmId
+		}
+		if watchedAt != "" {
+			last := &items[len(items)-1]
+			last.WatchedAt = append(last.WatchedAt, watchedAt)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return items, nil
+}
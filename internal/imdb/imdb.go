@@ -0,0 +1,133 @@
+package imdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Stages - этапы импорта в порядке выполнения. Для каждого этапа в базе хранится
+// номер последней сохраненной строки файла, поэтому прерванный импорт продолжается с нее.
+const (
+	StageTitles = "titles"
+	StageNames  = "names"
+	StageLinks  = "links"
+)
+
+// null - так в датасетах IMDb обозначается пустое значение.
+const null = `\N`
+
+// TSVReader построчно читает файл датасета IMDb (gzip или обычный TSV).
+// Кавычки в IMDb TSV не экранируются, поэтому encoding/csv здесь не подходит.
+type TSVReader struct {
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	columns map[string]int
+	fields  []string
+	line    int64
+}
+
+func OpenTSV(path string) (*TSVReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &TSVReader{file: file}
+	var r io.Reader = bufio.NewReader(file)
+	if strings.HasSuffix(path, ".gz") {
+		reader.gz, err = gzip.NewReader(r)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		r = reader.gz
+	}
+
+	reader.scanner = bufio.NewScanner(r)
+	reader.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !reader.scanner.Scan() {
+		reader.Close()
+		if err := reader.scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, fmt.Errorf("%s: empty file", path)
+	}
+
+	reader.columns = make(map[string]int)
+	for i, name := range strings.Split(reader.scanner.Text(), "\t") {
+		reader.columns[name] = i
+	}
+	return reader, nil
+}
+
+// Require проверяет, что в заголовке файла есть все нужные колонки.
+func (r *TSVReader) Require(columns ...string) error {
+	for _, column := range columns {
+		if _, ok := r.columns[column]; !ok {
+			return fmt.Errorf("%s: no %s column", r.file.Name(), column)
+		}
+	}
+	return nil
+}
+
+// Next переходит к следующей строке. Line - номер строки данных, начиная с 1.
+func (r *TSVReader) Next() bool {
+	if !r.scanner.Scan() {
+		return false
+	}
+	r.line++
+	r.fields = strings.Split(r.scanner.Text(), "\t")
+	return true
+}
+
+func (r *TSVReader) Line() int64 {
+	return r.line
+}
+
+// Get возвращает значение колонки текущей строки; \N превращается в пустую строку.
+func (r *TSVReader) Get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.fields) || r.fields[i] == null {
+		return ""
+	}
+	return r.fields[i]
+}
+
+func (r *TSVReader) Err() error {
+	return r.scanner.Err()
+}
+
+func (r *TSVReader) Close() error {
+	var gzErr error
+	if r.gz != nil {
+		gzErr = r.gz.Close()
+	}
+	return errors.Join(gzErr, r.file.Close())
+}
+
+// YearDate переводит год IMDb в дату ISO 8601. IMDb хранит только год, поэтому
+// в базу записывается 1 января с точностью до года.
+func YearDate(year string) string {
+	if len(year) != 4 {
+		return ""
+	}
+	return year + "-01-01"
+}
+
+// Gender определяет пол актера по категории в title.principals.
+func Gender(category string) string {
+	switch category {
+	case "actor":
+		return "man"
+	case "actress":
+		return "woman"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,507 @@
+package imdb
+
+import (
+	"context"
+	"database/sql"
+	"filmoteka/internal/audit"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultBatchSize = 1000
+	DefaultRating    = 5
+
+	maxTitleLength = 150
+	maxNameLength  = 255
+)
+
+type Options struct {
+	TitlesPath     string
+	NamesPath      string
+	PrincipalsPath string
+	// RatingsPath - необязательный title.ratings.tsv. Без него фильмы получают DefaultRating,
+	// так как рейтинг в таблице film обязателен.
+	RatingsPath   string
+	TitleTypes    []string
+	DefaultRating int
+	BatchSize     int
+	// Restart сбрасывает сохраненный прогресс и проходит файлы с начала.
+	Restart bool
+}
+
+type Stats struct {
+	Created int64
+	Matched int64
+	Skipped int64
+}
+
+type ImdbRepository struct {
+	db *sql.DB
+}
+
+func NewImdbRepository(db *sql.DB) *ImdbRepository {
+	return &ImdbRepository{
+		db: db,
+	}
+}
+
+// Import загружает фильмы, актеров и связи между ними из датасетов IMDb.
+// Импорт идемпотентен: уже загруженные записи находятся по imdb_id, а записи,
+// совпадающие с существующими по unique_title_release_date или unique_actor_fields
+// (дата с точностью до года), получают imdb_id вместо создания дубликата. Новые
+// фильмы и фильмы, получившие актеров, записываются в ревизии и аудит так же, как
+// при изменении через API.
+func (repo *ImdbRepository) Import(opts Options) error {
+	op := "imdb_repo.Import"
+	ctx := context.Background()
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.DefaultRating == 0 {
+		opts.DefaultRating = DefaultRating
+	}
+	if len(opts.TitleTypes) == 0 {
+		opts.TitleTypes = []string{"movie"}
+	}
+
+	if opts.Restart {
+		_, err := repo.db.Exec("DELETE FROM imdb_import_progress")
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err := repo.importTitles(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	err = repo.importNames(opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	err = repo.importLinks(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (repo *ImdbRepository) importTitles(ctx context.Context, opts Options) error {
+	ratings, err := loadRatings(opts.RatingsPath)
+	if err != nil {
+		return err
+	}
+
+	types := make(map[string]bool)
+	for _, titleType := range opts.TitleTypes {
+		types[titleType] = true
+	}
+
+	reader, err := OpenTSV(opts.TitlesPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := reader.Require("tconst", "titleType", "primaryTitle", "isAdult", "startYear", "genres"); err != nil {
+		return err
+	}
+
+	return repo.runStage(StageTitles, reader, opts.BatchSize, nil, func(tx *sql.Tx, stats *Stats) error {
+		title := reader.Get("primaryTitle")
+		releaseDate := YearDate(reader.Get("startYear"))
+		// описанием фильма служат жанры, а оно обязательно
+		description := strings.ReplaceAll(reader.Get("genres"), ",", ", ")
+		if !types[reader.Get("titleType")] || reader.Get("isAdult") == "1" || title == "" || releaseDate == "" ||
+			description == "" || utf8.RuneCountInString(title) > maxTitleLength {
+			stats.Skipped++
+			return nil
+		}
+
+		rating, ok := ratings[reader.Get("tconst")]
+		if !ok {
+			rating = opts.DefaultRating
+		}
+
+		filmId, err := upsert(tx, stats,
+			`INSERT INTO film(title, description, release_date, release_date_precision, rating, imdb_id)
+			VALUES($1, $2, $3, 'year', $4, $5)
+			ON CONFLICT DO NOTHING RETURNING id`,
+			[]interface{}{title, description, releaseDate, rating, reader.Get("tconst")},
+			`UPDATE film SET imdb_id = $1
+			WHERE title = $2 AND release_date = $3 AND release_date_precision = 'year' AND NOT release_date_approx AND imdb_id IS NULL`,
+			[]interface{}{reader.Get("tconst"), title, releaseDate})
+		if err != nil || filmId == 0 {
+			return err
+		}
+		return film.RecordCreate(ctx, tx, filmId)
+	})
+}
+
+func (repo *ImdbRepository) importNames(opts Options) error {
+	done, err := repo.stageDone(StageNames, opts.NamesPath)
+	if err != nil || done {
+		return err
+	}
+
+	// пол актера есть только в title.principals (категории actor/actress),
+	// поэтому сначала собираем его для всех, кто снимался в загруженных фильмах
+	genders, err := repo.castGenders(opts.PrincipalsPath)
+	if err != nil {
+		return err
+	}
+
+	reader, err := OpenTSV(opts.NamesPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := reader.Require("nconst", "primaryName", "birthYear"); err != nil {
+		return err
+	}
+
+	return repo.runStage(StageNames, reader, opts.BatchSize, nil, func(tx *sql.Tx, stats *Stats) error {
+		gender, ok := genders[reader.Get("nconst")]
+		if !ok {
+			return nil
+		}
+		name := reader.Get("primaryName")
+		birthDate := YearDate(reader.Get("birthYear"))
+		if name == "" || birthDate == "" || utf8.RuneCountInString(name) > maxNameLength {
+			stats.Skipped++
+			return nil
+		}
+
+		_, err := upsert(tx, stats,
+			`INSERT INTO actor(name, gender, birth_date, birth_date_precision, imdb_id)
+			VALUES($1, $2, $3, 'year', $4)
+			ON CONFLICT DO NOTHING RETURNING id`,
+			[]interface{}{name, gender, birthDate, reader.Get("nconst")},
+			`UPDATE actor SET imdb_id = $1
+			WHERE name = $2 AND gender = $3 AND birth_date = $4 AND birth_date_precision = 'year' AND NOT birth_date_approx AND imdb_id IS NULL`,
+			[]interface{}{reader.Get("nconst"), name, gender, birthDate})
+		return err
+	})
+}
+
+func (repo *ImdbRepository) importLinks(ctx context.Context, opts Options) error {
+	reader, err := OpenTSV(opts.PrincipalsPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := reader.Require("tconst", "nconst", "category"); err != nil {
+		return err
+	}
+
+	// фильмы, получившие актеров в текущей пачке, и их состояние до нее: каждый
+	// такой фильм получает одну ревизию на пачку
+	cast := &castChanges{before: make(map[int64]*film.Film)}
+	flush := func(tx *sql.Tx) error {
+		return cast.record(ctx, tx)
+	}
+
+	return repo.runStage(StageLinks, reader, opts.BatchSize, flush, func(tx *sql.Tx, stats *Stats) error {
+		if Gender(reader.Get("category")) == "" {
+			return nil
+		}
+
+		var filmId, actorId int64
+		err := tx.QueryRow("SELECT f.id, a.id FROM film f, actor a WHERE f.imdb_id = $1 AND a.imdb_id = $2",
+			reader.Get("tconst"), reader.Get("nconst")).Scan(&filmId, &actorId)
+		if err == sql.ErrNoRows {
+			stats.Skipped++
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = cast.track(tx, filmId)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec("INSERT INTO film_actor(film_id, actor_id) VALUES($1, $2) ON CONFLICT DO NOTHING", filmId, actorId)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected > 0 {
+			stats.Created++
+		} else {
+			stats.Skipped++
+		}
+		return nil
+	})
+}
+
+// castChanges собирает фильмы, состав которых меняется в транзакции пачки.
+type castChanges struct {
+	ids    []int64
+	before map[int64]*film.Film
+}
+
+// track запоминает состояние фильма filmId до первого изменения его состава в пачке.
+func (c *castChanges) track(tx *sql.Tx, filmId int64) error {
+	if _, ok := c.before[filmId]; ok {
+		return nil
+	}
+	before, err := film.GetFilmById(tx, filmId)
+	if err != nil {
+		return err
+	}
+	c.ids = append(c.ids, filmId)
+	c.before[filmId] = before
+	return nil
+}
+
+// record увеличивает версию изменившихся фильмов, записывает их ревизии и аудит
+// и очищает список для следующей пачки.
+func (c *castChanges) record(ctx context.Context, tx *sql.Tx) error {
+	for _, filmId := range c.ids {
+		before := c.before[filmId]
+		after, err := film.GetFilmById(tx, filmId)
+		if err != nil {
+			return err
+		}
+		if film.SameCast(before.Actors, after.Actors) {
+			continue
+		}
+
+		_, err = pkg.BumpVersion(ctx, tx, "film", filmId)
+		if err != nil {
+			return err
+		}
+		err = film.AddRevision(ctx, tx, filmId, film.RevisionUpdate, before, after)
+		if err != nil {
+			return err
+		}
+		err = film.RecordAudit(ctx, tx, filmId, audit.ActionUpdate, before, after)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.ids = nil
+	c.before = make(map[int64]*film.Film)
+	return nil
+}
+
+// upsert пробует вставить запись; если она конфликтует с уникальным ограничением,
+// привязывает imdb_id к существующей записи без imdb_id. Иначе запись уже
+// загружена раньше или это дубликат внутри IMDb - она пропускается. Возвращает
+// id вставленной записи или 0, если запись не создана.
+func upsert(tx *sql.Tx, stats *Stats, insert string, insertArgs []interface{}, match string, matchArgs []interface{}) (int64, error) {
+	var id int64
+	err := tx.QueryRow(insert, insertArgs...).Scan(&id)
+	if err == nil {
+		stats.Created++
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	result, err := tx.Exec(match, matchArgs...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		stats.Matched++
+	} else {
+		stats.Skipped++
+	}
+	return 0, nil
+}
+
+// runStage проходит файл этапа stage, пропуская уже сохраненные строки, и
+// сохраняет прогресс в той же транзакции, что и каждую пачку из batchSize строк.
+// Если flush задана, она выполняется в транзакции пачки перед ее сохранением.
+func (repo *ImdbRepository) runStage(stage string, reader *TSVReader, batchSize int, flush func(tx *sql.Tx) error,
+	row func(tx *sql.Tx, stats *Stats) error) error {
+	done, err := repo.progress(stage)
+	if err != nil {
+		return err
+	}
+
+	stats := &Stats{}
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	inBatch := 0
+	for reader.Next() {
+		if reader.Line() <= done {
+			continue
+		}
+
+		err = row(tx, stats)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", stage, reader.Line(), err)
+		}
+
+		inBatch++
+		if inBatch < batchSize {
+			continue
+		}
+		err = commitProgress(tx, stage, reader.Line(), flush)
+		if err != nil {
+			return err
+		}
+		log.Printf("imdb %s: line %d, created %d, matched %d, skipped %d", stage, reader.Line(), stats.Created, stats.Matched, stats.Skipped)
+		inBatch = 0
+		tx, err = repo.db.Begin()
+		if err != nil {
+			return err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return fmt.Errorf("%s: %w", stage, err)
+	}
+
+	if reader.Line() > done {
+		err = commitProgress(tx, stage, reader.Line(), flush)
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("imdb %s done: created %d, matched %d, skipped %d", stage, stats.Created, stats.Matched, stats.Skipped)
+	return nil
+}
+
+func commitProgress(tx *sql.Tx, stage string, line int64, flush func(tx *sql.Tx) error) error {
+	if flush != nil {
+		err := flush(tx)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`INSERT INTO imdb_import_progress(stage, line) VALUES($1, $2)
+		ON CONFLICT (stage) DO UPDATE SET line = EXCLUDED.line, updated_at = now()`, stage, line)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (repo *ImdbRepository) progress(stage string) (int64, error) {
+	var line int64
+	err := repo.db.QueryRow("SELECT line FROM imdb_import_progress WHERE stage = $1", stage).Scan(&line)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return line, err
+}
+
+// stageDone сообщает, что этап уже пройден до конца файла, чтобы не собирать
+// для него данные заново при продолжении импорта.
+func (repo *ImdbRepository) stageDone(stage, path string) (bool, error) {
+	done, err := repo.progress(stage)
+	if err != nil || done == 0 {
+		return false, err
+	}
+
+	reader, err := OpenTSV(path)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+	for reader.Next() {
+	}
+	return reader.Line() <= done, reader.Err()
+}
+
+// castGenders возвращает пол актеров и актрис, снимавшихся в фильмах, которые уже
+// загружены из IMDb.
+func (repo *ImdbRepository) castGenders(principalsPath string) (map[string]string, error) {
+	rows, err := repo.db.Query("SELECT imdb_id FROM film WHERE imdb_id IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	films := make(map[string]bool)
+	for rows.Next() {
+		var tconst string
+		if err := rows.Scan(&tconst); err != nil {
+			return nil, err
+		}
+		films[tconst] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reader, err := OpenTSV(principalsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if err := reader.Require("tconst", "nconst", "category"); err != nil {
+		return nil, err
+	}
+
+	genders := make(map[string]string)
+	for reader.Next() {
+		gender := Gender(reader.Get("category"))
+		if gender == "" || !films[reader.Get("tconst")] {
+			continue
+		}
+		if _, ok := genders[reader.Get("nconst")]; !ok {
+			genders[reader.Get("nconst")] = gender
+		}
+	}
+	return genders, reader.Err()
+}
+
+// loadRatings читает средние оценки из title.ratings.tsv и округляет их до целых от 1 до 10.
+func loadRatings(path string) (map[string]int, error) {
+	ratings := make(map[string]int)
+	if path == "" {
+		return ratings, nil
+	}
+
+	reader, err := OpenTSV(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if err := reader.Require("tconst", "averageRating"); err != nil {
+		return nil, err
+	}
+
+	for reader.Next() {
+		average, err := strconv.ParseFloat(reader.Get("averageRating"), 64)
+		if err != nil {
+			continue
+		}
+		rating := int(math.Round(average))
+		if rating < 1 {
+			rating = 1
+		}
+		if rating > 10 {
+			rating = 10
+		}
+		ratings[reader.Get("tconst")] = rating
+	}
+	return ratings, reader.Err()
+}
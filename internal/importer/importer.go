@@ -0,0 +1,252 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+
+	StatusCreated = "created"
+	StatusUpdated = "updated"
+	StatusSkipped = "skipped"
+	StatusError   = "error"
+
+	DefaultBatchSize = 100
+)
+
+// Record - один фильм из файла импорта. Row - номер строки CSV/NDJSON или
+// номер элемента JSON-массива, начиная с 1. Если строку не удалось разобрать, Err не пустой.
+type Record struct {
+	Row  int
+	Film film.Film
+	Err  error
+}
+
+type RowResult struct {
+	Row         int    `json:"row"`
+	Title       string `json:"title,omitempty"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+type Report struct {
+	DryRun  bool        `json:"dry_run"`
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Skipped int         `json:"skipped"`
+	Errors  int         `json:"errors"`
+	Rows    []RowResult `json:"rows"`
+}
+
+type Options struct {
+	DryRun    bool
+	BatchSize int
+}
+
+func (report *Report) add(result RowResult) {
+	switch result.Status {
+	case StatusCreated:
+		report.Created++
+	case StatusUpdated:
+		report.Updated++
+	case StatusSkipped:
+		report.Skipped++
+	case StatusError:
+		report.Errors++
+	}
+	report.Rows = append(report.Rows, result)
+}
+
+// Parse читает фильмы из r в формате format (csv, json или ndjson).
+// Ошибка возвращается только если файл нельзя прочитать целиком; ошибки
+// отдельных строк попадают в Record.Err, чтобы их можно было показать в отчете.
+func Parse(r io.Reader, format string) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSON:
+		return parseJSON(r)
+	case FormatNDJSON:
+		return parseNDJSON(r)
+	default:
+		return nil, fmt.Errorf("unknown import format %q: it must be csv, json or ndjson", format)
+	}
+}
+
+// FormatFromContentType определяет формат файла по заголовку Content-Type.
+func FormatFromContentType(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return FormatCSV
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/ndjson"):
+		return FormatNDJSON
+	case strings.HasPrefix(contentType, "application/json"):
+		return FormatJSON
+	default:
+		return ""
+	}
+}
+
+// ValidateFilm проверяет фильм по тегам полей, как и обработчики (pkg.Validate),
+// и объединяет ошибки полей в одну.
+func ValidateFilm(f *film.Film) error {
+	errs := pkg.Validate(f)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Field + ": " + e.Message
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// parseCSV ожидает заголовок с колонками title, description, release_date, rating
+// и необязательными actor_name, actor_gender, actor_birth_date. Строки с одинаковыми
+// title и release_date склеиваются в один фильм с несколькими актерами.
+func parseCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can't read csv header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, required := range []string{"title", "description", "release_date", "rating"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header has no %s column", required)
+		}
+	}
+	get := func(fields []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	var records []Record
+	films := make(map[string]int)
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("can't read csv: %w", err)
+			}
+			records = append(records, Record{Row: parseErr.Line, Err: err})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		f := film.Film{
+			Title:       get(fields, "title"),
+			Description: get(fields, "description"),
+			ReleaseDate: get(fields, "release_date"),
+		}
+		f.Rating, err = strconv.Atoi(get(fields, "rating"))
+		if err != nil {
+			records = append(records, Record{Row: line, Film: f, Err: fmt.Errorf("wrong rating %q: it must be a number", get(fields, "rating"))})
+			continue
+		}
+
+		var a *actor.Actor
+		if name := get(fields, "actor_name"); name != "" {
+			a = &actor.Actor{
+				Name:      name,
+				Gender:    get(fields, "actor_gender"),
+				BirthDate: get(fields, "actor_birth_date"),
+			}
+		}
+
+		key := f.Title + "\x00" + pkg.DBDate(f.ReleaseDate)
+		if i, ok := films[key]; ok {
+			if a != nil {
+				records[i].Film.Actors = append(records[i].Film.Actors, *a)
+			}
+			continue
+		}
+		if a != nil {
+			f.Actors = []actor.Actor{*a}
+		}
+		films[key] = len(records)
+		records = append(records, Record{Row: line, Film: f})
+	}
+
+	return records, nil
+}
+
+func parseJSON(r io.Reader) ([]Record, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("can't read json: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("json import must be an array of films")
+	}
+
+	var records []Record
+	for row := 1; decoder.More(); row++ {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err != nil {
+			return nil, fmt.Errorf("can't read json element %d: %w", row, err)
+		}
+		records = append(records, decodeFilm(row, raw))
+	}
+
+	return records, nil
+}
+
+func parseNDJSON(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var records []Record
+	for row := 1; scanner.Scan(); row++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		records = append(records, decodeFilm(row, line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read ndjson: %w", err)
+	}
+	return records, nil
+}
+
+func decodeFilm(row int, data []byte) Record {
+	var f film.Film
+	err := json.Unmarshal(data, &f)
+	if err != nil {
+		return Record{Row: row, Err: fmt.Errorf("can't decode film: %w", err)}
+	}
+	return Record{Row: row, Film: f}
+}
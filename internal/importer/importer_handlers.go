@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"filmoteka/pkg"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type Storage interface {
+	Import(ctx context.Context, records []Record, opts Options) (*Report, error)
+}
+
+type ImportHandler struct {
+	ImportRepo Storage
+}
+
+// @Summary Массово импортирует фильмы
+// @Description Импортирует фильмы с актерами из файла CSV, JSON (массив фильмов) или NDJSON, переданного в теле запроса. В CSV каждая строка - фильм и один актер (колонки title, description, release_date, rating, actor_name, actor_gender, actor_birth_date), строки одного фильма объединяются. Существующие фильмы обновляются. В режиме dry_run ничего не сохраняется, но возвращается такой же отчет.
+// @Accept plain
+// @Produce json
+// @Param format query string false "Формат файла (csv, json, ndjson); по умолчанию определяется по Content-Type"
+// @Param dry_run query boolean false "Только проверить файл, не сохраняя изменений"
+// @Param batch_size query int false "Количество фильмов в одной транзакции (по умолчанию 100)"
+// @Param file body string true "Содержимое файла"
+// @Success 200 {object} Report "Отчет по каждой строке"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/film/import [post]
+func (h *ImportHandler) ImportFilms(w http.ResponseWriter, r *http.Request) {
+	defer pkg.CloseBody(r)
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = FormatFromContentType(r.Header.Get("Content-Type"))
+	}
+
+	var opts Options
+	var err error
+	if s := query.Get("dry_run"); s != "" {
+		opts.DryRun, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Println("error importing films: wrong dry_run", err)
+			pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong dry_run: it must be true or false")
+			return
+		}
+	}
+	if s := query.Get("batch_size"); s != "" {
+		opts.BatchSize, err = strconv.Atoi(s)
+		if err != nil || opts.BatchSize <= 0 {
+			log.Println("error importing films: wrong batch_size", err)
+			pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong batch_size: it must be a positive number")
+			return
+		}
+	}
+
+	records, err := Parse(r.Body, format)
+	if err != nil {
+		log.Println("error parsing import file:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := h.ImportRepo.Import(r.Context(), records, opts)
+	if err != nil {
+		log.Println("error importing films:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't import films")
+		return
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		log.Println("error marshalling import report:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal import report")
+		return
+	}
+
+	log.Printf("films imported: created %d, updated %d, skipped %d, errors %d, dry run %t",
+		report.Created, report.Updated, report.Skipped, report.Errors, report.DryRun)
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
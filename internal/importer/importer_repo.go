@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/audit"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+)
+
+type ImportRepository struct {
+	db        *sql.DB
+	actorRepo *actor.ActorRepository
+}
+
+func NewImportRepository(db *sql.DB) *ImportRepository {
+	return &ImportRepository{
+		db:        db,
+		actorRepo: actor.NewActorRepository(db),
+	}
+}
+
+// Import добавляет фильмы из records пачками по opts.BatchSize, каждая пачка в
+// своей транзакции. Ошибка одной строки откатывается до точки сохранения и не
+// мешает остальным строкам пачки. При opts.DryRun все строки выполняются в одной
+// транзакции, которая в конце откатывается, поэтому отчет совпадает с реальным импортом.
+func (repo *ImportRepository) Import(ctx context.Context, records []Record, opts Options) (*Report, error) {
+	op := "import_repo.Import"
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if opts.DryRun {
+		batchSize = len(records)
+	}
+
+	report := &Report{DryRun: opts.DryRun}
+	for start := 0; start < len(records); start += batchSize {
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		results, err := repo.importBatch(ctx, records[start:end], opts.DryRun)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, result := range results {
+			report.add(result)
+		}
+	}
+
+	return report, nil
+}
+
+func (repo *ImportRepository) importBatch(ctx context.Context, records []Record, dryRun bool) ([]RowResult, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]RowResult, 0, len(records))
+	for _, record := range records {
+		result := RowResult{
+			Row:         record.Row,
+			Title:       record.Film.Title,
+			ReleaseDate: record.Film.ReleaseDate,
+		}
+
+		err := record.Err
+		if err == nil {
+			err = ValidateFilm(&record.Film)
+		}
+		if err == nil {
+			result.Status, err = repo.importFilm(ctx, tx, &record.Film)
+		}
+		if err != nil {
+			result.Status = StatusError
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	if dryRun {
+		return results, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		// пачка не сохранилась, поэтому все ее строки считаются ошибочными
+		for i := range results {
+			if results[i].Status != StatusError {
+				results[i].Status = StatusError
+				results[i].Error = fmt.Sprintf("can't commit batch: %s", err)
+			}
+		}
+	}
+	return results, nil
+}
+
+// importFilm добавляет фильм или обновляет существующий с тем же названием и датой
+// выхода. Актеры заменяются, только если они указаны в файле.
+func (repo *ImportRepository) importFilm(ctx context.Context, tx *sql.Tx, f *film.Film) (string, error) {
+	_, err := tx.Exec("SAVEPOINT import_film")
+	if err != nil {
+		return "", err
+	}
+
+	status, err := repo.upsertFilm(ctx, tx, f)
+	if err != nil {
+		_, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT import_film")
+		if rollbackErr != nil {
+			return "", fmt.Errorf("%s (rollback: %s)", err, rollbackErr)
+		}
+		return "", err
+	}
+
+	_, err = tx.Exec("RELEASE SAVEPOINT import_film")
+	if err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+func (repo *ImportRepository) upsertFilm(ctx context.Context, tx *sql.Tx, f *film.Film) (string, error) {
+	var filmId int64
+	err := tx.QueryRow(`SELECT id FROM film
+		WHERE title = $1 AND release_date = $2 AND release_date_precision = $3 AND release_date_approx = $4`,
+		f.Title, pkg.DBDate(f.ReleaseDate), pkg.DBPrecision(f.ReleaseDate), pkg.DBApproximate(f.ReleaseDate)).Scan(&filmId)
+	if err == sql.ErrNoRows {
+		_, err = film.Insert(ctx, tx, repo.actorRepo, f)
+		if err != nil {
+			return "", err
+		}
+		return StatusCreated, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	before, err := film.GetFilmById(tx, filmId)
+	if err != nil {
+		return "", err
+	}
+
+	sameFields := before.Description == f.Description && before.Rating == f.Rating
+	sameCast := len(f.Actors) == 0 || film.SameCast(before.Actors, f.Actors)
+	if sameFields && sameCast {
+		return StatusSkipped, nil
+	}
+
+	_, err = tx.Exec("UPDATE film SET description = $1, rating = $2, version = version + 1 WHERE id = $3", f.Description, f.Rating, filmId)
+	if err != nil {
+		return "", err
+	}
+	if !sameCast {
+		err = film.ReplaceCast(tx, repo.actorRepo, filmId, f.Actors)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	after, err := film.GetFilmById(tx, filmId)
+	if err != nil {
+		return "", err
+	}
+	err = film.AddRevision(ctx, tx, filmId, film.RevisionUpdate, before, after)
+	if err != nil {
+		return "", err
+	}
+	err = film.RecordAudit(ctx, tx, filmId, audit.ActionUpdate, before, after)
+	if err != nil {
+		return "", err
+	}
+
+	return StatusUpdated, nil
+}
@@ -0,0 +1,239 @@
+package memory
+
+import (
+	"context"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+	"sort"
+)
+
+// ActorRepository - actor.Storage в памяти. Ненайденные актеры возвращают
+// pkg.ErrNotFound вместе с sql.ErrNoRows, как репозиторий PostgreSQL.
+type ActorRepository struct {
+	store *Store
+}
+
+func NewActorRepository(store *Store) *ActorRepository {
+	return &ActorRepository{store: store}
+}
+
+func (repo *ActorRepository) Add(a *actor.Actor) error {
+	op := "memory_actor_repo.Add"
+	s := repo.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := newActorRecord(a)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, ok := s.actorIds[rec.key()]; ok {
+		return fmt.Errorf("%s: %w", op, ErrDuplicate)
+	}
+
+	s.getOrAddActor(rec)
+	s.changed()
+	return nil
+}
+
+func (repo *ActorRepository) GetActorId(a *actor.Actor) (int64, error) {
+	op := "memory_actor_repo.GetActorId"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok, err := s.findActor(a)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", op, errNotFound)
+	}
+	return id, nil
+}
+
+// EachActor по очереди передает в fn всех актеров, отсортированных по имени.
+func (repo *ActorRepository) EachActor(fn func(a *actor.Actor) error) error {
+	op := "memory_actor_repo.EachActor"
+	s := repo.store
+
+	s.mu.RLock()
+	ids := make([]int64, 0, len(s.actors))
+	for id := range s.actors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := s.actors[ids[i]], s.actors[ids[j]]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return ids[i] < ids[j]
+	})
+	actors := make([]actor.Actor, 0, len(ids))
+	for _, id := range ids {
+		actors = append(actors, s.actors[id].actor())
+	}
+	s.mu.RUnlock()
+
+	for i := range actors {
+		if err := fn(&actors[i]); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// Update заменяет данные актера и возвращает его новую версию. Версия
+// проверяется по условию If-Match из ctx. Если данные не изменились, версия
+// остается прежней.
+func (repo *ActorRepository) Update(ctx context.Context, actorId int64, newActor *actor.Actor) (int64, error) {
+	op := "memory_actor_repo.Update"
+	s := repo.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := repo.lockActor(ctx, actorId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	before := rec.actor()
+	err = repo.replace(actorId, rec, newActor)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if rec.actor() == before {
+		return rec.version, nil
+	}
+
+	rec.version++
+	s.bumpFilms(actorId)
+	s.changed()
+	return rec.version, nil
+}
+
+// Get возвращает актера по id и его версию.
+func (repo *ActorRepository) Get(actorId int64) (*actor.Actor, int64, error) {
+	op := "memory_actor_repo.Get"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.actors[actorId]
+	if !ok {
+		return nil, 0, fmt.Errorf("%s: %w", op, errNotFound)
+	}
+	a := rec.actor()
+	return &a, rec.version, nil
+}
+
+// Patch сохраняет актера patched, полученного из текущего состояния, и
+// возвращает его новое состояние и версию. Если ничего не изменилось, версия
+// остается прежней.
+func (repo *ActorRepository) Patch(ctx context.Context, actorId int64, patched *actor.Actor) (*actor.Actor, int64, error) {
+	op := "memory_actor_repo.Patch"
+	s := repo.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := repo.lockActor(ctx, actorId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	before := rec.actor()
+	err = repo.replace(actorId, rec, patched)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	after := rec.actor()
+	if after == before {
+		return &after, rec.version, nil
+	}
+
+	rec.version++
+	s.bumpFilms(actorId)
+	s.changed()
+	return &after, rec.version, nil
+}
+
+// Delete удаляет актера. Если актер указан в фильмах, без cascade возвращается
+// *actor.ReferencedError со списком этих фильмов, а с cascade актер удаляется вместе со связями.
+func (repo *ActorRepository) Delete(ctx context.Context, actorId int64, cascade bool) error {
+	op := "memory_actor_repo.Delete"
+	s := repo.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := repo.lockActor(ctx, actorId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	filmIds := s.filmsOf(actorId)
+	if len(filmIds) > 0 && !cascade {
+		return fmt.Errorf("%s: %w", op, &actor.ReferencedError{Films: s.filmRefs(filmIds)})
+	}
+
+	s.bumpFilms(actorId)
+	for _, filmId := range filmIds {
+		delete(s.films[filmId].actors, actorId)
+		s.addRevision(ctx, filmId, film.RevisionUpdate, s.filmWithCast(filmId))
+	}
+	delete(s.actorIds, rec.key())
+	delete(s.actors, actorId)
+	s.changed()
+	return nil
+}
+
+// lockActor возвращает актера для изменения, проверив его версию по условию
+// If-Match из ctx. Вызывается под блокировкой на запись.
+func (repo *ActorRepository) lockActor(ctx context.Context, actorId int64) (*actorRecord, error) {
+	rec, ok := repo.store.actors[actorId]
+	if !ok {
+		return nil, errNotFound
+	}
+	err := pkg.CheckVersion(ctx, rec.version)
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// replace заменяет данные актера rec данными newActor.
+func (repo *ActorRepository) replace(actorId int64, rec *actorRecord, newActor *actor.Actor) error {
+	s := repo.store
+	next, err := newActorRecord(newActor)
+	if err != nil {
+		return err
+	}
+	if id, ok := s.actorIds[next.key()]; ok && id != actorId {
+		return ErrDuplicate
+	}
+
+	delete(s.actorIds, rec.key())
+	rec.name, rec.gender, rec.birthDate = next.name, next.gender, next.birthDate
+	s.actorIds[rec.key()] = actorId
+	return nil
+}
+
+// bumpFilms увеличивает версии фильмов, в которых указан актер, как
+// ActorRepository из пакета actor: актеры входят в ответ с фильмом.
+func (s *Store) bumpFilms(actorId int64) {
+	for _, filmId := range s.filmsOf(actorId) {
+		s.films[filmId].version++
+	}
+}
+
+// filmRefs возвращает фильмы filmIds, отсортированные по названию.
+func (s *Store) filmRefs(filmIds []int64) []actor.FilmRef {
+	refs := make([]actor.FilmRef, 0, len(filmIds))
+	for _, id := range filmIds {
+		rec := s.films[id]
+		refs = append(refs, actor.FilmRef{Title: rec.title, ReleaseDate: rec.releaseDate.String()})
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].Title < refs[j].Title })
+	return refs
+}
@@ -0,0 +1,452 @@
+package memory
+
+import (
+	"context"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/auth"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FilmRepository - film.Storage в памяти. Ненайденные фильмы и ревизии
+// возвращают pkg.ErrNotFound вместе с sql.ErrNoRows, как репозиторий
+// PostgreSQL.
+type FilmRepository struct {
+	store *Store
+}
+
+func NewFilmRepository(store *Store) *FilmRepository {
+	return &FilmRepository{store: store}
+}
+
+// Add добавляет фильм вместе с актерами: новые актеры создаются, а при любой
+// ошибке не сохраняется ничего.
+func (repo *FilmRepository) Add(ctx context.Context, f *film.Film) error {
+	op := "memory_film_repo.Add"
+	s := repo.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := newFilmRecord(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	cast, err := s.castRecords(f.Actors)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, ok := s.filmIds[rec.key()]; ok {
+		return fmt.Errorf("%s: %w", op, ErrDuplicate)
+	}
+
+	id := s.nextId()
+	s.films[id] = rec
+	s.filmIds[rec.key()] = id
+	s.setCast(rec, cast)
+	s.addRevision(ctx, id, film.RevisionInitial, s.filmWithCast(id))
+	s.changed()
+	return nil
+}
+
+func (repo *FilmRepository) GetFilmId(f *film.Film) (int64, error) {
+	op := "memory_film_repo.GetFilmId"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	releaseDate, err := pkg.ParseDate(f.ReleaseDate)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w: %v", op, ErrInvalid, err)
+	}
+	id, ok := s.filmIds[filmKey{title: f.Title, releaseDate: releaseDate}]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", op, errNotFound)
+	}
+	return id, nil
+}
+
+// Update полностью заменяет фильм, включая список актеров, и возвращает его новое
+// состояние и версию.
+func (repo *FilmRepository) Update(ctx context.Context, filmId int64, newFilm *film.Film) (*film.Film, int64, error) {
+	op := "memory_film_repo.Update"
+	after, version, err := repo.change(ctx, filmId, film.RevisionUpdate, func(rec *filmRecord) error {
+		return repo.replace(filmId, rec, newFilm)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return after, version, nil
+}
+
+// Patch сохраняет фильм patched, полученный из текущего состояния. Если ничего
+// не изменилось, версия остается прежней.
+func (repo *FilmRepository) Patch(ctx context.Context, filmId int64, patched *film.Film) (*film.Film, int64, error) {
+	op := "memory_film_repo.Patch"
+	after, version, err := repo.change(ctx, filmId, film.RevisionUpdate, func(rec *filmRecord) error {
+		return repo.replace(filmId, rec, patched)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return after, version, nil
+}
+
+// AddActor добавляет актера в фильм, создавая его, если такого актера еще нет.
+// Если актер уже есть в фильме, возвращается film.ErrActorInFilm.
+func (repo *FilmRepository) AddActor(ctx context.Context, filmId int64, newActor *actor.Actor) (*film.Film, int64, error) {
+	op := "memory_film_repo.AddActor"
+	s := repo.store
+	after, version, err := repo.change(ctx, filmId, film.RevisionUpdate, func(rec *filmRecord) error {
+		actorRec, err := newActorRecord(newActor)
+		if err != nil {
+			return err
+		}
+		if id, ok := s.actorIds[actorRec.key()]; ok && rec.actors[id] {
+			return film.ErrActorInFilm
+		}
+		rec.actors[s.getOrAddActor(actorRec)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return after, version, nil
+}
+
+// RemoveActor убирает актера из фильма, сам актер остается. Если актера нет в
+// фильме, возвращается film.ErrActorNotInFilm.
+func (repo *FilmRepository) RemoveActor(ctx context.Context, filmId int64, oldActor *actor.Actor) (*film.Film, int64, error) {
+	op := "memory_film_repo.RemoveActor"
+	s := repo.store
+	after, version, err := repo.change(ctx, filmId, film.RevisionUpdate, func(rec *filmRecord) error {
+		id, ok, err := s.findActor(oldActor)
+		if err != nil {
+			return err
+		}
+		if !ok || !rec.actors[id] {
+			return film.ErrActorNotInFilm
+		}
+		delete(rec.actors, id)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return after, version, nil
+}
+
+// GetFilm возвращает фильм вместе с актерами и его версию.
+func (repo *FilmRepository) GetFilm(filmId int64) (*film.Film, int64, error) {
+	op := "memory_film_repo.GetFilm"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.films[filmId]
+	if !ok {
+		return nil, 0, fmt.Errorf("%s: %w", op, errNotFound)
+	}
+	return s.filmWithCast(filmId), rec.version, nil
+}
+
+// Delete удаляет фильм. Если в фильме указаны актеры, без cascade возвращается
+// *film.ReferencedError со списком актеров, а с cascade фильм удаляется вместе со связями.
+func (repo *FilmRepository) Delete(ctx context.Context, filmId int64, cascade bool) error {
+	op := "memory_film_repo.Delete"
+	s := repo.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := repo.lockFilm(ctx, filmId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(rec.actors) > 0 && !cascade {
+		return fmt.Errorf("%s: %w", op, &film.ReferencedError{Actors: s.filmWithCast(filmId).Actors})
+	}
+
+	s.addRevision(ctx, filmId, film.RevisionDelete, s.filmWithCast(filmId))
+
+	delete(s.filmIds, rec.key())
+	delete(s.films, filmId)
+	s.changed()
+	return nil
+}
+
+func (repo *FilmRepository) GetAllFilms(sortCol string) ([]film.Film, error) {
+	op := "memory_film_repo.GetAllFilms"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, err := s.sortedFilms(sortCol)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var films []film.Film
+	for _, id := range ids {
+		films = append(films, s.films[id].film())
+	}
+	return films, nil
+}
+
+// EachFilm по очереди передает в fn все фильмы вместе с актерами. Фильмы
+// отсортированы по sortCol, актеры - по имени.
+func (repo *FilmRepository) EachFilm(sortCol string, fn func(f *film.Film) error) error {
+	op := "memory_film_repo.EachFilm"
+	s := repo.store
+
+	// fn может писать ответ клиенту, поэтому вызывается без блокировки
+	s.mu.RLock()
+	ids, err := s.sortedFilms(sortCol)
+	var films []*film.Film
+	for _, id := range ids {
+		films = append(films, s.filmWithCast(id))
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, f := range films {
+		if err := fn(f); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// FindFilms ищет фильмы по фрагменту названия, а если таких нет - по фрагменту
+// имени актера.
+func (repo *FilmRepository) FindFilms(toFind string) ([]film.Film, error) {
+	op := "memory_film_repo.FindFilms"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var films []film.Film
+	for _, id := range sortedIds(s.filmSet()) {
+		if strings.Contains(s.films[id].title, toFind) {
+			films = append(films, s.films[id].film())
+		}
+	}
+	if len(films) == 0 {
+		films = s.findFilmsByActor(toFind)
+	}
+
+	if len(films) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, film.ErrNoFilms)
+	}
+	return films, nil
+}
+
+// ActorsListWithFilms возвращает актеров с фильмами, которые находит поиск по
+// их имени, как и репозиторий PostgreSQL.
+func (repo *FilmRepository) ActorsListWithFilms() (map[actor.Actor][]film.Film, error) {
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	actorsWithFilms := make(map[actor.Actor][]film.Film)
+	for _, rec := range s.actors {
+		actorsWithFilms[rec.actor()] = s.findFilmsByActor(rec.name)
+	}
+	return actorsWithFilms, nil
+}
+
+func (repo *FilmRepository) Revisions(filmId int64) ([]film.Revision, error) {
+	op := "memory_film_repo.Revisions"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := s.revisions[filmId]
+	if _, ok := s.films[filmId]; !ok && len(revisions) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, errNotFound)
+	}
+	return append([]film.Revision{}, revisions...), nil
+}
+
+func (repo *FilmRepository) Revision(filmId int64, revision int) (*film.Revision, error) {
+	op := "memory_film_repo.Revision"
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rev, ok := s.revision(filmId, revision)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, errNotFound)
+	}
+	return &rev, nil
+}
+
+// Rollback возвращает фильм (поля и актеров) к состоянию указанной ревизии.
+// Сам откат тоже становится новой ревизией, поэтому история не теряется. Откат
+// к состоянию, совпадающему с текущим, ничего не меняет.
+func (repo *FilmRepository) Rollback(ctx context.Context, filmId int64, revision int) error {
+	op := "memory_film_repo.Rollback"
+	s := repo.store
+	_, _, err := repo.change(ctx, filmId, film.RevisionRollback, func(rec *filmRecord) error {
+		target, ok := s.revision(filmId, revision)
+		if !ok {
+			return errNotFound
+		}
+		return repo.replace(filmId, rec, &target.Film)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// CatalogVersion возвращает счетчик изменений каталога и время последнего изменения.
+func (repo *FilmRepository) CatalogVersion() (int64, time.Time, error) {
+	s := repo.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.version, s.changedAt, nil
+}
+
+// lockFilm возвращает фильм для изменения, проверив его версию по условию
+// If-Match из ctx. Вызывается под блокировкой на запись.
+func (repo *FilmRepository) lockFilm(ctx context.Context, filmId int64) (*filmRecord, error) {
+	rec, ok := repo.store.films[filmId]
+	if !ok {
+		return nil, errNotFound
+	}
+	err := pkg.CheckVersion(ctx, rec.version)
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// change выполняет изменение фильма fn и записывает ревизию. fn должна проверить
+// все данные до того, как что-то поменять, чтобы при ошибке фильм остался прежним.
+// Если фильм не изменился, ни ревизия, ни новая версия не появляются. action -
+// действие ревизии: film.RevisionUpdate или film.RevisionRollback.
+func (repo *FilmRepository) change(ctx context.Context, filmId int64, action string, fn func(rec *filmRecord) error) (*film.Film, int64, error) {
+	s := repo.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := repo.lockFilm(ctx, filmId)
+	if err != nil {
+		return nil, 0, err
+	}
+	before := s.filmWithCast(filmId)
+
+	err = fn(rec)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	after := s.filmWithCast(filmId)
+	if reflect.DeepEqual(before, after) {
+		return after, rec.version, nil
+	}
+
+	rec.version++
+	s.addRevision(ctx, filmId, action, after)
+	s.changed()
+	return after, rec.version, nil
+}
+
+// replace заменяет поля и актеров фильма rec данными newFilm.
+func (repo *FilmRepository) replace(filmId int64, rec *filmRecord, newFilm *film.Film) error {
+	s := repo.store
+	next, err := newFilmRecord(newFilm)
+	if err != nil {
+		return err
+	}
+	cast, err := s.castRecords(newFilm.Actors)
+	if err != nil {
+		return err
+	}
+	if id, ok := s.filmIds[next.key()]; ok && id != filmId {
+		return ErrDuplicate
+	}
+
+	delete(s.filmIds, rec.key())
+	rec.title, rec.description, rec.releaseDate, rec.rating = next.title, next.description, next.releaseDate, next.rating
+	s.filmIds[rec.key()] = filmId
+	s.setCast(rec, cast)
+	return nil
+}
+
+// addRevision сохраняет состояние фильма filmId очередной ревизией.
+func (s *Store) addRevision(ctx context.Context, filmId int64, action string, f *film.Film) {
+	var userId *uint32
+	if sess, err := auth.SessionFromContext(ctx); err == nil {
+		id := sess.UserID
+		userId = &id
+	}
+	s.revisions[filmId] = append(s.revisions[filmId], film.Revision{
+		Revision: len(s.revisions[filmId]) + 1,
+		Action:   action,
+		UserID:   userId,
+		Film:     *f,
+		Time:     time.Now(),
+	})
+}
+
+func (s *Store) revision(filmId int64, revision int) (film.Revision, bool) {
+	revisions := s.revisions[filmId]
+	if revision < 1 || revision > len(revisions) {
+		return film.Revision{}, false
+	}
+	return revisions[revision-1], true
+}
+
+func (s *Store) filmSet() map[int64]bool {
+	set := make(map[int64]bool, len(s.films))
+	for id := range s.films {
+		set[id] = true
+	}
+	return set
+}
+
+// sortedFilms возвращает id фильмов, отсортированных по колонке sortCol.
+func (s *Store) sortedFilms(sortCol string) ([]int64, error) {
+	if !film.IsSortColumn(sortCol) {
+		return nil, fmt.Errorf("invalid column name: %s", sortCol)
+	}
+
+	ids := sortedIds(s.filmSet())
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, b := s.films[ids[i]], s.films[ids[j]]
+		switch sortCol {
+		case "title":
+			return a.title < b.title
+		case "release_date":
+			return a.releaseDate.Time.Before(b.releaseDate.Time)
+		default:
+			return a.rating < b.rating
+		}
+	})
+	return ids, nil
+}
+
+// findFilmsByActor возвращает фильмы, в которых указан актер с именем,
+// содержащим actorName.
+func (s *Store) findFilmsByActor(actorName string) []film.Film {
+	var films []film.Film
+	for _, id := range sortedIds(s.filmSet()) {
+		rec := s.films[id]
+		for actorId := range rec.actors {
+			if strings.Contains(s.actors[actorId].name, actorName) {
+				films = append(films, rec.film())
+				break
+			}
+		}
+	}
+	return films
+}
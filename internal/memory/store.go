@@ -0,0 +1,236 @@
+// Package memory хранит фильмы и актеров в памяти процесса. FilmRepository и
+// ActorRepository работают с общим Store и соблюдают те же правила, что и
+// репозитории PostgreSQL: уникальность фильмов и актеров, связи между ними,
+// версии записей, ревизии фильмов и счетчик изменений каталога. Аудит в памяти
+// не ведется. Данные теряются при остановке сервера.
+package memory
+
+import (
+	"database/sql"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDuplicate - аналог нарушения ограничения уникальности в базе.
+	ErrDuplicate = pkg.NewError(pkg.ErrConflict, "already exists")
+	// ErrInvalid - аналог нарушения ограничения CHECK в базе.
+	ErrInvalid = pkg.NewError(pkg.ErrValidation, "violates a check constraint")
+	// errNotFound - ненайденная запись, как ее возвращает pkg.DBError.
+	errNotFound = pkg.DBError(sql.ErrNoRows)
+)
+
+// Store - общее хранилище фильмов и актеров. Все изменения выполняются под
+// одной блокировкой, поэтому каждое из них атомарно, как транзакция.
+type Store struct {
+	mu sync.RWMutex
+
+	films    map[int64]*filmRecord
+	filmIds  map[filmKey]int64
+	actors   map[int64]*actorRecord
+	actorIds map[actorKey]int64
+	// revisions хранятся отдельно от фильмов, чтобы история оставалась после удаления
+	revisions map[int64][]film.Revision
+	lastId    int64
+	version   int64
+	changedAt time.Time
+}
+
+type filmRecord struct {
+	title       string
+	description string
+	releaseDate pkg.Date
+	rating      int
+	version     int64
+	actors      map[int64]bool
+}
+
+type actorRecord struct {
+	name      string
+	gender    string
+	birthDate pkg.Date
+	version   int64
+}
+
+// filmKey и actorKey повторяют ограничения unique_title_release_date и
+// unique_actor_fields: дата сравнивается вместе с точностью и признаком
+// приблизительности, поэтому 1990 и 01.01.1990 - разные даты.
+type filmKey struct {
+	title       string
+	releaseDate pkg.Date
+}
+
+type actorKey struct {
+	name      string
+	gender    string
+	birthDate pkg.Date
+}
+
+func NewStore() *Store {
+	return &Store{
+		films:     make(map[int64]*filmRecord),
+		filmIds:   make(map[filmKey]int64),
+		actors:    make(map[int64]*actorRecord),
+		actorIds:  make(map[actorKey]int64),
+		revisions: make(map[int64][]film.Revision),
+		version:   1,
+		changedAt: time.Now(),
+	}
+}
+
+func (s *Store) nextId() int64 {
+	s.lastId++
+	return s.lastId
+}
+
+// changed отмечает изменение каталога, как триггеры catalog_version.
+func (s *Store) changed() {
+	s.version++
+	s.changedAt = time.Now()
+}
+
+func (rec *filmRecord) key() filmKey {
+	return filmKey{title: rec.title, releaseDate: rec.releaseDate}
+}
+
+func (rec *actorRecord) key() actorKey {
+	return actorKey{name: rec.name, gender: rec.gender, birthDate: rec.birthDate}
+}
+
+// newFilmRecord проверяет поля фильма так же, как колонки и ограничения таблицы film.
+func newFilmRecord(f *film.Film) (*filmRecord, error) {
+	releaseDate, err := pkg.ParseDate(f.ReleaseDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: release_date: %v", ErrInvalid, err)
+	}
+	if f.Rating < 1 || f.Rating > 10 {
+		return nil, fmt.Errorf("%w: rating %d", ErrInvalid, f.Rating)
+	}
+	return &filmRecord{
+		title:       f.Title,
+		description: f.Description,
+		releaseDate: releaseDate,
+		rating:      f.Rating,
+		version:     1,
+		actors:      make(map[int64]bool),
+	}, nil
+}
+
+// newActorRecord проверяет поля актера так же, как колонки и ограничения таблицы actor.
+func newActorRecord(a *actor.Actor) (*actorRecord, error) {
+	birthDate, err := pkg.ParseDate(a.BirthDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: birth_date: %v", ErrInvalid, err)
+	}
+	if a.Gender != "man" && a.Gender != "woman" {
+		return nil, fmt.Errorf("%w: gender %q", ErrInvalid, a.Gender)
+	}
+	return &actorRecord{
+		name:      a.Name,
+		gender:    a.Gender,
+		birthDate: birthDate,
+		version:   1,
+	}, nil
+}
+
+func (rec *actorRecord) actor() actor.Actor {
+	return actor.Actor{Name: rec.name, Gender: rec.gender, BirthDate: rec.birthDate.String()}
+}
+
+// film возвращает фильм без актеров, как его читают списки каталога.
+func (rec *filmRecord) film() film.Film {
+	return film.Film{
+		Title:       rec.title,
+		Description: rec.description,
+		ReleaseDate: rec.releaseDate.String(),
+		Rating:      rec.rating,
+	}
+}
+
+// filmWithCast возвращает фильм id вместе с актерами, отсортированными по имени.
+func (s *Store) filmWithCast(id int64) *film.Film {
+	rec := s.films[id]
+	f := rec.film()
+	for _, actorId := range sortedIds(rec.actors) {
+		f.Actors = append(f.Actors, s.actors[actorId].actor())
+	}
+	sort.SliceStable(f.Actors, func(i, j int) bool {
+		return f.Actors[i].Name < f.Actors[j].Name
+	})
+	return &f
+}
+
+// findActor ищет актера по уникальному ключу.
+func (s *Store) findActor(a *actor.Actor) (int64, bool, error) {
+	rec, err := newActorRecord(a)
+	if err != nil {
+		return 0, false, err
+	}
+	id, ok := s.actorIds[rec.key()]
+	return id, ok, nil
+}
+
+// castRecords проверяет список актеров фильма до каких-либо изменений: актер не
+// может быть указан в фильме дважды. Для актеров, которых еще нет в хранилище,
+// возвращаются новые записи без id.
+func (s *Store) castRecords(actors []actor.Actor) ([]*actorRecord, error) {
+	seen := make(map[actorKey]bool, len(actors))
+	records := make([]*actorRecord, 0, len(actors))
+	for i := range actors {
+		rec, err := newActorRecord(&actors[i])
+		if err != nil {
+			return nil, err
+		}
+		if seen[rec.key()] {
+			return nil, fmt.Errorf("%w: actor %s is in the film twice", ErrDuplicate, rec.name)
+		}
+		seen[rec.key()] = true
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// setCast заменяет актеров фильма, добавляя в хранилище новых актеров из
+// castRecords.
+func (s *Store) setCast(rec *filmRecord, cast []*actorRecord) {
+	rec.actors = make(map[int64]bool, len(cast))
+	for _, actorRec := range cast {
+		rec.actors[s.getOrAddActor(actorRec)] = true
+	}
+}
+
+func (s *Store) getOrAddActor(rec *actorRecord) int64 {
+	if id, ok := s.actorIds[rec.key()]; ok {
+		return id
+	}
+	id := s.nextId()
+	s.actors[id] = rec
+	s.actorIds[rec.key()] = id
+	return id
+}
+
+// filmsOf возвращает id фильмов, в которых указан актер actorId.
+func (s *Store) filmsOf(actorId int64) []int64 {
+	var ids []int64
+	for id, rec := range s.films {
+		if rec.actors[actorId] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func sortedIds(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,200 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// lockKey - ключ pg_advisory_lock, под которым выполняются миграции, чтобы
+// несколько одновременно запущенных экземпляров не применяли их параллельно.
+const lockKey int64 = 7_311_042_010
+
+var ErrDirty = errors.New("database is dirty: a previous migration failed, fix it manually and set schema_migrations.dirty to false")
+
+// Migrator применяет миграции и хранит текущую версию в таблице schema_migrations
+// в том же формате, что и утилита migrate, которой схема применялась раньше.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// advisoryLock - брать ли pg_advisory_lock перед применением миграций.
+	advisoryLock bool
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("migrate.NewMigrator: %w", err)
+	}
+	return &Migrator{
+		db:           db,
+		migrations:   migrations,
+		advisoryLock: true,
+	}, nil
+}
+
+// NewSQLiteMigrator создает Migrator для базы SQLite. В SQLite нет advisory lock,
+// поэтому одновременно запускать миграции одной базы из нескольких процессов нельзя.
+func NewSQLiteMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrator, err := NewMigrator(db, fsys)
+	if err != nil {
+		return nil, err
+	}
+	migrator.advisoryLock = false
+	return migrator, nil
+}
+
+// Up применяет все непримененные миграции до версии target включительно
+// (0 - до последней) и возвращает примененные.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	op := "migrate.Up"
+
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, version int64) error {
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if target != 0 && migration.Version > target {
+				break
+			}
+			err := apply(ctx, conn, migration.Up, migration.Version)
+			if err != nil {
+				return fmt.Errorf("%d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return applied, fmt.Errorf("%s: %w", op, err)
+	}
+	return applied, nil
+}
+
+// Down откатывает steps последних примененных миграций (все, если steps < 0) и возвращает откаченные.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	op := "migrate.Down"
+
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, version int64) error {
+		for i := len(m.migrations) - 1; i >= 0 && (steps < 0 || len(reverted) < steps); i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			var previous int64
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			err := apply(ctx, conn, migration.Down, previous)
+			if err != nil {
+				return fmt.Errorf("%d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return reverted, fmt.Errorf("%s: %w", op, err)
+	}
+	return reverted, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	op := "migrate.Status"
+
+	status := &Status{}
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		var err error
+		status.Version, status.Dirty, err = currentVersion(ctx, conn)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, migration := range m.migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+			Applied: migration.Version <= status.Version,
+		})
+	}
+	return status, nil
+}
+
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)")
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// withLock выполняет fn под advisory lock на одном соединении: блокировка
+// принадлежит сессии, поэтому и захват, и освобождение должны идти через него.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, version int64) error) error {
+	return m.withConn(ctx, func(conn *sql.Conn) error {
+		if m.advisoryLock {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+			if err != nil {
+				return err
+			}
+			defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+		}
+
+		version, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		return fn(conn, version)
+	})
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// apply выполняет миграцию и записывает новую версию в одной транзакции, поэтому
+// упавшая миграция не оставляет ни частично измененной схемы, ни dirty-версии.
+func apply(ctx context.Context, conn *sql.Conn, query string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	if version != 0 {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", version)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
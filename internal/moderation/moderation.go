@@ -0,0 +1,44 @@
+package moderation
+
+import (
+	"encoding/json"
+	"filmoteka/internal/auth"
+	"net/http"
+	"time"
+)
+
+const (
+	EntityFilm  = "film"
+	EntityActor = "actor"
+
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Submission - фильм или актер, добавленный обычным пользователем. В каталог он
+// попадает только после одобрения администратором.
+type Submission struct {
+	ID         int64           `json:"id"`
+	UserID     uint32          `json:"user_id"`
+	Entity     string          `json:"entity"`
+	Data       json.RawMessage `json:"data" swaggertype:"object"`
+	Status     string          `json:"status"`
+	Reason     string          `json:"reason,omitempty"`
+	ReviewedBy *uint32         `json:"reviewed_by,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ReviewedAt *time.Time      `json:"reviewed_at,omitempty"`
+}
+
+// AdminOrSubmit пропускает запросы администраторов сразу в каталог (admin),
+// а запросы остальных пользователей отправляет на модерацию (submit).
+func AdminOrSubmit(admin, submit http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess, err := auth.SessionFromContext(r.Context())
+		if err == nil && sess.IsAdmin {
+			admin(w, r)
+			return
+		}
+		submit(w, r)
+	}
+}
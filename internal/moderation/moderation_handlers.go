@@ -0,0 +1,368 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/auth"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type Storage interface {
+	Add(submission *Submission) (int64, error)
+	Get(submissionId int64) (*Submission, error)
+	FindByUser(userId uint32) ([]Submission, error)
+	FindByStatus(status string) ([]Submission, error)
+	Review(submissionId int64, status string, data json.RawMessage, reviewerId uint32, reason string) error
+	Approve(ctx context.Context, submissionId int64, data json.RawMessage, reviewerId uint32) error
+}
+
+type ModerationHandler struct {
+	ModerationRepo Storage
+	FilmRepo       film.Storage
+	ActorRepo      actor.Storage
+}
+
+// SubmitFilm сохраняет фильм от пользователя без прав администратора как заявку.
+// Документация в swagger описана вместе с film.FilmHandler.AddFilm, так как маршрут общий.
+func (h *ModerationHandler) SubmitFilm(w http.ResponseWriter, r *http.Request) {
+	var newFilm film.Film
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&newFilm)
+	if err != nil {
+		log.Println("error decoding request JSON:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
+		return
+	}
+
+	defer pkg.CloseBody(r)
+
+	if errs := pkg.Validate(&newFilm); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return
+	}
+
+	h.submit(w, r, EntityFilm, &newFilm)
+}
+
+// SubmitActor сохраняет актера от пользователя без прав администратора как заявку.
+// Документация в swagger описана вместе с actor.ActorHandler.AddActor, так как маршрут общий.
+func (h *ModerationHandler) SubmitActor(w http.ResponseWriter, r *http.Request) {
+	var newActor actor.Actor
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&newActor)
+	if err != nil {
+		log.Println("error decoding request JSON:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
+		return
+	}
+
+	defer pkg.CloseBody(r)
+
+	if errs := pkg.Validate(&newActor); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return
+	}
+
+	h.submit(w, r, EntityActor, &newActor)
+}
+
+func (h *ModerationHandler) submit(w http.ResponseWriter, r *http.Request, entity string, data interface{}) {
+	sess, err := auth.SessionFromContext(r.Context())
+	if err != nil {
+		pkg.WriteProblem(w, r, http.StatusUnauthorized, "No auth")
+		return
+	}
+
+	submission := &Submission{
+		UserID: sess.UserID,
+		Entity: entity,
+	}
+	submission.Data, err = json.Marshal(data)
+	if err != nil {
+		log.Println("error marshalling submission:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal submission")
+		return
+	}
+
+	submissionId, err := h.ModerationRepo.Add(submission)
+	if err != nil {
+		log.Println("error adding submission:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't add submission")
+		return
+	}
+
+	log.Println(entity, "submitted for moderation:", submissionId)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(fmt.Sprintf("%s submitted for moderation: submission %d", entity, submissionId)))
+}
+
+// @Summary Получает заявки текущего пользователя
+// @Description Возвращает все заявки текущего пользователя с их статусом (pending, approved, rejected) и причиной отказа.
+// @Produce json
+// @Success 200 {array} Submission "Заявки пользователя"
+// @Failure 401 {object} pkg.Problem "No auth"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /user/submissions [get]
+func (h *ModerationHandler) MySubmissions(w http.ResponseWriter, r *http.Request) {
+	sess, err := auth.SessionFromContext(r.Context())
+	if err != nil {
+		pkg.WriteProblem(w, r, http.StatusUnauthorized, "No auth")
+		return
+	}
+
+	submissions, err := h.ModerationRepo.FindByUser(sess.UserID)
+	if err != nil {
+		log.Println("error getting user submissions:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't get submissions")
+		return
+	}
+
+	writeSubmissions(w, r, submissions)
+}
+
+// @Summary Получает очередь модерации
+// @Description Возвращает заявки с указанным статусом, по умолчанию ожидающие модерации.
+// @Produce json
+// @Param status query string false "Статус заявок (pending, approved, rejected)"
+// @Success 200 {array} Submission "Заявки"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/moderation/queue [get]
+func (h *ModerationHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = StatusPending
+	}
+	if status != StatusPending && status != StatusApproved && status != StatusRejected {
+		log.Println("error getting moderation queue: wrong status")
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong status: it must be empty, pending, approved or rejected")
+		return
+	}
+
+	submissions, err := h.ModerationRepo.FindByStatus(status)
+	if err != nil {
+		log.Println("error getting moderation queue:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't get submissions")
+		return
+	}
+
+	writeSubmissions(w, r, submissions)
+}
+
+// @Summary Одобряет заявку
+// @Description Добавляет фильм или актера из заявки в каталог. Если передано тело запроса, оно заменяет данные заявки (правка перед одобрением). Запись в каталоге и статус заявки сохраняются в одной транзакции, поэтому заявка не может быть одобрена дважды.
+// @Accept json
+// @Produce json
+// @Param id query int true "Id заявки"
+// @Param data body object false "Исправленные данные фильма или актера"
+// @Success 200 {string} string "submission approved"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Submission not found"
+// @Failure 409 {object} pkg.Problem "Submission already reviewed"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/moderation/approve [post]
+func (h *ModerationHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	submission, reviewer, ok := h.pendingSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	var edited json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&edited)
+	if err != nil && err != io.EOF {
+		log.Println("error decoding request JSON:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode request JSON")
+		return
+	}
+
+	defer pkg.CloseBody(r)
+
+	data := submission.Data
+	if len(edited) > 0 {
+		data = edited
+	}
+
+	switch submission.Entity {
+	case EntityFilm:
+		data, ok = h.checkFilm(w, r, data)
+	case EntityActor:
+		data, ok = h.checkActor(w, r, data)
+	default:
+		log.Println("error approving submission: unknown entity", submission.Entity)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "unknown submission entity")
+		return
+	}
+	if !ok {
+		return
+	}
+
+	err = h.ModerationRepo.Approve(r.Context(), submission.ID, data, reviewer)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't approve submission")
+		return
+	}
+
+	log.Println("submission approved:", submission.ID)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("submission approved: %d", submission.ID)))
+}
+
+// @Summary Отклоняет заявку
+// @Description Отклоняет заявку с указанием причины, которую увидит автор заявки.
+// @Produce json
+// @Param id query int true "Id заявки"
+// @Param reason query string true "Причина отказа"
+// @Success 200 {string} string "submission rejected"
+// @Failure 400 {object} pkg.Problem "Bad request"
+// @Failure 404 {object} pkg.Problem "Submission not found"
+// @Failure 409 {object} pkg.Problem "Submission already reviewed"
+// @Failure 500 {object} pkg.Problem "Internal server error"
+// @Router /admin/moderation/reject [post]
+func (h *ModerationHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	reason := r.FormValue("reason")
+	if reason == "" {
+		log.Println("error rejecting submission: empty reason")
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "empty reason")
+		return
+	}
+
+	submission, reviewer, ok := h.pendingSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.ModerationRepo.Review(submission.ID, StatusRejected, submission.Data, reviewer, reason)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't reject submission")
+		return
+	}
+
+	log.Println("submission rejected:", submission.ID)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("submission rejected: %d", submission.ID)))
+}
+
+// pendingSubmission находит заявку по параметру id и проверяет, что она еще не
+// рассмотрена. При ошибке ответ уже записан в w.
+func (h *ModerationHandler) pendingSubmission(w http.ResponseWriter, r *http.Request) (*Submission, uint32, bool) {
+	sess, err := auth.SessionFromContext(r.Context())
+	if err != nil {
+		pkg.WriteProblem(w, r, http.StatusUnauthorized, "No admin auth")
+		return nil, 0, false
+	}
+
+	submissionId, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		log.Println("error getting submission: wrong id", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "wrong id: it must be a number")
+		return nil, 0, false
+	}
+
+	submission, err := h.ModerationRepo.Get(submissionId)
+	if err != nil {
+		pkg.WriteError(w, r, err, "can't get submission")
+		return nil, 0, false
+	}
+
+	if submission.Status != StatusPending {
+		log.Println("error reviewing submission: already", submission.Status)
+		pkg.WriteProblem(w, r, http.StatusConflict, "submission already reviewed")
+		return nil, 0, false
+	}
+
+	return submission, sess.UserID, true
+}
+
+// checkFilm проверяет фильм из заявки и возвращает данные, которые попадут в
+// каталог. Сам фильм добавляет ModerationRepo.Approve. При ошибке ответ уже
+// записан в w.
+func (h *ModerationHandler) checkFilm(w http.ResponseWriter, r *http.Request, data json.RawMessage) (json.RawMessage, bool) {
+	var newFilm film.Film
+	err := json.Unmarshal(data, &newFilm)
+	if err != nil {
+		log.Println("error decoding film:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode film")
+		return nil, false
+	}
+
+	if errs := pkg.Validate(&newFilm); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return nil, false
+	}
+
+	_, err = h.FilmRepo.GetFilmId(&newFilm)
+	if err == nil {
+		log.Println("error approving film: film already exists")
+		pkg.WriteProblem(w, r, http.StatusConflict, "film already exists")
+		return nil, false
+	}
+	if !errors.Is(err, pkg.ErrNotFound) {
+		pkg.WriteError(w, r, err, "can't add film")
+		return nil, false
+	}
+
+	data, err = json.Marshal(newFilm)
+	if err != nil {
+		log.Println("error marshalling film:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal film")
+		return nil, false
+	}
+	return data, true
+}
+
+// checkActor проверяет актера из заявки так же, как checkFilm.
+func (h *ModerationHandler) checkActor(w http.ResponseWriter, r *http.Request, data json.RawMessage) (json.RawMessage, bool) {
+	var newActor actor.Actor
+	err := json.Unmarshal(data, &newActor)
+	if err != nil {
+		log.Println("error decoding actor:", err)
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "can't decode actor")
+		return nil, false
+	}
+
+	if errs := pkg.Validate(&newActor); len(errs) > 0 {
+		pkg.WriteValidationProblem(w, r, errs)
+		return nil, false
+	}
+
+	_, err = h.ActorRepo.GetActorId(&newActor)
+	if err == nil {
+		log.Println("error approving actor: actor already exists")
+		pkg.WriteProblem(w, r, http.StatusConflict, "actor already exists")
+		return nil, false
+	}
+	if !errors.Is(err, pkg.ErrNotFound) {
+		pkg.WriteError(w, r, err, "can't add actor")
+		return nil, false
+	}
+
+	data, err = json.Marshal(newActor)
+	if err != nil {
+		log.Println("error marshalling actor:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal actor")
+		return nil, false
+	}
+	return data, true
+}
+
+func writeSubmissions(w http.ResponseWriter, r *http.Request, submissions []Submission) {
+	resp, err := json.Marshal(submissions)
+	if err != nil {
+		log.Println("error marshalling submissions:", err)
+		pkg.WriteProblem(w, r, http.StatusInternalServerError, "can't marshal submissions")
+		return
+	}
+
+	pkg.WriteJSON(w, http.StatusOK, resp)
+}
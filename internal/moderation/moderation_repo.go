@@ -0,0 +1,214 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"fmt"
+)
+
+var ErrAlreadyReviewed = pkg.NewError(pkg.ErrConflict, "submission already reviewed")
+
+type ModerationRepository struct {
+	db        *sql.DB
+	actorRepo *actor.ActorRepository
+}
+
+func NewModerationRepository(db *sql.DB) *ModerationRepository {
+	return &ModerationRepository{
+		db:        db,
+		actorRepo: actor.NewActorRepository(db),
+	}
+}
+
+func (repo *ModerationRepository) Add(submission *Submission) (int64, error) {
+	op := "moderation_repo.Add"
+
+	row := repo.db.QueryRow("INSERT INTO submission(user_id, entity, data) VALUES($1, $2, $3) RETURNING id",
+		submission.UserID, submission.Entity, string(submission.Data))
+
+	var submissionId int64
+	err := row.Scan(&submissionId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return submissionId, nil
+}
+
+func (repo *ModerationRepository) Get(submissionId int64) (*Submission, error) {
+	op := "moderation_repo.Get"
+
+	row := repo.db.QueryRow(`SELECT id, user_id, entity, data, status, reason, reviewed_by, created_at, reviewed_at
+		FROM submission WHERE id = $1`, submissionId)
+	submission, err := scanSubmission(row)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return submission, nil
+}
+
+func (repo *ModerationRepository) FindByUser(userId uint32) ([]Submission, error) {
+	op := "moderation_repo.FindByUser"
+
+	rows, err := repo.db.Query(`SELECT id, user_id, entity, data, status, reason, reviewed_by, created_at, reviewed_at
+		FROM submission WHERE user_id = $1 ORDER BY id DESC`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	submissions, err := scanSubmissions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return submissions, nil
+}
+
+func (repo *ModerationRepository) FindByStatus(status string) ([]Submission, error) {
+	op := "moderation_repo.FindByStatus"
+
+	rows, err := repo.db.Query(`SELECT id, user_id, entity, data, status, reason, reviewed_by, created_at, reviewed_at
+		FROM submission WHERE status = $1 ORDER BY id`, status)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer rows.Close()
+
+	submissions, err := scanSubmissions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return submissions, nil
+}
+
+// Review переводит заявку из pending в status. Данные заявки заменяются на data,
+// чтобы в истории осталось то, что в итоге попало в каталог после правок администратора.
+func (repo *ModerationRepository) Review(submissionId int64, status string, data json.RawMessage, reviewerId uint32, reason string) error {
+	op := "moderation_repo.Review"
+
+	err := review(repo.db, submissionId, status, data, reviewerId, reason)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Approve добавляет фильм или актера из заявки в каталог и одобряет заявку в
+// одной транзакции. Строка заявки блокируется до конца транзакции, поэтому из
+// двух одновременных одобрений запись в каталог добавит только первое, а второе
+// получит ErrAlreadyReviewed.
+func (repo *ModerationRepository) Approve(ctx context.Context, submissionId int64, data json.RawMessage, reviewerId uint32) error {
+	op := "moderation_repo.Approve"
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	defer tx.Rollback()
+
+	var entity string
+	err = tx.QueryRow("SELECT entity FROM submission WHERE id = $1 AND status = $2 FOR UPDATE", submissionId, StatusPending).
+		Scan(&entity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, ErrAlreadyReviewed)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	switch entity {
+	case EntityFilm:
+		var newFilm film.Film
+		if err := json.Unmarshal(data, &newFilm); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		_, err = film.Insert(ctx, tx, repo.actorRepo, &newFilm)
+	case EntityActor:
+		var newActor actor.Actor
+		if err := json.Unmarshal(data, &newActor); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		err = repo.actorRepo.WithTx(tx).Add(&newActor)
+	default:
+		return fmt.Errorf("%s: unknown submission entity %s", op, entity)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+
+	err = review(tx, submissionId, StatusApproved, data, reviewerId, "")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, pkg.DBError(err))
+	}
+	return nil
+}
+
+func review(q actor.Querier, submissionId int64, status string, data json.RawMessage, reviewerId uint32, reason string) error {
+	result, err := q.Exec(`UPDATE submission SET status = $1, data = $2, reviewed_by = $3, reason = $4, reviewed_at = now()
+		WHERE id = $5 AND status = $6`,
+		status, string(data), reviewerId, reason, submissionId, StatusPending)
+	if err != nil {
+		return pkg.DBError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return pkg.DBError(err)
+	}
+	if affected == 0 {
+		return ErrAlreadyReviewed
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubmission(row scanner) (*Submission, error) {
+	var submission Submission
+	var data []byte
+	var reason sql.NullString
+	var reviewedBy sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&submission.ID, &submission.UserID, &submission.Entity, &data, &submission.Status,
+		&reason, &reviewedBy, &submission.CreatedAt, &reviewedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	submission.Data = data
+	submission.Reason = reason.String
+	if reviewedBy.Valid {
+		id := uint32(reviewedBy.Int64)
+		submission.ReviewedBy = &id
+	}
+	if reviewedAt.Valid {
+		submission.ReviewedAt = &reviewedAt.Time
+	}
+	return &submission, nil
+}
+
+func scanSubmissions(rows *sql.Rows) ([]Submission, error) {
+	var submissions []Submission
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, *submission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
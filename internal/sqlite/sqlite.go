@@ -0,0 +1,296 @@
+// Package sqlite открывает базу SQLite так, чтобы с ней работали те же
+// репозитории, что и с PostgreSQL. Драйвер написан на чистом Go, поэтому сервер
+// с SQLite собирается в один бинарник без внешних зависимостей.
+//
+// Запросы репозиториев переводятся на диалект SQLite: FOR UPDATE убирается (при
+// _txlock=immediate транзакция сразу блокирует всю базу на запись, поэтому
+// строки и так не изменятся до ее конца), EXTRACT(YEAR FROM x) заменяется на
+// strftime, а функции partial_date и now, которых нет в SQLite, регистрируются
+// в драйвере. Строки в кавычках и комментарии не переписываются.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"filmoteka/pkg"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Scheme - префикс строки подключения к SQLite: sqlite:путь/к/файлу.db.
+const Scheme = "sqlite:"
+
+const driverName = "filmoteka-sqlite"
+
+// timeFormat - формат времени в колонках TIMESTAMP, как у CURRENT_TIMESTAMP,
+// но с долями секунды.
+const timeFormat = "2006-01-02 15:04:05.999999999"
+
+// pragmas включают внешние ключи, регистрозависимый LIKE (как в PostgreSQL) и
+// ожидание блокировки вместо немедленной ошибки SQLITE_BUSY.
+var pragmas = []string{
+	"foreign_keys(1)",
+	"case_sensitive_like(1)",
+	"busy_timeout(5000)",
+	"journal_mode(WAL)",
+}
+
+var (
+	forUpdate   = regexp.MustCompile(`(?i)^FOR\s+UPDATE\b`)
+	extractYear = regexp.MustCompile(`(?i)^EXTRACT\s*\(\s*YEAR\s+FROM\s`)
+)
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("partial_date", 3, partialDate)
+	sqlite.MustRegisterScalarFunction("now", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(timeFormat), nil
+	})
+	// функции регистрируются в общем драйвере пакета modernc.org/sqlite,
+	// поэтому запросы передаются ему, а не новому sqlite.Driver
+	db, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register(driverName, dialectDriver{db.Driver()})
+}
+
+// MemoryDSN - строка подключения к временной базе в памяти процесса.
+const MemoryDSN = Scheme + ":memory:"
+
+// IsDSN сообщает, указывает ли строка подключения на базу SQLite.
+func IsDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, Scheme)
+}
+
+// Open открывает базу по строке подключения sqlite:путь[?параметры]. Параметры
+// передаются драйверу modernc.org/sqlite, к ним добавляются pragmas и
+// _txlock=immediate.
+func Open(dsn string) (*sql.DB, error) {
+	op := "sqlite.Open"
+	path, query, _ := strings.Cut(strings.TrimPrefix(dsn, Scheme), "?")
+	if path == "" {
+		return nil, fmt.Errorf("%s: empty database path in %q", op, dsn)
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, pragma := range pragmas {
+		params.Add("_pragma", pragma)
+	}
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+
+	db, err := sql.Open(driverName, path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if path == ":memory:" {
+		// у каждого соединения своя база в памяти, поэтому соединение одно
+		db.SetMaxOpenConns(1)
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return db, nil
+}
+
+// Rewrite переводит запрос с диалекта PostgreSQL на диалект SQLite.
+func Rewrite(query string) string {
+	var result strings.Builder
+	// quoted - длина result до конца последней строки или комментария, их
+	// пробелы не удаляются вместе с FOR UPDATE
+	quoted := 0
+	for i := 0; i < len(query); {
+		if n := skipQuoted(query[i:]); n > 0 {
+			result.WriteString(query[i : i+n])
+			quoted = result.Len()
+			i += n
+			continue
+		}
+		if !isWordStart(query, i) {
+			result.WriteByte(query[i])
+			i++
+			continue
+		}
+
+		if match := forUpdate.FindString(query[i:]); match != "" {
+			written := result.String()
+			result.Reset()
+			result.WriteString(written[:quoted])
+			result.WriteString(strings.TrimRight(written[quoted:], " \t\r\n"))
+			i += len(match)
+			continue
+		}
+		if match := extractYear.FindString(query[i:]); match != "" {
+			if n := closingParen(query[i+len(match):]); n >= 0 {
+				arg := strings.TrimSpace(query[i+len(match) : i+len(match)+n])
+				result.WriteString("CAST(strftime('%Y', " + Rewrite(arg) + ") AS INTEGER)")
+				i += len(match) + n + 1
+				continue
+			}
+		}
+		result.WriteByte(query[i])
+		i++
+	}
+	return result.String()
+}
+
+// skipQuoted возвращает длину строки в одинарных кавычках, идентификатора в
+// двойных кавычках или комментария в начале query и 0, если query начинается
+// не с них. Незакрытые кавычки и комментарии продолжаются до конца запроса.
+func skipQuoted(query string) int {
+	switch {
+	case strings.HasPrefix(query, "'"), strings.HasPrefix(query, `"`):
+		quote := query[0]
+		for i := 1; i < len(query); i++ {
+			if query[i] != quote {
+				continue
+			}
+			// удвоенная кавычка внутри строки
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+		return len(query)
+	case strings.HasPrefix(query, "--"):
+		if end := strings.IndexByte(query, '\n'); end >= 0 {
+			return end + 1
+		}
+		return len(query)
+	case strings.HasPrefix(query, "/*"):
+		if end := strings.Index(query[2:], "*/"); end >= 0 {
+			return end + 4
+		}
+		return len(query)
+	}
+	return 0
+}
+
+// closingParen возвращает позицию скобки, закрывающей уже открытую, или -1.
+// Скобки в строках и комментариях не учитываются.
+func closingParen(query string) int {
+	depth := 0
+	for i := 0; i < len(query); {
+		if n := skipQuoted(query[i:]); n > 0 {
+			i += n
+			continue
+		}
+		switch query[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+		i++
+	}
+	return -1
+}
+
+// isWordStart сообщает, начинается ли в позиции i query новое слово.
+func isWordStart(query string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	c := query[i-1]
+	return !(c == '_' || c == '$' || c == '.' || c >= 0x80 || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z')
+}
+
+// partialDate - аналог SQL-функции partial_date из миграций PostgreSQL: дата с
+// ее точностью в формате EDTF (1920, 1920-05, 1920-05-17, 1920~).
+func partialDate(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+
+	var date time.Time
+	switch value := args[0].(type) {
+	case time.Time:
+		date = value
+	case string:
+		var err error
+		date, err = time.Parse(pkg.DateFormatISO, value[:min(len(value), len(pkg.DateFormatISO))])
+		if err != nil {
+			return nil, fmt.Errorf("partial_date: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("partial_date: unexpected date %v", value)
+	}
+
+	layout := pkg.DateFormatISO
+	switch args[1] {
+	case pkg.PrecisionYear:
+		layout = "2006"
+	case pkg.PrecisionMonth:
+		layout = "2006-01"
+	}
+
+	result := date.Format(layout)
+	if approximate, _ := args[2].(int64); approximate != 0 {
+		result += "~"
+	}
+	return result, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type conn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+}
+
+// dialectDriver и dialectConn переписывают запросы функцией Rewrite перед тем,
+// как передать их драйверу SQLite.
+type dialectDriver struct {
+	driver driver.Driver
+}
+
+func (d dialectDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return dialectConn{c.(conn)}, nil
+}
+
+type dialectConn struct {
+	conn
+}
+
+func (c dialectConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(Rewrite(query))
+}
+
+func (c dialectConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.PrepareContext(ctx, Rewrite(query))
+}
+
+func (c dialectConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.ExecContext(ctx, Rewrite(query), args)
+}
+
+func (c dialectConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.QueryContext(ctx, Rewrite(query), args)
+}
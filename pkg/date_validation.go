@@ -1,14 +1,177 @@
 package pkg
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
+const (
+	// DateFormatLegacy - исторический формат дат API (дд.мм.гггг).
+	DateFormatLegacy = "02.01.2006"
+	// DateFormatISO - формат ISO 8601 (гггг-мм-дд), в нем же даты передаются в базу.
+	DateFormatISO = "2006-01-02"
+
+	PrecisionDay   = "day"
+	PrecisionMonth = "month"
+	PrecisionYear  = "year"
+
+	// approximateMark - суффикс приблизительной даты, как в EDTF (ISO 8601-2): "1920~".
+	approximateMark = "~"
+)
+
+// Date - дата, известная с точностью до дня, месяца или года, возможно приблизительная.
+// Time - первый день периода, поэтому даты разной точности сортируются хронологически.
+type Date struct {
+	Time        time.Time
+	Precision   string
+	Approximate bool
+}
+
+type dateLayouts struct {
+	day, month, year string
+}
+
+var (
+	isoLayouts    = dateLayouts{day: DateFormatISO, month: "2006-01", year: "2006"}
+	legacyLayouts = dateLayouts{day: DateFormatLegacy, month: "01.2006", year: "2006"}
+)
+
+// dateOutputFormat - формат, в котором даты отдаются клиентам. Задается при
+// запуске через SetDateFormat и дальше не меняется.
+var dateOutputFormat = legacyLayouts
+
+// SetDateFormat выбирает формат дат в ответах: legacy (по умолчанию) или iso.
+func SetDateFormat(name string) error {
+	switch name {
+	case "", "legacy":
+		dateOutputFormat = legacyLayouts
+	case "iso":
+		dateOutputFormat = isoLayouts
+	default:
+		return fmt.Errorf("unknown date format %q: it must be legacy or iso", name)
+	}
+	return nil
+}
+
+// ParseDate разбирает дату в формате ISO 8601 (гггг-мм-дд, гггг-мм, гггг) или
+// дд.мм.гггг (мм.гггг). Суффикс ~ помечает дату как приблизительную.
+func ParseDate(s string) (Date, error) {
+	value := strings.TrimSuffix(s, approximateMark)
+	for _, layouts := range []dateLayouts{isoLayouts, legacyLayouts} {
+		for _, layout := range []struct{ layout, precision string }{
+			{layouts.day, PrecisionDay},
+			{layouts.month, PrecisionMonth},
+			{layouts.year, PrecisionYear},
+		} {
+			date, err := time.Parse(layout.layout, value)
+			if err == nil {
+				return Date{Time: date, Precision: layout.precision, Approximate: value != s}, nil
+			}
+		}
+	}
+	return Date{}, fmt.Errorf("%s is not a valid date", s)
+}
+
+// String форматирует дату в формате ответов API с ее точностью.
+func (date Date) String() string {
+	return date.format(dateOutputFormat)
+}
+
+func (date Date) format(layouts dateLayouts) string {
+	layout := layouts.day
+	switch date.Precision {
+	case PrecisionMonth:
+		layout = layouts.month
+	case PrecisionYear:
+		layout = layouts.year
+	}
+
+	s := date.Time.Format(layout)
+	if date.Approximate {
+		s += approximateMark
+	}
+	return s
+}
+
 func DateValidation(s string) error {
-	_, err := time.Parse("02.01.2006", s)
+	_, err := ParseDate(s)
+	return err
+}
+
+func FormatDate(date time.Time) string {
+	return Date{Time: date, Precision: PrecisionDay}.String()
+}
+
+// DBDate переводит дату из запроса в ISO 8601 для передачи в колонку DATE: для
+// неполной даты это первый день периода. Непонятная строка возвращается как есть,
+// чтобы ошибку вернула база.
+func DBDate(s string) string {
+	date, err := ParseDate(s)
+	if err != nil {
+		return s
+	}
+	return date.Time.Format(DateFormatISO)
+}
+
+// DBPrecision возвращает точность даты для колонки *_precision.
+func DBPrecision(s string) string {
+	date, err := ParseDate(s)
+	if err != nil {
+		return PrecisionDay
+	}
+	return date.Precision
+}
+
+// DBApproximate возвращает признак приблизительной даты для колонки *_approx.
+func DBApproximate(s string) bool {
+	date, err := ParseDate(s)
+	return err == nil && date.Approximate
+}
+
+// SameDate сообщает, обозначают ли строки a и b одну и ту же дату с той же точностью,
+// даже если они записаны в разных форматах.
+func SameDate(a, b string) bool {
+	dateA, errA := ParseDate(a)
+	dateB, errB := ParseDate(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return dateA.Time.Equal(dateB.Time) && dateA.Precision == dateB.Precision && dateA.Approximate == dateB.Approximate
+}
+
+// ScanDate позволяет читать дату (в том числе NULL) сразу в строку в формате
+// ответов API. Колонка может быть DATE или текстом partial_date(...) из базы.
+func ScanDate(dst *string) sql.Scanner {
+	return dateScanner{dst: dst}
+}
+
+type dateScanner struct {
+	dst *string
+}
+
+func (s dateScanner) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*s.dst = ""
+	case time.Time:
+		*s.dst = FormatDate(value)
+	case string:
+		return s.scanString(value)
+	case []byte:
+		return s.scanString(string(value))
+	default:
+		return fmt.Errorf("can't scan %T into date", src)
+	}
+	return nil
+}
+
+func (s dateScanner) scanString(value string) error {
+	date, err := ParseDate(value)
 	if err != nil {
-		return fmt.Errorf("%s is not a valid date\n", s)
+		return err
 	}
+	*s.dst = date.String()
 	return nil
 }
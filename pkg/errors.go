@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+// Виды ошибок хранилищ. Обработчики выбирают по ним код ответа через
+// HTTPStatus и WriteError, не разбирая ошибки конкретной базы.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+// Error - ошибка хранилища одного из видов ErrNotFound, ErrConflict или
+// ErrValidation. Reason объясняет ее клиенту, а Err хранит исходную ошибку,
+// поэтому errors.Is находит и вид, и, например, sql.ErrNoRows.
+type Error struct {
+	Kind   error
+	Reason string
+	Err    error
+}
+
+// NewError создает ошибку вида kind без исходной ошибки, например для проверок
+// на стороне хранилища.
+func NewError(kind error, reason string) error {
+	return &Error{Kind: kind, Reason: reason}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Reason
+	}
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// коды ошибок PostgreSQL: https://www.postgresql.org/docs/current/errcodes-appendix.html
+var pqKinds = map[pq.ErrorCode]*Error{
+	"23505": {Kind: ErrConflict, Reason: "already exists"},
+	"23503": {Kind: ErrConflict, Reason: "conflicts with related records"},
+	"23514": {Kind: ErrValidation, Reason: "violates a check constraint"},
+	"23502": {Kind: ErrValidation, Reason: "required value is missing"},
+	"22007": {Kind: ErrValidation, Reason: "wrong date format"},
+	"22008": {Kind: ErrValidation, Reason: "date is out of range"},
+	"22001": {Kind: ErrValidation, Reason: "value is too long"},
+}
+
+// расширенные коды ошибок SQLite: https://www.sqlite.org/rescode.html
+var sqliteKinds = map[int]*Error{
+	2067: {Kind: ErrConflict, Reason: "already exists"},                 // SQLITE_CONSTRAINT_UNIQUE
+	1555: {Kind: ErrConflict, Reason: "already exists"},                 // SQLITE_CONSTRAINT_PRIMARYKEY
+	787:  {Kind: ErrConflict, Reason: "conflicts with related records"}, // SQLITE_CONSTRAINT_FOREIGNKEY
+	275:  {Kind: ErrValidation, Reason: "violates a check constraint"},  // SQLITE_CONSTRAINT_CHECK
+	1299: {Kind: ErrValidation, Reason: "required value is missing"},    // SQLITE_CONSTRAINT_NOTNULL
+}
+
+// DBError переводит ошибку базы в *Error: sql.ErrNoRows - в ErrNotFound,
+// нарушения уникальности и внешних ключей - в ErrConflict, нарушения проверок и
+// неверные значения - в ErrValidation. Остальные ошибки и уже переведенные
+// возвращаются без изменений.
+func DBError(err error) error {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) || errors.Is(err, ErrValidation) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Reason: "not found", Err: err}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if kind, ok := pqKinds[pqErr.Code]; ok {
+			return &Error{Kind: kind.Kind, Reason: kind.Reason, Err: err}
+		}
+		return err
+	}
+
+	// ошибки modernc.org/sqlite, без зависимости от драйвера
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		if kind, ok := sqliteKinds[sqliteErr.Code()]; ok {
+			return &Error{Kind: kind.Kind, Reason: kind.Reason, Err: err}
+		}
+	}
+	return err
+}
+
+// HTTPStatus возвращает код ответа для ошибки хранилища: 404, 409, 422, 412
+// для ErrPreconditionFailed и 500 для остальных ошибок.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WriteError записывает в лог и отвечает на ошибку хранилища кодом из
+// HTTPStatus. К тексту msg в detail добавляется причина из *Error; о
+// внутренних ошибках клиент получает только msg.
+func WriteError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	if PreconditionFailed(w, r, err) {
+		return
+	}
+
+	log.Println(msg+":", err)
+	status := HTTPStatus(err)
+	var domainErr *Error
+	if status != http.StatusInternalServerError && errors.As(err, &domainErr) {
+		msg += ": " + domainErr.Reason
+	}
+	WriteProblem(w, r, status, msg)
+}
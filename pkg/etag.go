@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrPreconditionFailed возвращается, когда версия записи не совпала с If-Match.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+type ifMatchKey struct{}
+
+// ETag возвращает сильный ETag для версии записи.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// WeakETag возвращает слабый ETag для версии: одна версия может отдаваться
+// побайтно разными ответами (например, в другом порядке), но с тем же смыслом.
+func WeakETag(version int64) string {
+	return "W/" + ETag(version)
+}
+
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// WithIfMatch добавляет в контекст условие в формате заголовка If-Match.
+// Пустое условие контекст не меняет, и запись изменяется без проверки версии.
+func WithIfMatch(ctx context.Context, ifMatch string) context.Context {
+	if strings.TrimSpace(ifMatch) == "" {
+		return ctx
+	}
+	var tags []string
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tags = append(tags, strings.TrimSpace(tag))
+	}
+	return context.WithValue(ctx, ifMatchKey{}, tags)
+}
+
+// IfMatch возвращает контекст запроса с условием из его заголовка If-Match.
+func IfMatch(r *http.Request) context.Context {
+	return WithIfMatch(r.Context(), r.Header.Get("If-Match"))
+}
+
+// CheckVersion проверяет версию записи по условию If-Match из ctx. Слабые
+// ETag (W/"...") по RFC 9110 в If-Match не совпадают ни с чем.
+func CheckVersion(ctx context.Context, version int64) error {
+	tags, ok := ctx.Value(ifMatchKey{}).([]string)
+	if !ok {
+		return nil
+	}
+	for _, tag := range tags {
+		if tag == "*" || tag == ETag(version) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: current version is %s", ErrPreconditionFailed, ETag(version))
+}
+
+// BumpVersion увеличивает версию строки id в таблице table и проверяет прежнюю
+// версию по условию из ctx. Строка остается заблокированной до конца транзакции,
+// поэтому проверка и изменение атомарны. Возвращает новую версию.
+func BumpVersion(ctx context.Context, tx *sql.Tx, table string, id int64) (int64, error) {
+	var version int64
+	err := tx.QueryRow(fmt.Sprintf("UPDATE %s SET version = version + 1 WHERE id = $1 RETURNING version", table), id).
+		Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, CheckVersion(ctx, version-1)
+}
+
+// PreconditionFailed отвечает 412, если err вызвана несовпадением версии.
+func PreconditionFailed(w http.ResponseWriter, r *http.Request, err error) bool {
+	if !errors.Is(err, ErrPreconditionFailed) {
+		return false
+	}
+	log.Println("precondition failed:", err)
+	WriteProblem(w, r, http.StatusPreconditionFailed, "resource was modified, reload it and retry")
+	return true
+}
+
+// NotModified выставляет ответу на чтение заголовки кэширования и проверяет
+// условия If-None-Match и If-Modified-Since (If-Modified-Since учитывается, только
+// если нет If-None-Match). Если копия клиента актуальна, отвечает 304 и
+// возвращает true. Нулевое modified означает, что время изменения неизвестно.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	// клиент может хранить ответ, но должен проверять его перед каждым использованием
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if !weakMatch(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// weakMatch сравнивает ETag из списка tags с etag без учета признака W/.
+func weakMatch(tags, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
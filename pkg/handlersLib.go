@@ -1,8 +1,16 @@
 package pkg
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"sort"
+	"strings"
 )
 
 func CloseBody(r *http.Request) {
@@ -11,3 +19,85 @@ func CloseBody(r *http.Request) {
 		log.Println("error closing request body:", err)
 	}
 }
+
+// JSONContentType - тип тела успешных ответов API.
+const JSONContentType = "application/json"
+
+// WriteJSON отвечает status с телом resp в формате JSON. Остальные заголовки
+// ответа выставляются до вызова.
+func WriteJSON(w http.ResponseWriter, status int, resp []byte) {
+	w.Header().Set("Content-Type", JSONContentType)
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// ByMethod выбирает обработчик по HTTP-методу запроса. На остальные методы
+// отвечает 405 со списком допустимых в заголовке Allow.
+func ByMethod(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	allowed := make([]string, 0, len(handlers))
+	for method := range handlers {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := handlers[r.Method]
+		if !ok {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			WriteProblem(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// DecodePatch применяет PATCH-запрос к current в формате из Content-Type
+// (MergePatchType или JSONPatchType) и записывает результат в dst. Неизвестные
+// поля в результате считаются ошибкой. При ошибке ответ уже записан в w.
+func DecodePatch(w http.ResponseWriter, r *http.Request, current, dst interface{}) bool {
+	defer CloseBody(r)
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != MergePatchType && contentType != JSONPatchType) {
+		log.Println("error patching: unsupported content type", r.Header.Get("Content-Type"))
+		w.Header().Set("Accept-Patch", MergePatchType+", "+JSONPatchType)
+		WriteProblem(w, r, http.StatusUnsupportedMediaType, "content type must be "+MergePatchType+" or "+JSONPatchType)
+		return false
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("error reading patch:", err)
+		WriteProblem(w, r, http.StatusBadRequest, "can't read patch")
+		return false
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		log.Println("error marshalling current state:", err)
+		WriteProblem(w, r, http.StatusInternalServerError, "can't marshal current state")
+		return false
+	}
+
+	patched, err := ApplyPatch(contentType, doc, patch)
+	if errors.Is(err, ErrPatchTestFailed) {
+		log.Println("error applying patch:", err)
+		WriteProblem(w, r, http.StatusConflict, fmt.Sprintf("can't apply patch: %v", err))
+		return false
+	}
+	if err != nil {
+		log.Println("error applying patch:", err)
+		WriteProblem(w, r, http.StatusBadRequest, fmt.Sprintf("can't apply patch: %v", err))
+		return false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(dst)
+	if err != nil {
+		log.Println("error decoding patched JSON:", err)
+		WriteProblem(w, r, http.StatusBadRequest, fmt.Sprintf("wrong patched document: %v", err))
+		return false
+	}
+	return true
+}
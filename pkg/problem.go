@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// ProblemContentType - тип ответов об ошибках (RFC 7807).
+const ProblemContentType = "application/problem+json"
+
+// RequestIDHeader - заголовок с id запроса. Id из запроса клиента сохраняется,
+// иначе создается новый; он возвращается в ответе и в поле request_id ошибок.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen ограничивает id от клиента, чтобы он не раздувал логи и ответы.
+const maxRequestIDLen = 128
+
+// Problem - описание ошибки по RFC 7807. Type "about:blank" означает, что
+// смысл ошибки полностью передает код ответа, а Title - его название.
+type Problem struct {
+	Type      string       `json:"type" example:"about:blank"`
+	Title     string       `json:"title" example:"Not Found"`
+	Status    int          `json:"status" example:"404"`
+	Detail    string       `json:"detail,omitempty" example:"can't find film: not found"`
+	Instance  string       `json:"instance,omitempty" example:"/user/film"`
+	RequestID string       `json:"request_id,omitempty" example:"5f2b6c1e9a7d4e3b8c0a1f2e3d4c5b6a"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError - ошибка в одном поле запроса. Field - путь к полю в JSON,
+// например release_date или actors[0].birth_date.
+type FieldError struct {
+	Field   string `json:"field" example:"release_date"`
+	Message string `json:"message" example:"wrong date format"`
+}
+
+type requestIDKey struct{}
+
+// RequestID добавляет к запросу id из заголовка X-Request-ID или новый и
+// возвращает его в том же заголовке ответа.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext возвращает id запроса, добавленный RequestID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Println("error generating request id:", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewProblem описывает ошибку запроса r с кодом status.
+func NewProblem(r *http.Request, status int, detail string) *Problem {
+	return &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+}
+
+// WriteProblem отвечает на запрос r ошибкой с кодом status. Заменяет http.Error.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	WriteProblemJSON(w, status, NewProblem(r, status, detail))
+}
+
+// WriteValidationProblem отвечает 400 со списком ошибок в полях запроса.
+func WriteValidationProblem(w http.ResponseWriter, r *http.Request, errs []FieldError) {
+	log.Println("request validation failed:", errs)
+	problem := NewProblem(r, http.StatusBadRequest, "request validation failed")
+	problem.Errors = errs
+	WriteProblemJSON(w, http.StatusBadRequest, problem)
+}
+
+// WriteProblemJSON записывает problem - *Problem или структуру, которая
+// встраивает Problem и добавляет свои поля (расширения RFC 7807).
+func WriteProblemJSON(w http.ResponseWriter, status int, problem interface{}) {
+	resp, err := json.Marshal(problem)
+	if err != nil {
+		log.Println("error marshalling problem:", err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// NotFound отвечает 404 на запросы к неизвестным адресам.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, http.StatusNotFound, "no handler for "+r.URL.Path)
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type pathValuesKey struct{}
+
+// ByPath выбирает обработчик по шаблону пути вида /user/films/{id}/revisions.
+// Сегмент в фигурных скобках подходит под любое непустое значение, его можно
+// получить через PathValue. Если под путь подходят несколько шаблонов,
+// выбирается тот, в котором больше постоянных сегментов. На пути, не
+// подходящие ни под один шаблон, отвечает 404.
+func ByPath(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	patterns := make([]string, 0, len(handlers))
+	for pattern := range handlers {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return literalSegments(patterns[i]) > literalSegments(patterns[j])
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, pattern := range patterns {
+			values, ok := matchPath(pattern, r.URL.Path)
+			if ok {
+				handlers[pattern](w, r.WithContext(context.WithValue(r.Context(), pathValuesKey{}, values)))
+				return
+			}
+		}
+		NotFound(w, r)
+	}
+}
+
+// PathValue возвращает значение сегмента {name} из шаблона, выбранного ByPath,
+// или пустую строку.
+func PathValue(r *http.Request, name string) string {
+	values, _ := r.Context().Value(pathValuesKey{}).(map[string]string)
+	return values[name]
+}
+
+// PathID возвращает числовой id из сегмента {name}. При ошибке ответ уже
+// записан в w.
+func PathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(PathValue(r, name), 10, 64)
+	if err != nil || id < 1 {
+		log.Printf("wrong %s in path %s", name, r.URL.Path)
+		WriteProblem(w, r, http.StatusBadRequest, "wrong "+name+": it must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+func matchPath(pattern, path string) (map[string]string, bool) {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+
+	values := make(map[string]string)
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+			values[segment[1:len(segment)-1]] = pathSegments[i]
+			continue
+		}
+		if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+func literalSegments(pattern string) int {
+	count := 0
+	for _, segment := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if !strings.HasPrefix(segment, "{") {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Assignments собирает SET-часть UPDATE только из изменившихся колонок.
+type Assignments struct {
+	columns []string
+	args    []interface{}
+}
+
+func (a *Assignments) Set(column string, value interface{}) {
+	a.columns = append(a.columns, column)
+	a.args = append(a.args, value)
+}
+
+func (a *Assignments) Empty() bool {
+	return len(a.columns) == 0
+}
+
+// Update возвращает запрос UPDATE строки id в таблице table и его аргументы.
+func (a *Assignments) Update(table string, id int64) (string, []interface{}) {
+	set := make([]string, len(a.columns))
+	for i, column := range a.columns {
+		set[i] = fmt.Sprintf("%s = $%d", column, i+1)
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d", table, strings.Join(set, ", "), len(a.columns)+1)
+	return query, append(append([]interface{}{}, a.args...), id)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike экранирует в s символы шаблона LIKE, чтобы s искалась буквально в
+// условии LIKE ... ESCAPE '\'.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
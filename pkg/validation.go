@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Validate проверяет структуру v (или указатель на нее) по тегам полей и
+// возвращает все найденные ошибки. Поля называются по тегу json, вложенные
+// структуры и их срезы проверяются рекурсивно: actors[0].birth_date.
+//
+// Тег notempty:"true" запрещает нулевое значение поля. Тег validate содержит
+// правила через запятую, они проверяют только непустые значения:
+//
+//	min=N, max=N - границы числа, длины строки или среза
+//	oneof=a b    - значение из списка через пробел
+//	date         - дата в формате ParseDate
+//
+// Неизвестное правило - ошибка в описании структуры, а не в запросе, поэтому
+// Validate паникует.
+func Validate(v interface{}) []FieldError {
+	return validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", nil)
+}
+
+// ValidateFields проверяет только перечисленные поля верхнего уровня, например
+// title и release_date, по которым фильм ищется в базе.
+func ValidateFields(v interface{}, fields ...string) []FieldError {
+	only := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		only[field] = true
+	}
+	return validateStruct(reflect.Indirect(reflect.ValueOf(v)), "", only)
+}
+
+func validateStruct(value reflect.Value, prefix string, only map[string]bool) []FieldError {
+	var errs []FieldError
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok || only != nil && !only[name] {
+			continue
+		}
+		errs = append(errs, validateField(value.Field(i), field, prefix+name)...)
+	}
+	return errs
+}
+
+func validateField(value reflect.Value, field reflect.StructField, path string) []FieldError {
+	if value.IsZero() {
+		if field.Tag.Get("notempty") == "true" {
+			return []FieldError{{Field: path, Message: "must not be empty"}}
+		}
+		return nil
+	}
+
+	if rules := field.Tag.Get("validate"); rules != "" {
+		for _, rule := range strings.Split(rules, ",") {
+			message := checkRule(value, rule)
+			if message != "" {
+				return []FieldError{{Field: path, Message: message}}
+			}
+		}
+	}
+
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Struct:
+		return validateStruct(value, path+".", nil)
+	case reflect.Slice, reflect.Array:
+		var errs []FieldError
+		for i := 0; i < value.Len(); i++ {
+			errs = append(errs, validateStruct(reflect.Indirect(value.Index(i)), fmt.Sprintf("%s[%d].", path, i), nil)...)
+		}
+		return errs
+	}
+	return nil
+}
+
+// checkRule возвращает сообщение об ошибке или пустую строку, если значение
+// подходит под правило.
+func checkRule(value reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+	value = reflect.Indirect(value)
+
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("pkg.Validate: wrong %s rule %q", name, rule))
+		}
+		size, unit := ruleSize(value, rule)
+		if name == "min" && size < limit {
+			return fmt.Sprintf("must be at least %d%s", limit, unit)
+		}
+		if name == "max" && size > limit {
+			return fmt.Sprintf("must be at most %d%s", limit, unit)
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		s := fmt.Sprint(value.Interface())
+		for _, option := range options {
+			if s == option {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", ")
+	case "date":
+		if value.Kind() != reflect.String {
+			panic(fmt.Sprintf("pkg.Validate: rule %q needs a string field", rule))
+		}
+		if err := DateValidation(value.String()); err != nil {
+			return err.Error()
+		}
+	default:
+		panic(fmt.Sprintf("pkg.Validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+// ruleSize возвращает то, что сравнивают min и max: число, длину строки в
+// символах или длину среза.
+func ruleSize(value reflect.Value, rule string) (int64, string) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), ""
+	case reflect.String:
+		return int64(len([]rune(value.String()))), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(value.Len()), " items"
+	default:
+		panic(fmt.Sprintf("pkg.Validate: rule %q doesn't support %s fields", rule, value.Kind()))
+	}
+}
+
+// jsonFieldName возвращает имя поля в JSON; false для полей, которых нет в JSON.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+                                         id SERIAL PRIMARY KEY,
+                                         user_id INT,
+                                         entity VARCHAR(20) NOT NULL,
+                                         entity_id INT NOT NULL,
+                                         action VARCHAR(20) NOT NULL,
+                                         before JSONB,
+                                         after JSONB,
+                                         diff JSONB,
+                                         created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_user_id_idx ON audit_log (user_id);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS film_revision (
+                                             id SERIAL PRIMARY KEY,
+                                             film_id INT NOT NULL,
+                                             revision INT NOT NULL,
+                                             action VARCHAR(20) NOT NULL,
+                                             user_id INT,
+                                             data JSONB NOT NULL,
+                                             created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                             CONSTRAINT film_revision_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE,
+                                             CONSTRAINT unique_film_revision UNIQUE (film_id, revision)
+);
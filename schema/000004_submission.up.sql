@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS submission (
+                                          id SERIAL PRIMARY KEY,
+                                          user_id INT NOT NULL,
+                                          entity VARCHAR(20) CHECK (entity IN ('film', 'actor')) NOT NULL,
+                                          data JSONB NOT NULL,
+                                          status VARCHAR(20) CHECK (status IN ('pending', 'approved', 'rejected')) NOT NULL DEFAULT 'pending',
+                                          reason VARCHAR(1000),
+                                          reviewed_by INT,
+                                          created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                          reviewed_at TIMESTAMPTZ,
+                                          CONSTRAINT submission_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+                                          CONSTRAINT submission_reviewed_by_fkey FOREIGN KEY (reviewed_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS submission_status_idx ON submission (status);
+CREATE INDEX IF NOT EXISTS submission_user_id_idx ON submission (user_id);
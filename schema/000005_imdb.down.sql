@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS imdb_import_progress;
+
+ALTER TABLE actor DROP CONSTRAINT IF EXISTS unique_actor_imdb_id;
+ALTER TABLE actor DROP COLUMN IF EXISTS imdb_id;
+
+ALTER TABLE film DROP CONSTRAINT IF EXISTS unique_film_imdb_id;
+ALTER TABLE film DROP COLUMN IF EXISTS imdb_id;
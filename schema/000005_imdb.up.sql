@@ -0,0 +1,11 @@
+ALTER TABLE film ADD COLUMN IF NOT EXISTS imdb_id VARCHAR(12);
+ALTER TABLE film ADD CONSTRAINT unique_film_imdb_id UNIQUE (imdb_id);
+
+ALTER TABLE actor ADD COLUMN IF NOT EXISTS imdb_id VARCHAR(12);
+ALTER TABLE actor ADD CONSTRAINT unique_actor_imdb_id UNIQUE (imdb_id);
+
+CREATE TABLE IF NOT EXISTS imdb_import_progress (
+                                                    stage VARCHAR(20) PRIMARY KEY,
+                                                    line BIGINT NOT NULL,
+                                                    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
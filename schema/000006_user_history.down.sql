@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_film_watch;
+DROP TABLE IF EXISTS user_film_rating;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS user_film_rating (
+                                                user_id INT NOT NULL,
+                                                film_id INT NOT NULL,
+                                                rating INT NOT NULL,
+                                                source VARCHAR(20) NOT NULL,
+                                                updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                                PRIMARY KEY (user_id, film_id),
+                                                CONSTRAINT user_film_rating_rating CHECK (rating >= 1 AND rating <= 10),
+                                                CONSTRAINT user_film_rating_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+                                                CONSTRAINT user_film_rating_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_film_watch (
+                                               id SERIAL PRIMARY KEY,
+                                               user_id INT NOT NULL,
+                                               film_id INT NOT NULL,
+                                               watched_at VARCHAR(12) NOT NULL,
+                                               source VARCHAR(20) NOT NULL,
+                                               CONSTRAINT user_film_watch_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+                                               CONSTRAINT user_film_watch_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE,
+                                               CONSTRAINT unique_user_film_watch UNIQUE (user_id, film_id, watched_at)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS created_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS disabled;
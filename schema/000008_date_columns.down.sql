@@ -0,0 +1,5 @@
+ALTER TABLE user_film_watch ALTER COLUMN watched_at TYPE VARCHAR(12) USING to_char(watched_at, 'DD.MM.YYYY');
+
+ALTER TABLE actor ALTER COLUMN birth_date TYPE VARCHAR(20) USING to_char(birth_date, 'DD.MM.YYYY');
+
+ALTER TABLE film ALTER COLUMN release_date TYPE VARCHAR(12) USING to_char(release_date, 'DD.MM.YYYY');
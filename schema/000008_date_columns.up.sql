@@ -0,0 +1,5 @@
+ALTER TABLE film ALTER COLUMN release_date TYPE DATE USING to_date(release_date, 'DD.MM.YYYY');
+
+ALTER TABLE actor ALTER COLUMN birth_date TYPE DATE USING to_date(birth_date, 'DD.MM.YYYY');
+
+ALTER TABLE user_film_watch ALTER COLUMN watched_at TYPE DATE USING to_date(watched_at, 'DD.MM.YYYY');
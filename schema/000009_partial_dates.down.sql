@@ -0,0 +1,7 @@
+DROP FUNCTION IF EXISTS partial_date(DATE, VARCHAR, BOOLEAN);
+
+ALTER TABLE actor DROP COLUMN IF EXISTS birth_date_approx;
+ALTER TABLE actor DROP COLUMN IF EXISTS birth_date_precision;
+
+ALTER TABLE film DROP COLUMN IF EXISTS release_date_approx;
+ALTER TABLE film DROP COLUMN IF EXISTS release_date_precision;
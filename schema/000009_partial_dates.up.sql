@@ -0,0 +1,28 @@
+ALTER TABLE film ADD COLUMN release_date_precision VARCHAR(5) NOT NULL DEFAULT 'day'
+    CHECK (release_date_precision IN ('day', 'month', 'year'));
+ALTER TABLE film ADD COLUMN release_date_approx BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE actor ADD COLUMN birth_date_precision VARCHAR(5) NOT NULL DEFAULT 'day'
+    CHECK (birth_date_precision IN ('day', 'month', 'year'));
+ALTER TABLE actor ADD COLUMN birth_date_approx BOOLEAN NOT NULL DEFAULT false;
+
+-- у фильмов и актеров, созданных импортом IMDb, известен только год, который
+-- сохранялся как 1 января. Записи, которые уже были в каталоге и только получили
+-- imdb_id, сохраняют свою точную дату. Такие фильмы отличаются записью о создании
+-- в журнале аудита (импорт IMDb аудит не вел), а такие актеры - ролями в фильмах
+-- не из IMDb
+UPDATE film SET release_date_precision = 'year'
+WHERE imdb_id IS NOT NULL AND EXTRACT(MONTH FROM release_date) = 1 AND EXTRACT(DAY FROM release_date) = 1
+  AND NOT EXISTS (SELECT 1 FROM audit_log a WHERE a.entity = 'film' AND a.entity_id = film.id AND a.action = 'create');
+UPDATE actor SET birth_date_precision = 'year'
+WHERE imdb_id IS NOT NULL AND EXTRACT(MONTH FROM birth_date) = 1 AND EXTRACT(DAY FROM birth_date) = 1
+  AND NOT EXISTS (SELECT 1 FROM film_actor fa JOIN film f ON f.id = fa.film_id WHERE fa.actor_id = actor.id AND f.imdb_id IS NULL);
+
+-- partial_date возвращает дату с ее точностью в формате EDTF: 1920, 1920-05, 1920-05-17, 1920~
+CREATE FUNCTION partial_date(d DATE, date_precision VARCHAR, approximate BOOLEAN) RETURNS TEXT AS $$
+    SELECT CASE date_precision
+               WHEN 'year' THEN to_char(d, 'YYYY')
+               WHEN 'month' THEN to_char(d, 'YYYY-MM')
+               ELSE to_char(d, 'YYYY-MM-DD')
+           END || CASE WHEN approximate THEN '~' ELSE '' END
+$$ LANGUAGE SQL STABLE;
@@ -0,0 +1,2 @@
+ALTER TABLE film ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE actor ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS film_actor_catalog_version ON film_actor;
+DROP TRIGGER IF EXISTS actor_catalog_version ON actor;
+DROP TRIGGER IF EXISTS film_catalog_version ON film;
+
+DROP FUNCTION IF EXISTS bump_catalog_version();
+
+DROP TABLE IF EXISTS catalog_version;
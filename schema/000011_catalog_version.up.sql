@@ -0,0 +1,22 @@
+-- catalog_version - счетчик изменений каталога (фильмы, актеры и их связи) для
+-- условных GET-запросов. В таблице всегда одна строка.
+CREATE TABLE catalog_version (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    version BIGINT NOT NULL DEFAULT 1,
+    modified_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO catalog_version DEFAULT VALUES;
+
+CREATE FUNCTION bump_catalog_version() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE catalog_version SET version = version + 1, modified_at = clock_timestamp();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER film_catalog_version AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON film
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
+CREATE TRIGGER actor_catalog_version AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON actor
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
+CREATE TRIGGER film_actor_catalog_version AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON film_actor
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
@@ -0,0 +1,6 @@
+CREATE OR REPLACE FUNCTION bump_catalog_version() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE catalog_version SET version = version + 1, modified_at = clock_timestamp();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
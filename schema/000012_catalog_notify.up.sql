@@ -0,0 +1,10 @@
+-- Сообщает об изменении каталога в канал catalog_changed, чтобы экземпляры
+-- сервера сбрасывали кэш списков. Одинаковые уведомления в одной транзакции
+-- PostgreSQL объединяет, поэтому импорт отправляет одно уведомление.
+CREATE OR REPLACE FUNCTION bump_catalog_version() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE catalog_version SET version = version + 1, modified_at = clock_timestamp();
+    PERFORM pg_notify('catalog_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+DELETE FROM film_revision WHERE film_id NOT IN (SELECT id FROM film);
+ALTER TABLE film_revision
+    ADD CONSTRAINT film_revision_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE;
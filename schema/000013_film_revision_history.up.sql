@@ -0,0 +1,5 @@
+-- История фильма остается после его удаления: ревизии больше не удаляются
+-- каскадом, а film_id, как entity_id в audit_log, хранит id удаленного фильма.
+-- Последняя ревизия удаленного фильма (action = 'delete') хранит его состояние
+-- на момент удаления.
+ALTER TABLE film_revision DROP CONSTRAINT IF EXISTS film_revision_film_id_fkey;
@@ -0,0 +1,22 @@
+DROP TRIGGER IF EXISTS film_actor_truncate_catalog_version ON film_actor;
+DROP TRIGGER IF EXISTS actor_truncate_catalog_version ON actor;
+DROP TRIGGER IF EXISTS film_truncate_catalog_version ON film;
+
+DROP TRIGGER IF EXISTS film_actor_catalog_version ON film_actor;
+DROP TRIGGER IF EXISTS actor_catalog_version ON actor;
+DROP TRIGGER IF EXISTS film_catalog_version ON film;
+
+CREATE OR REPLACE FUNCTION bump_catalog_version() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE catalog_version SET version = version + 1, modified_at = clock_timestamp();
+    PERFORM pg_notify('catalog_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER film_catalog_version AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON film
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
+CREATE TRIGGER actor_catalog_version AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON actor
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
+CREATE TRIGGER film_actor_catalog_version AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON film_actor
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
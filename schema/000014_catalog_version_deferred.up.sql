@@ -0,0 +1,36 @@
+-- Счетчик каталога обновляется при фиксации транзакции и один раз на
+-- транзакцию. Раньше первое же изменение фильма, актера или связи блокировало
+-- единственную строку catalog_version до конца транзакции, и все изменения
+-- каталога выполнялись по очереди; теперь строка блокируется только на время
+-- фиксации. TRUNCATE не поддерживается отложенными триггерами, для него
+-- остаются триггеры на выражение.
+CREATE OR REPLACE FUNCTION bump_catalog_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('filmoteka.catalog_bumped', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+    PERFORM set_config('filmoteka.catalog_bumped', 'on', true);
+
+    UPDATE catalog_version SET version = version + 1, modified_at = clock_timestamp();
+    PERFORM pg_notify('catalog_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER film_catalog_version ON film;
+DROP TRIGGER actor_catalog_version ON actor;
+DROP TRIGGER film_actor_catalog_version ON film_actor;
+
+CREATE CONSTRAINT TRIGGER film_catalog_version AFTER INSERT OR UPDATE OR DELETE ON film
+    DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION bump_catalog_version();
+CREATE CONSTRAINT TRIGGER actor_catalog_version AFTER INSERT OR UPDATE OR DELETE ON actor
+    DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION bump_catalog_version();
+CREATE CONSTRAINT TRIGGER film_actor_catalog_version AFTER INSERT OR UPDATE OR DELETE ON film_actor
+    DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION bump_catalog_version();
+
+CREATE TRIGGER film_truncate_catalog_version AFTER TRUNCATE ON film
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
+CREATE TRIGGER actor_truncate_catalog_version AFTER TRUNCATE ON actor
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
+CREATE TRIGGER film_actor_truncate_catalog_version AFTER TRUNCATE ON film_actor
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_catalog_version();
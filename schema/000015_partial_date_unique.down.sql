@@ -0,0 +1,5 @@
+ALTER TABLE actor DROP CONSTRAINT unique_actor_fields;
+ALTER TABLE actor ADD CONSTRAINT unique_actor_fields UNIQUE (name, gender, birth_date);
+
+ALTER TABLE film DROP CONSTRAINT unique_title_release_date;
+ALTER TABLE film ADD CONSTRAINT unique_title_release_date UNIQUE (title, release_date);
@@ -0,0 +1,9 @@
+-- даты с разной точностью - разные даты: фильм 1990 года и фильм, вышедший
+-- 01.01.1990, могут называться одинаково
+ALTER TABLE film DROP CONSTRAINT unique_title_release_date;
+ALTER TABLE film ADD CONSTRAINT unique_title_release_date
+    UNIQUE (title, release_date, release_date_precision, release_date_approx);
+
+ALTER TABLE actor DROP CONSTRAINT unique_actor_fields;
+ALTER TABLE actor ADD CONSTRAINT unique_actor_fields
+    UNIQUE (name, gender, birth_date, birth_date_precision, birth_date_approx);
@@ -0,0 +1,18 @@
+// Package schema встраивает файлы миграций в бинарник. Миграции применяются
+// пакетом internal/migrate (команда filmoteka migrate или AUTO_MIGRATE при запуске).
+package schema
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.sql
+var Migrations embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// SQLiteMigrations - отдельная история миграций для SQLite: ее схема повторяет
+// схему PostgreSQL, но без несовместимых с SQLite конструкций.
+var SQLiteMigrations, _ = fs.Sub(sqliteMigrations, "sqlite")
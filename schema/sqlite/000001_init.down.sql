@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS catalog_version;
+DROP TABLE IF EXISTS user_film_watch;
+DROP TABLE IF EXISTS user_film_rating;
+DROP TABLE IF EXISTS imdb_import_progress;
+DROP TABLE IF EXISTS submission;
+DROP TABLE IF EXISTS film_revision;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS film_actor;
+DROP TABLE IF EXISTS film;
+DROP TABLE IF EXISTS actor;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
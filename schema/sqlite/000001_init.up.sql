@@ -0,0 +1,160 @@
+-- Схема SQLite соответствует схеме PostgreSQL после миграции 000012, уникальные
+-- ключи фильма и актера - после 000015. Даты хранятся текстом в ISO 8601, JSON -
+-- текстом, время - в UTC (CURRENT_TIMESTAMP).
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    login VARCHAR(255) NOT NULL,
+    password BLOB NOT NULL,
+    role BOOLEAN,
+    disabled BOOLEAN NOT NULL DEFAULT false,
+    CONSTRAINT unique_login UNIQUE (login)
+);
+
+CREATE TABLE sessions (
+    id VARCHAR(32),
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_session_id UNIQUE (id),
+    CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE actor (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    gender VARCHAR(10) CHECK (gender IN ('man', 'woman')) NOT NULL,
+    birth_date DATE NOT NULL,
+    birth_date_precision VARCHAR(5) NOT NULL DEFAULT 'day' CHECK (birth_date_precision IN ('day', 'month', 'year')),
+    birth_date_approx BOOLEAN NOT NULL DEFAULT false,
+    imdb_id VARCHAR(12),
+    version BIGINT NOT NULL DEFAULT 1,
+    CONSTRAINT unique_actor_fields UNIQUE (name, gender, birth_date, birth_date_precision, birth_date_approx),
+    CONSTRAINT unique_actor_imdb_id UNIQUE (imdb_id)
+);
+
+CREATE TABLE film (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(150) NOT NULL,
+    description VARCHAR(1000) NOT NULL,
+    release_date DATE NOT NULL,
+    release_date_precision VARCHAR(5) NOT NULL DEFAULT 'day' CHECK (release_date_precision IN ('day', 'month', 'year')),
+    release_date_approx BOOLEAN NOT NULL DEFAULT false,
+    rating INT NOT NULL,
+    imdb_id VARCHAR(12),
+    version BIGINT NOT NULL DEFAULT 1,
+    CONSTRAINT rating CHECK (rating >= 1 AND rating <= 10),
+    CONSTRAINT unique_title_release_date UNIQUE (title, release_date, release_date_precision, release_date_approx),
+    CONSTRAINT unique_film_imdb_id UNIQUE (imdb_id)
+);
+
+CREATE TABLE film_actor (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    film_id INTEGER NOT NULL,
+    actor_id INTEGER NOT NULL,
+    CONSTRAINT film_actor_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id),
+    CONSTRAINT film_actor_actor_id_fkey FOREIGN KEY (actor_id) REFERENCES actor(id),
+    CONSTRAINT unique_film_actor UNIQUE (film_id, actor_id)
+);
+
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    entity VARCHAR(20) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    before TEXT,
+    after TEXT,
+    diff TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity, entity_id);
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id);
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+
+CREATE TABLE film_revision (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    film_id INTEGER NOT NULL,
+    revision INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    user_id INTEGER,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT film_revision_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE,
+    CONSTRAINT unique_film_revision UNIQUE (film_id, revision)
+);
+
+CREATE TABLE submission (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    entity VARCHAR(20) CHECK (entity IN ('film', 'actor')) NOT NULL,
+    data TEXT NOT NULL,
+    status VARCHAR(20) CHECK (status IN ('pending', 'approved', 'rejected')) NOT NULL DEFAULT 'pending',
+    reason VARCHAR(1000),
+    reviewed_by INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    CONSTRAINT submission_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT submission_reviewed_by_fkey FOREIGN KEY (reviewed_by) REFERENCES users(id)
+);
+
+CREATE INDEX submission_status_idx ON submission (status);
+CREATE INDEX submission_user_id_idx ON submission (user_id);
+
+CREATE TABLE imdb_import_progress (
+    stage VARCHAR(20) PRIMARY KEY,
+    line BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_film_rating (
+    user_id INTEGER NOT NULL,
+    film_id INTEGER NOT NULL,
+    rating INT NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, film_id),
+    CONSTRAINT user_film_rating_rating CHECK (rating >= 1 AND rating <= 10),
+    CONSTRAINT user_film_rating_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT user_film_rating_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_film_watch (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    film_id INTEGER NOT NULL,
+    watched_at DATE NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    CONSTRAINT user_film_watch_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id),
+    CONSTRAINT user_film_watch_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE,
+    CONSTRAINT unique_user_film_watch UNIQUE (user_id, film_id, watched_at)
+);
+
+-- catalog_version - счетчик изменений каталога для условных GET-запросов.
+-- Триггеры SQLite срабатывают на каждую строку, а не на оператор.
+-- Отложенных триггеров, как в миграции PostgreSQL 000014, в SQLite нет, но они
+-- и не нужны: SQLite и так выполняет пишущие транзакции по одной.
+CREATE TABLE catalog_version (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    version BIGINT NOT NULL DEFAULT 1,
+    modified_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO catalog_version DEFAULT VALUES;
+
+CREATE TRIGGER film_insert_catalog_version AFTER INSERT ON film
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER film_update_catalog_version AFTER UPDATE ON film
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER film_delete_catalog_version AFTER DELETE ON film
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER actor_insert_catalog_version AFTER INSERT ON actor
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER actor_update_catalog_version AFTER UPDATE ON actor
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER actor_delete_catalog_version AFTER DELETE ON actor
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER film_actor_insert_catalog_version AFTER INSERT ON film_actor
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER film_actor_update_catalog_version AFTER UPDATE ON film_actor
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
+CREATE TRIGGER film_actor_delete_catalog_version AFTER DELETE ON film_actor
+BEGIN UPDATE catalog_version SET version = version + 1, modified_at = strftime('%Y-%m-%d %H:%M:%f', 'now'); END;
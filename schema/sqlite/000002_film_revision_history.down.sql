@@ -0,0 +1,14 @@
+CREATE TABLE film_revision_cascade (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    film_id INTEGER NOT NULL,
+    revision INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    user_id INTEGER,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT film_revision_film_id_fkey FOREIGN KEY (film_id) REFERENCES film(id) ON DELETE CASCADE,
+    CONSTRAINT unique_film_revision UNIQUE (film_id, revision)
+);
+INSERT INTO film_revision_cascade SELECT * FROM film_revision WHERE film_id IN (SELECT id FROM film);
+DROP TABLE film_revision;
+ALTER TABLE film_revision_cascade RENAME TO film_revision;
@@ -0,0 +1,15 @@
+-- Повторяет миграцию PostgreSQL 000013: ревизии удаленного фильма остаются.
+-- SQLite не умеет удалять ограничения, поэтому таблица пересоздается.
+CREATE TABLE film_revision_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    film_id INTEGER NOT NULL,
+    revision INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    user_id INTEGER,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT unique_film_revision UNIQUE (film_id, revision)
+);
+INSERT INTO film_revision_history SELECT * FROM film_revision;
+DROP TABLE film_revision;
+ALTER TABLE film_revision_history RENAME TO film_revision;
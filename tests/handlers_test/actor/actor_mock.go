@@ -5,6 +5,7 @@
 package actorTest
 
 import (
+	context "context"
 	"filmoteka/internal/actor"
 	reflect "reflect"
 
@@ -49,17 +50,33 @@ func (mr *MockStorageMockRecorder) Add(arg0 interface{}) *gomock.Call {
 }
 
 // Delete mocks base method.
-func (m *MockStorage) Delete(arg0 int64) error {
+func (m *MockStorage) Delete(ctx context.Context, actorId int64, cascade bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret := m.ctrl.Call(m, "Delete", ctx, actorId, cascade)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockStorageMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Delete(ctx, actorId, cascade interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorage)(nil).Delete), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorage)(nil).Delete), ctx, actorId, cascade)
+}
+
+// Get mocks base method.
+func (m *MockStorage) Get(actorId int64) (*actor.Actor, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", actorId)
+	ret0, _ := ret[0].(*actor.Actor)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockStorageMockRecorder) Get(actorId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockStorage)(nil).Get), actorId)
 }
 
 // GetActorId mocks base method.
@@ -77,16 +94,33 @@ func (mr *MockStorageMockRecorder) GetActorId(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActorId", reflect.TypeOf((*MockStorage)(nil).GetActorId), arg0)
 }
 
+// Patch mocks base method.
+func (m *MockStorage) Patch(ctx context.Context, actorId int64, patched *actor.Actor) (*actor.Actor, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", ctx, actorId, patched)
+	ret0, _ := ret[0].(*actor.Actor)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Patch indicates an expected call of Patch.
+func (mr *MockStorageMockRecorder) Patch(ctx, actorId, patched interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockStorage)(nil).Patch), ctx, actorId, patched)
+}
+
 // Update mocks base method.
-func (m *MockStorage) Update(arg0 int64, arg1 *actor.Actor) error {
+func (m *MockStorage) Update(arg0 context.Context, arg1 int64, arg2 *actor.Actor) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Update indicates an expected call of Update.
-func (mr *MockStorageMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Update(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorage)(nil).Update), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorage)(nil).Update), arg0, arg1, arg2)
 }
@@ -2,8 +2,10 @@ package actorTest
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"filmoteka/internal/actor"
+	"filmoteka/pkg"
 	"fmt"
 	"github.com/golang/mock/gomock"
 	"net/http"
@@ -24,9 +26,10 @@ func TestActorHandler_AddActor(t *testing.T) {
 	// Успешное добавление нового актера
 	testActor := &actor.Actor{
 		Name:      "John",
+		Gender:    "man",
 		BirthDate: "01.01.1990",
 	}
-	mockStorage.EXPECT().GetActorId(testActor).Return(int64(0), fmt.Errorf("actor not found"))
+	mockStorage.EXPECT().GetActorId(testActor).Return(int64(0), fmt.Errorf("actor_repo.GetActorId: %w", pkg.DBError(sql.ErrNoRows)))
 	mockStorage.EXPECT().Add(testActor).Return(nil)
 
 	reqBody, err := json.Marshal(testActor)
@@ -44,7 +47,7 @@ func TestActorHandler_AddActor(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	expectedResponse := "actor added: {John  01.01.1990}"
+	expectedResponse := "actor added: {John man 01.01.1990}"
 	if body := strings.TrimSpace(w.Body.String()); body != expectedResponse {
 		t.Errorf("expected response body %q, got %q", expectedResponse, body)
 	}
@@ -60,14 +63,14 @@ func TestActorHandler_UpdateActor(t *testing.T) {
 	}
 
 	// Создаем тестовых актеров для обновления
-	oldActor := &actor.Actor{Name: "John", BirthDate: "01.01.1990"}
-	newActor := &actor.Actor{Name: "John", BirthDate: "02.02.1990"}
+	oldActor := &actor.Actor{Name: "John", Gender: "man", BirthDate: "01.01.1990"}
+	newActor := &actor.Actor{Name: "John", Gender: "man", BirthDate: "02.02.1990"}
 
 	// Устанавливаем ожидаемое поведение мока GetActorId
 	mockStorage.EXPECT().GetActorId(oldActor).Return(int64(1), nil)
 
 	// Устанавливаем ожидаемое поведение мока Update
-	mockStorage.EXPECT().Update(int64(1), newActor).Return(nil)
+	mockStorage.EXPECT().Update(gomock.Any(), int64(1), newActor).Return(int64(2), nil)
 
 	// Создаем JSON-данные для обновления актера
 	actorInfo := []actor.Actor{*oldActor, *newActor}
@@ -86,12 +89,192 @@ func TestActorHandler_UpdateActor(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	expectedResponse := "actor updated: {John  02.02.1990}"
+	expectedResponse := "actor updated: {John man 02.02.1990}"
 	if body := strings.TrimSpace(w.Body.String()); body != expectedResponse {
 		t.Errorf("expected response body %q, got %q", expectedResponse, body)
 	}
 }
 
+func TestActorHandler_GetActor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &actor.ActorHandler{
+		ActorRepo: mockStorage,
+	}
+
+	key := &actor.Actor{Name: "John", Gender: "man", BirthDate: "01.01.1990"}
+	mockStorage.EXPECT().GetActorId(key).Return(int64(1), nil)
+	mockStorage.EXPECT().Get(int64(1)).Return(key, int64(3), nil)
+
+	req := httptest.NewRequest("GET", "/user/actor?name=John&gender=man&birth_date=01.01.1990", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetActor(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	expectedResponse := `{"name":"John","gender":"man","birth_date":"01.01.1990"}`
+	if body := w.Body.String(); body != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, body)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"3"` {
+		t.Errorf("expected ETag %q, got %q", `"3"`, etag)
+	}
+	if location := w.Header().Get("Content-Location"); location != "/user/actors/1" {
+		t.Errorf("expected Content-Location %q, got %q", "/user/actors/1", location)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != pkg.JSONContentType {
+		t.Errorf("expected Content-Type %q, got %q", pkg.JSONContentType, ct)
+	}
+
+	// актер по id ищется без GetActorId
+	router := pkg.ByPath(map[string]http.HandlerFunc{
+		"/user/actors/{id}": handler.GetActor,
+	})
+	mockStorage.EXPECT().Get(int64(1)).Return(key, int64(3), nil)
+	w = httptest.NewRecorder()
+	router(w, httptest.NewRequest("GET", "/user/actors/1", nil))
+	if w.Code != http.StatusOK || w.Body.String() != expectedResponse {
+		t.Errorf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router(w, httptest.NewRequest("GET", "/user/actors/abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestActorHandler_PatchActor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &actor.ActorHandler{
+		ActorRepo: mockStorage,
+	}
+
+	key := &actor.Actor{Name: "John", Gender: "man", BirthDate: "01.01.1990"}
+	patched := &actor.Actor{Name: "John", Gender: "man", BirthDate: "1990~"}
+
+	// Частичное обновление даты рождения
+	mockStorage.EXPECT().GetActorId(key).Return(int64(1), nil)
+	mockStorage.EXPECT().Get(int64(1)).Return(key, int64(1), nil)
+	mockStorage.EXPECT().Patch(gomock.Any(), int64(1), patched).Return(patched, int64(2), nil)
+
+	req := httptest.NewRequest("PATCH", "/admin/actor/patch?name=John&gender=man&birth_date=01.01.1990",
+		strings.NewReader(`{"birth_date":"1990~"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w := httptest.NewRecorder()
+
+	handler.PatchActor(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	expectedResponse := `{"name":"John","gender":"man","birth_date":"1990~"}`
+	if body := w.Body.String(); body != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, body)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("expected ETag %q, got %q", `"2"`, etag)
+	}
+
+	// Актера изменили после чтения
+	mockStorage.EXPECT().GetActorId(key).Return(int64(1), nil)
+	mockStorage.EXPECT().Get(int64(1)).Return(key, int64(1), nil)
+	mockStorage.EXPECT().Patch(gomock.Any(), int64(1), patched).
+		Return(nil, int64(0), fmt.Errorf("actor_repo.Patch: %w", pkg.ErrPreconditionFailed))
+
+	req = httptest.NewRequest("PATCH", "/admin/actor/patch?name=John&gender=man&birth_date=01.01.1990",
+		strings.NewReader(`{"birth_date":"1990~"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+
+	handler.PatchActor(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+	}
+
+	// Неверная дата после патча
+	mockStorage.EXPECT().GetActorId(key).Return(int64(1), nil)
+	mockStorage.EXPECT().Get(int64(1)).Return(key, int64(1), nil)
+
+	req = httptest.NewRequest("PATCH", "/admin/actor/patch?name=John&gender=man&birth_date=01.01.1990",
+		strings.NewReader(`[{"op":"replace","path":"/birth_date","value":"yesterday"}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	w = httptest.NewRecorder()
+
+	handler.PatchActor(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Актер не найден
+	mockStorage.EXPECT().GetActorId(key).Return(int64(0), fmt.Errorf("actor_repo.GetActorId: %w", pkg.DBError(sql.ErrNoRows)))
+
+	req = httptest.NewRequest("PATCH", "/admin/actor/patch?name=John&gender=man&birth_date=01.01.1990",
+		strings.NewReader(`{"name":"Johnny"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w = httptest.NewRecorder()
+
+	handler.PatchActor(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestActorHandler_PatchActorValidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &actor.ActorHandler{
+		ActorRepo: mockStorage,
+	}
+
+	key := &actor.Actor{Name: "John", Gender: "man", BirthDate: "01.01.1990"}
+
+	// проверяется весь документ после патча, до базы он не доходит
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		field       string
+	}{
+		{"unknown gender", "application/merge-patch+json", `{"gender":"other"}`, "gender"},
+		{"name removed", "application/merge-patch+json", `{"name":null}`, "name"},
+		{"gender removed", "application/merge-patch+json", `{"gender":null}`, "gender"},
+		{"gender replaced by null", "application/json-patch+json", `[{"op":"replace","path":"/gender","value":null}]`, "gender"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage.EXPECT().GetActorId(key).Return(int64(1), nil)
+			mockStorage.EXPECT().Get(int64(1)).Return(key, int64(1), nil)
+
+			req := httptest.NewRequest("PATCH", "/admin/actor/patch?name=John&gender=man&birth_date=01.01.1990",
+				strings.NewReader(tt.patch))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			handler.PatchActor(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), `"field":"`+tt.field+`"`) {
+				t.Errorf("expected error for field %s, got %s", tt.field, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestActorHandler_DeleteActor(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -102,11 +285,11 @@ func TestActorHandler_DeleteActor(t *testing.T) {
 	}
 
 	// Создаем тестового актера для удаления
-	testActor := &actor.Actor{Name: "John", BirthDate: "01.01.1990"}
+	testActor := &actor.Actor{Name: "John", Gender: "man", BirthDate: "01.01.1990"}
 
 	mockStorage.EXPECT().GetActorId(testActor).Return(int64(1), nil)
 
-	mockStorage.EXPECT().Delete(int64(1)).Return(nil)
+	mockStorage.EXPECT().Delete(gomock.Any(), int64(1), false).Return(nil)
 
 	reqBody, err := json.Marshal(testActor)
 	if err != nil {
@@ -123,8 +306,66 @@ func TestActorHandler_DeleteActor(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
-	expectedResponse := "actor deleted: {John  01.01.1990}"
+	expectedResponse := "actor deleted: {John man 01.01.1990}"
+	if body := strings.TrimSpace(w.Body.String()); body != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, body)
+	}
+}
+
+func TestActorHandler_DeleteActor_Referenced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &actor.ActorHandler{
+		ActorRepo: mockStorage,
+	}
+
+	testActor := &actor.Actor{Name: "John", Gender: "man", BirthDate: "01.01.1990"}
+	refErr := &actor.ReferencedError{Films: []actor.FilmRef{{Title: "Film 1", ReleaseDate: "1999"}}}
+
+	mockStorage.EXPECT().GetActorId(testActor).Return(int64(1), nil)
+	mockStorage.EXPECT().Delete(gomock.Any(), int64(1), false).Return(fmt.Errorf("actor_repo.DeleteActor: %w", refErr))
+
+	reqBody, err := json.Marshal(testActor)
+	if err != nil {
+		t.Fatalf("failed to marshal request body: %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/admin/actor/delete", bytes.NewReader(reqBody))
+	w := httptest.NewRecorder()
+
+	handler.DeleteActor(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	expectedResponse := `{"type":"about:blank","title":"Conflict","status":409,"detail":"actor is referenced by 1 films","instance":"/admin/actor/delete","films":[{"title":"Film 1","release_date":"1999"}]}`
 	if body := strings.TrimSpace(w.Body.String()); body != expectedResponse {
 		t.Errorf("expected response body %q, got %q", expectedResponse, body)
 	}
+
+	// с cascade актер удаляется вместе со связями
+	mockStorage.EXPECT().GetActorId(testActor).Return(int64(1), nil)
+	mockStorage.EXPECT().Delete(gomock.Any(), int64(1), true).Return(nil)
+
+	req = httptest.NewRequest("DELETE", "/admin/actor/delete?cascade=true", bytes.NewReader(reqBody))
+	w = httptest.NewRecorder()
+
+	handler.DeleteActor(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// неверное значение cascade
+	req = httptest.NewRequest("DELETE", "/admin/actor/delete?cascade=yes", bytes.NewReader(reqBody))
+	w = httptest.NewRecorder()
+
+	handler.DeleteActor(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
 }
@@ -0,0 +1,107 @@
+package exportTest
+
+import (
+	"filmoteka/internal/actor"
+	"filmoteka/internal/export"
+	"filmoteka/internal/film"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportHandler_ExportFilms(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFilms := NewMockFilmSource(ctrl)
+	handler := &export.ExportHandler{
+		FilmRepo: mockFilms,
+	}
+
+	films := []film.Film{
+		{Title: "Film 1", Description: "Description 1", ReleaseDate: "01.01.2022", Rating: 8, Actors: []actor.Actor{
+			{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"},
+			{Name: "Actor 2", Gender: "woman", BirthDate: "02.01.1990"},
+		}},
+		{Title: "Film 2", Description: "Description 2", ReleaseDate: "02.01.2022", Rating: 7},
+	}
+	eachFilm := func(sortCol string, fn func(*film.Film) error) error {
+		for i := range films {
+			if err := fn(&films[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// csv
+	mockFilms.EXPECT().EachFilm("rating", gomock.Any()).DoAndReturn(eachFilm)
+
+	req := httptest.NewRequest("GET", "/user/film/export?format=csv&sort=rating", nil)
+	rr := httptest.NewRecorder()
+
+	handler.Export(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("expected csv content type, got %q", contentType)
+	}
+	expectedResponse := "title,description,release_date,rating,actor_name,actor_gender,actor_birth_date\n" +
+		"Film 1,Description 1,01.01.2022,8,Actor 1,man,01.01.1990\n" +
+		"Film 1,Description 1,01.01.2022,8,Actor 2,woman,02.01.1990\n" +
+		"Film 2,Description 2,02.01.2022,7,,,\n"
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+
+	// json
+	mockFilms.EXPECT().EachFilm("title", gomock.Any()).DoAndReturn(eachFilm)
+
+	req = httptest.NewRequest("GET", "/user/film/export?format=json", nil)
+	rr = httptest.NewRecorder()
+
+	handler.Export(rr, req)
+
+	expectedResponse = `[{"title":"Film 1","description":"Description 1","release_date":"01.01.2022","rating":8,"actors":[{"name":"Actor 1","gender":"man","birth_date":"01.01.1990"},{"name":"Actor 2","gender":"woman","birth_date":"02.01.1990"}]},` +
+		`{"title":"Film 2","description":"Description 2","release_date":"02.01.2022","rating":7}]`
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+}
+
+func TestExportHandler_ExportActors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockActors := NewMockActorSource(ctrl)
+	handler := &export.ExportHandler{
+		ActorRepo: mockActors,
+	}
+
+	mockActors.EXPECT().EachActor(gomock.Any()).DoAndReturn(func(fn func(*actor.Actor) error) error {
+		return fn(&actor.Actor{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"})
+	})
+
+	req := httptest.NewRequest("GET", "/user/film/export?entity=actors", nil)
+	rr := httptest.NewRecorder()
+
+	handler.Export(rr, req)
+
+	expectedResponse := "{\"name\":\"Actor 1\",\"gender\":\"man\",\"birth_date\":\"01.01.1990\"}\n"
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+
+	// wrong format is rejected before anything is written
+	req = httptest.NewRequest("GET", "/user/film/export?entity=actors&format=xml", nil)
+	rr = httptest.NewRecorder()
+
+	handler.Export(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,197 @@
+package film
+
+import (
+	"context"
+	"errors"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestCachedStorage_ServesReadsFromCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	storage := film.NewCachedStorage(mockStorage, pkg.NewLRU(10, time.Minute))
+
+	mockStorage.EXPECT().CatalogVersion().Return(int64(1), time.Time{}, nil).AnyTimes()
+	films := []film.Film{{Title: "Film 1"}, {Title: "Film 2"}}
+	actors := map[actor.Actor][]film.Film{{Name: "Actor 1"}: films}
+	mockStorage.EXPECT().GetAllFilms("rating").Return(films, nil).Times(1)
+	mockStorage.EXPECT().GetAllFilms("title").Return(films[:1], nil).Times(1)
+	mockStorage.EXPECT().FindFilms("Film").Return(films, nil).Times(1)
+	mockStorage.EXPECT().ActorsListWithFilms().Return(actors, nil).Times(1)
+
+	for i := 0; i < 3; i++ {
+		result, err := storage.GetAllFilms("rating")
+		if err != nil || len(result) != 2 {
+			t.Fatalf("unexpected result: %v, %v", result, err)
+		}
+		result, err = storage.GetAllFilms("title")
+		if err != nil || len(result) != 1 {
+			t.Fatalf("unexpected result: %v, %v", result, err)
+		}
+		result, err = storage.FindFilms("Film")
+		if err != nil || len(result) != 2 {
+			t.Fatalf("unexpected result: %v, %v", result, err)
+		}
+		list, err := storage.ActorsListWithFilms()
+		if err != nil || len(list) != 1 {
+			t.Fatalf("unexpected result: %v, %v", list, err)
+		}
+	}
+}
+
+func TestCachedStorage_DoesNotCacheErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	storage := film.NewCachedStorage(mockStorage, pkg.NewLRU(10, time.Minute))
+
+	mockStorage.EXPECT().CatalogVersion().Return(int64(1), time.Time{}, nil).AnyTimes()
+	gomock.InOrder(
+		mockStorage.EXPECT().GetAllFilms("rating").Return(nil, errors.New("database error")),
+		mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "Film 1"}}, nil),
+	)
+
+	if _, err := storage.GetAllFilms("rating"); err == nil {
+		t.Fatal("expected error")
+	}
+	result, err := storage.GetAllFilms("rating")
+	if err != nil || len(result) != 1 {
+		t.Fatalf("unexpected result: %v, %v", result, err)
+	}
+}
+
+func TestCachedStorage_InvalidatesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	writes := map[string]func(storage *film.CachedStorage, mockStorage *MockStorage){
+		"Add": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			mockStorage.EXPECT().Add(ctx, gomock.Any()).Return(nil)
+			storage.Add(ctx, &film.Film{})
+		},
+		"Update": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			mockStorage.EXPECT().Update(ctx, int64(1), gomock.Any()).Return(&film.Film{}, int64(2), nil)
+			storage.Update(ctx, 1, &film.Film{})
+		},
+		"Patch": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			mockStorage.EXPECT().Patch(ctx, int64(1), gomock.Any()).Return(&film.Film{}, int64(2), nil)
+			storage.Patch(ctx, 1, &film.Film{})
+		},
+		"AddActor": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			mockStorage.EXPECT().AddActor(ctx, int64(1), gomock.Any()).Return(&film.Film{}, int64(2), nil)
+			storage.AddActor(ctx, 1, &actor.Actor{})
+		},
+		"RemoveActor": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			mockStorage.EXPECT().RemoveActor(ctx, int64(1), gomock.Any()).Return(&film.Film{}, int64(2), nil)
+			storage.RemoveActor(ctx, 1, &actor.Actor{})
+		},
+		"Delete": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			mockStorage.EXPECT().Delete(ctx, int64(1), false).Return(nil)
+			storage.Delete(ctx, 1, false)
+		},
+		"Rollback": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			mockStorage.EXPECT().Rollback(ctx, int64(1), 1).Return(nil)
+			storage.Rollback(ctx, 1, 1)
+		},
+		"Invalidate": func(storage *film.CachedStorage, mockStorage *MockStorage) {
+			storage.Invalidate()
+		},
+	}
+
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := NewMockStorage(ctrl)
+			storage := film.NewCachedStorage(mockStorage, pkg.NewLRU(10, time.Minute))
+
+			mockStorage.EXPECT().CatalogVersion().Return(int64(1), time.Time{}, nil).AnyTimes()
+			gomock.InOrder(
+				mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "Old"}}, nil),
+				mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "New"}}, nil),
+			)
+
+			storage.GetAllFilms("rating")
+			write(storage, mockStorage)
+			result, err := storage.GetAllFilms("rating")
+			if err != nil || len(result) != 1 || result[0].Title != "New" {
+				t.Errorf("expected fresh result after %s, got %v, %v", name, result, err)
+			}
+		})
+	}
+}
+
+func TestCachedStorage_RejectsValueFromOlderCatalogVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	storage := film.NewCachedStorage(mockStorage, pkg.NewLRU(10, time.Minute))
+
+	// каталог изменился мимо CachedStorage, например через репозиторий актеров,
+	// а уведомление еще не пришло
+	gomock.InOrder(
+		mockStorage.EXPECT().CatalogVersion().Return(int64(1), time.Time{}, nil),
+		mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "Old"}}, nil),
+		mockStorage.EXPECT().CatalogVersion().Return(int64(2), time.Time{}, nil),
+		mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "New"}}, nil),
+		mockStorage.EXPECT().CatalogVersion().Return(int64(2), time.Time{}, nil),
+	)
+
+	storage.GetAllFilms("rating")
+	for i := 0; i < 2; i++ {
+		result, err := storage.GetAllFilms("rating")
+		if err != nil || len(result) != 1 || result[0].Title != "New" {
+			t.Errorf("expected fresh result, got %v, %v", result, err)
+		}
+	}
+}
+
+func TestCachedStorage_DropsResultStartedBeforeChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	storage := film.NewCachedStorage(mockStorage, pkg.NewLRU(10, time.Minute))
+
+	// изменение завершилось, пока выполнялся запрос списка: результат помечен
+	// версией, прочитанной до запроса
+	gomock.InOrder(
+		mockStorage.EXPECT().CatalogVersion().Return(int64(1), time.Time{}, nil),
+		mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "Old"}}, nil),
+		mockStorage.EXPECT().CatalogVersion().Return(int64(2), time.Time{}, nil),
+		mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "New"}}, nil),
+	)
+
+	storage.GetAllFilms("rating")
+	result, err := storage.GetAllFilms("rating")
+	if err != nil || len(result) != 1 || result[0].Title != "New" {
+		t.Errorf("expected fresh result, got %v, %v", result, err)
+	}
+}
+
+func TestCachedStorage_BypassesCacheWithoutVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	storage := film.NewCachedStorage(mockStorage, pkg.NewLRU(10, time.Minute))
+
+	mockStorage.EXPECT().CatalogVersion().Return(int64(0), time.Time{}, errors.New("database error")).Times(2)
+	mockStorage.EXPECT().GetAllFilms("rating").Return([]film.Film{{Title: "Film 1"}}, nil).Times(2)
+
+	for i := 0; i < 2; i++ {
+		result, err := storage.GetAllFilms("rating")
+		if err != nil || len(result) != 1 {
+			t.Fatalf("unexpected result: %v, %v", result, err)
+		}
+	}
+}
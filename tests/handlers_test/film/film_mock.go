@@ -5,9 +5,11 @@
 package film
 
 import (
+	context "context"
 	actor "filmoteka/internal/actor"
 	"filmoteka/internal/film"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -51,31 +53,63 @@ func (mr *MockStorageMockRecorder) ActorsListWithFilms() *gomock.Call {
 }
 
 // Add mocks base method.
-func (m *MockStorage) Add(film *film.Film) error {
+func (m *MockStorage) Add(ctx context.Context, film *film.Film) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", film)
+	ret := m.ctrl.Call(m, "Add", ctx, film)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Add indicates an expected call of Add.
-func (mr *MockStorageMockRecorder) Add(film interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Add(ctx, film interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockStorage)(nil).Add), film)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockStorage)(nil).Add), ctx, film)
+}
+
+// AddActor mocks base method.
+func (m *MockStorage) AddActor(ctx context.Context, filmId int64, newActor *actor.Actor) (*film.Film, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddActor", ctx, filmId, newActor)
+	ret0, _ := ret[0].(*film.Film)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AddActor indicates an expected call of AddActor.
+func (mr *MockStorageMockRecorder) AddActor(ctx, filmId, newActor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddActor", reflect.TypeOf((*MockStorage)(nil).AddActor), ctx, filmId, newActor)
+}
+
+// CatalogVersion mocks base method.
+func (m *MockStorage) CatalogVersion() (int64, time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CatalogVersion")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(time.Time)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CatalogVersion indicates an expected call of CatalogVersion.
+func (mr *MockStorageMockRecorder) CatalogVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CatalogVersion", reflect.TypeOf((*MockStorage)(nil).CatalogVersion))
 }
 
 // Delete mocks base method.
-func (m *MockStorage) Delete(filmId int64) error {
+func (m *MockStorage) Delete(ctx context.Context, filmId int64, cascade bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", filmId)
+	ret := m.ctrl.Call(m, "Delete", ctx, filmId, cascade)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockStorageMockRecorder) Delete(filmId interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Delete(ctx, filmId, cascade interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorage)(nil).Delete), filmId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorage)(nil).Delete), ctx, filmId, cascade)
 }
 
 // FindFilms mocks base method.
@@ -108,6 +142,22 @@ func (mr *MockStorageMockRecorder) GetAllFilms(sortCol interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllFilms", reflect.TypeOf((*MockStorage)(nil).GetAllFilms), sortCol)
 }
 
+// GetFilm mocks base method.
+func (m *MockStorage) GetFilm(filmId int64) (*film.Film, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFilm", filmId)
+	ret0, _ := ret[0].(*film.Film)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetFilm indicates an expected call of GetFilm.
+func (mr *MockStorageMockRecorder) GetFilm(filmId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFilm", reflect.TypeOf((*MockStorage)(nil).GetFilm), filmId)
+}
+
 // GetFilmId mocks base method.
 func (m *MockStorage) GetFilmId(film *film.Film) (int64, error) {
 	m.ctrl.T.Helper()
@@ -123,16 +173,94 @@ func (mr *MockStorageMockRecorder) GetFilmId(film interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFilmId", reflect.TypeOf((*MockStorage)(nil).GetFilmId), film)
 }
 
-// Update mocks base method.
-func (m *MockStorage) Update(filmId int64, newFilm *film.Film) error {
+// Patch mocks base method.
+func (m *MockStorage) Patch(ctx context.Context, filmId int64, patched *film.Film) (*film.Film, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", ctx, filmId, patched)
+	ret0, _ := ret[0].(*film.Film)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Patch indicates an expected call of Patch.
+func (mr *MockStorageMockRecorder) Patch(ctx, filmId, patched interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockStorage)(nil).Patch), ctx, filmId, patched)
+}
+
+// RemoveActor mocks base method.
+func (m *MockStorage) RemoveActor(ctx context.Context, filmId int64, oldActor *actor.Actor) (*film.Film, int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", filmId, newFilm)
+	ret := m.ctrl.Call(m, "RemoveActor", ctx, filmId, oldActor)
+	ret0, _ := ret[0].(*film.Film)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RemoveActor indicates an expected call of RemoveActor.
+func (mr *MockStorageMockRecorder) RemoveActor(ctx, filmId, oldActor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveActor", reflect.TypeOf((*MockStorage)(nil).RemoveActor), ctx, filmId, oldActor)
+}
+
+// Revision mocks base method.
+func (m *MockStorage) Revision(filmId int64, revision int) (*film.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revision", filmId, revision)
+	ret0, _ := ret[0].(*film.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revision indicates an expected call of Revision.
+func (mr *MockStorageMockRecorder) Revision(filmId, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revision", reflect.TypeOf((*MockStorage)(nil).Revision), filmId, revision)
+}
+
+// Revisions mocks base method.
+func (m *MockStorage) Revisions(filmId int64) ([]film.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revisions", filmId)
+	ret0, _ := ret[0].([]film.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revisions indicates an expected call of Revisions.
+func (mr *MockStorageMockRecorder) Revisions(filmId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revisions", reflect.TypeOf((*MockStorage)(nil).Revisions), filmId)
+}
+
+// Rollback mocks base method.
+func (m *MockStorage) Rollback(ctx context.Context, filmId int64, revision int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback", ctx, filmId, revision)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// Rollback indicates an expected call of Rollback.
+func (mr *MockStorageMockRecorder) Rollback(ctx, filmId, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockStorage)(nil).Rollback), ctx, filmId, revision)
+}
+
+// Update mocks base method.
+func (m *MockStorage) Update(ctx context.Context, filmId int64, newFilm *film.Film) (*film.Film, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, filmId, newFilm)
+	ret0, _ := ret[0].(*film.Film)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
 // Update indicates an expected call of Update.
-func (mr *MockStorageMockRecorder) Update(filmId, newFilm interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Update(ctx, filmId, newFilm interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorage)(nil).Update), filmId, newFilm)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorage)(nil).Update), ctx, filmId, newFilm)
 }
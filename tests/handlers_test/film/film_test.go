@@ -2,14 +2,21 @@ package film
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"filmoteka/internal/actor"
 	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	actorTest "filmoteka/tests/handlers_test/actor"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestFilmHandler_AddFilm(t *testing.T) {
@@ -27,7 +34,7 @@ func TestFilmHandler_AddFilm(t *testing.T) {
 		ReleaseDate: "20.03.2024",
 		Rating:      8,
 	}
-	mockStorage.EXPECT().Add(testFilm).Return(nil)
+	mockStorage.EXPECT().Add(gomock.Any(), testFilm).Return(nil)
 
 	reqBody, err := json.Marshal(testFilm)
 	if err != nil {
@@ -66,8 +73,8 @@ func TestFilmHandler_UpdateFilm(t *testing.T) {
 		ReleaseDate: "01.01.2022",
 		Rating:      8,
 		Actors: []actor.Actor{
-			{Name: "Actor 1", BirthDate: "01.01.1990"},
-			{Name: "Actor 2", BirthDate: "02.01.1990"},
+			{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"},
+			{Name: "Actor 2", Gender: "woman", BirthDate: "02.01.1990"},
 		},
 	}
 	newFilm := film.Film{
@@ -76,8 +83,8 @@ func TestFilmHandler_UpdateFilm(t *testing.T) {
 		ReleaseDate: "01.01.2023",
 		Rating:      9,
 		Actors: []actor.Actor{
-			{Name: "Actor 3", BirthDate: "03.01.1990"},
-			{Name: "Actor 4", BirthDate: "04.01.1990"},
+			{Name: "Actor 3", Gender: "man", BirthDate: "03.01.1990"},
+			{Name: "Actor 4", Gender: "woman", BirthDate: "04.01.1990"},
 		},
 	}
 
@@ -88,7 +95,7 @@ func TestFilmHandler_UpdateFilm(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().GetFilmId(&oldFilm).Return(int64(1), nil)
-	mockStorage.EXPECT().Update(int64(1), &newFilm).Return(nil)
+	mockStorage.EXPECT().Update(gomock.Any(), int64(1), &newFilm).Return(&newFilm, int64(2), nil)
 
 	req, err := http.NewRequest("POST", "/films", bytes.NewReader(filmJSON))
 	if err != nil {
@@ -103,10 +110,424 @@ func TestFilmHandler_UpdateFilm(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
 	}
 
-	expectedResponse := "film updated"
+	expectedResponse := `{"title":"New Film","description":"New Description","release_date":"01.01.2023","rating":9,` +
+		`"actors":[{"name":"Actor 3","gender":"man","birth_date":"03.01.1990"},{"name":"Actor 4","gender":"woman","birth_date":"04.01.1990"}]}`
 	if rr.Body.String() != expectedResponse {
 		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
 	}
+	if etag := rr.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("expected ETag %q, got %q", `"2"`, etag)
+	}
+
+	// фильм изменили после того, как клиент получил версию 1
+	mockStorage.EXPECT().GetFilmId(&oldFilm).Return(int64(1), nil)
+	mockStorage.EXPECT().Update(ifMatch{`"1"`}, int64(1), &newFilm).
+		Return(nil, int64(0), fmt.Errorf("film_repo.UpdateFilm: %w", pkg.ErrPreconditionFailed))
+
+	req = httptest.NewRequest("PUT", "/admin/film/update", bytes.NewReader(filmJSON))
+	req.Header.Set("If-Match", `"1"`)
+	rr = httptest.NewRecorder()
+
+	handler.UpdateFilm(rr, req)
+
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got %d", http.StatusPreconditionFailed, rr.Code)
+	}
+}
+
+// ifMatch проверяет, что в контексте передано условие If-Match с версией.
+type ifMatch struct {
+	etag string
+}
+
+func (m ifMatch) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	if !ok {
+		return false
+	}
+	version, err := strconv.ParseInt(strings.Trim(m.etag, `"`), 10, 64)
+	if err != nil {
+		return false
+	}
+	return pkg.CheckVersion(ctx, version) == nil && pkg.CheckVersion(ctx, version+1) != nil
+}
+
+func (m ifMatch) String() string {
+	return "context with If-Match " + m.etag
+}
+
+func TestFilmHandler_GetFilm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	filmKey := &film.Film{Title: "Film 1", ReleaseDate: "01.01.2022"}
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().GetFilm(int64(1)).Return(&film.Film{Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8}, int64(5), nil)
+
+	req := httptest.NewRequest("GET", "/user/film?title=Film+1&release_date=01.01.2022", nil)
+	rr := httptest.NewRecorder()
+	handler.GetFilm(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	expectedResponse := `{"title":"Film 1","release_date":"01.01.2022","rating":8}`
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"5"` {
+		t.Errorf("expected ETag %q, got %q", `"5"`, etag)
+	}
+	if location := rr.Header().Get("Content-Location"); location != "/user/films/1" {
+		t.Errorf("expected Content-Location %q, got %q", "/user/films/1", location)
+	}
+}
+
+func TestFilmHandler_ByIdRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+	router := pkg.ByPath(map[string]http.HandlerFunc{
+		"/user/films/{id}":                handler.GetFilm,
+		"/user/films/{id}/revisions":      handler.GetRevisions,
+		"/user/films/{id}/revisions/diff": handler.DiffRevisions,
+	})
+
+	// фильм по id ищется без GetFilmId
+	mockStorage.EXPECT().GetFilm(int64(7)).Return(&film.Film{Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8}, int64(2), nil)
+	rr := httptest.NewRecorder()
+	router(rr, httptest.NewRequest("GET", "/user/films/7", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Location") != "/user/films/7" || rr.Header().Get("Content-Type") != pkg.JSONContentType {
+		t.Errorf("unexpected response: %d %v", rr.Code, rr.Header())
+	}
+
+	// ревизии удаленного фильма доступны по id
+	mockStorage.EXPECT().Revisions(int64(7)).Return([]film.Revision{
+		{Revision: 1, Action: film.RevisionInitial, Film: film.Film{Title: "Film 1"}},
+		{Revision: 2, Action: film.RevisionDelete, Film: film.Film{Title: "Film 1"}},
+	}, nil)
+	rr = httptest.NewRecorder()
+	router(rr, httptest.NewRequest("GET", "/user/films/7/revisions", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"action":"delete"`) {
+		t.Errorf("unexpected response: %d %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != pkg.JSONContentType {
+		t.Errorf("expected Content-Type %q, got %q", pkg.JSONContentType, ct)
+	}
+
+	// у неизвестного фильма нет истории
+	mockStorage.EXPECT().Revisions(int64(8)).Return(nil, pkg.DBError(sql.ErrNoRows))
+	rr = httptest.NewRecorder()
+	router(rr, httptest.NewRequest("GET", "/user/films/8/revisions", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	mockStorage.EXPECT().Revision(int64(7), 1).Return(&film.Revision{Revision: 1, Film: film.Film{Rating: 8}}, nil)
+	mockStorage.EXPECT().Revision(int64(7), 2).Return(&film.Revision{Revision: 2, Film: film.Film{Rating: 9}}, nil)
+	rr = httptest.NewRecorder()
+	router(rr, httptest.NewRequest("GET", "/user/films/7/revisions/diff?from=1&to=2", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != pkg.JSONContentType {
+		t.Errorf("unexpected response: %d %v", rr.Code, rr.Header())
+	}
+
+	for _, path := range []string{"/user/films/abc", "/user/films/0/revisions"} {
+		rr = httptest.NewRecorder()
+		router(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestFilmHandler_AddRemoveFilmActor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	filmKey := &film.Film{Title: "Film 1", ReleaseDate: "01.01.2022"}
+	newActor := actor.Actor{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"}
+	actorJSON, err := json.Marshal(newActor)
+	if err != nil {
+		t.Fatalf("failed to marshal actor data: %v", err)
+	}
+
+	// актер добавляется в фильм
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().AddActor(gomock.Any(), int64(1), &newActor).Return(&film.Film{
+		Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8, Actors: []actor.Actor{newActor},
+	}, int64(2), nil)
+
+	req := httptest.NewRequest("POST", "/admin/film/actor?title=Film+1&release_date=01.01.2022", bytes.NewReader(actorJSON))
+	rr := httptest.NewRecorder()
+	handler.AddFilmActor(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	expectedResponse := `{"title":"Film 1","release_date":"01.01.2022","rating":8,"actors":[{"name":"Actor 1","gender":"man","birth_date":"01.01.1990"}]}`
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+
+	// актер уже есть в фильме
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().AddActor(gomock.Any(), int64(1), &newActor).
+		Return(nil, int64(0), fmt.Errorf("film_repo.AddActor: %w", film.ErrActorInFilm))
+
+	req = httptest.NewRequest("POST", "/admin/film/actor?title=Film+1&release_date=01.01.2022", bytes.NewReader(actorJSON))
+	rr = httptest.NewRecorder()
+	handler.AddFilmActor(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+
+	// актер убирается из фильма
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().RemoveActor(gomock.Any(), int64(1), &newActor).Return(&film.Film{
+		Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8,
+	}, int64(2), nil)
+
+	req = httptest.NewRequest("DELETE", "/admin/film/actor?title=Film+1&release_date=01.01.2022", bytes.NewReader(actorJSON))
+	rr = httptest.NewRecorder()
+	handler.RemoveFilmActor(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	expectedResponse = `{"title":"Film 1","release_date":"01.01.2022","rating":8}`
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+
+	// актера нет в фильме
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().RemoveActor(gomock.Any(), int64(1), &newActor).
+		Return(nil, int64(0), fmt.Errorf("film_repo.RemoveActor: %w", film.ErrActorNotInFilm))
+
+	req = httptest.NewRequest("DELETE", "/admin/film/actor?title=Film+1&release_date=01.01.2022", bytes.NewReader(actorJSON))
+	rr = httptest.NewRecorder()
+	handler.RemoveFilmActor(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestFilmHandler_FilmActorByIdRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	mockActors := actorTest.NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo:  mockStorage,
+		ActorRepo: mockActors,
+	}
+	router := pkg.ByPath(map[string]http.HandlerFunc{
+		"/admin/films/{id}/actors/{actorId}": pkg.ByMethod(map[string]http.HandlerFunc{
+			http.MethodPost:   handler.AddFilmActor,
+			http.MethodDelete: handler.RemoveFilmActor,
+		}),
+	})
+
+	existing := actor.Actor{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"}
+
+	// фильм и актер берутся из пути, тело не нужно
+	mockActors.EXPECT().Get(int64(2)).Return(&existing, int64(1), nil)
+	mockStorage.EXPECT().AddActor(gomock.Any(), int64(7), &existing).Return(&film.Film{
+		Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8, Actors: []actor.Actor{existing},
+	}, int64(3), nil)
+	rr := httptest.NewRecorder()
+	router(rr, httptest.NewRequest("POST", "/admin/films/7/actors/2", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Location") != "/user/films/7" {
+		t.Errorf("unexpected response: %d %v", rr.Code, rr.Header())
+	}
+
+	mockActors.EXPECT().Get(int64(2)).Return(&existing, int64(1), nil)
+	mockStorage.EXPECT().RemoveActor(gomock.Any(), int64(7), &existing).Return(&film.Film{
+		Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8,
+	}, int64(4), nil)
+	rr = httptest.NewRecorder()
+	router(rr, httptest.NewRequest("DELETE", "/admin/films/7/actors/2", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// неизвестный актер
+	mockActors.EXPECT().Get(int64(3)).Return(nil, int64(0), fmt.Errorf("actor_repo.Get: %w", pkg.DBError(sql.ErrNoRows)))
+	rr = httptest.NewRecorder()
+	router(rr, httptest.NewRequest("POST", "/admin/films/7/actors/3", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router(rr, httptest.NewRequest("POST", "/admin/films/7/actors/abc", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestFilmHandler_PatchFilm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	filmKey := &film.Film{Title: "Film 1", ReleaseDate: "01.01.2022"}
+	current := &film.Film{Title: "Film 1", Description: "Description", ReleaseDate: "01.01.2022", Rating: 8,
+		Actors: []actor.Actor{{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"}}}
+	patched := *current
+	patched.Rating = 9
+
+	// merge patch меняет только рейтинг; без If-Match сохраняется только прочитанная версия
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().GetFilm(int64(1)).Return(current, int64(1), nil)
+	mockStorage.EXPECT().Patch(ifMatch{`"1"`}, int64(1), &patched).Return(&patched, int64(2), nil)
+
+	req := httptest.NewRequest("PATCH", "/admin/film/patch?title=Film+1&release_date=01.01.2022", strings.NewReader(`{"rating":9}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	rr := httptest.NewRecorder()
+	handler.PatchFilm(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	expectedResponse := `{"title":"Film 1","description":"Description","release_date":"01.01.2022","rating":9,"actors":[{"name":"Actor 1","gender":"man","birth_date":"01.01.1990"}]}`
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("expected ETag %q, got %q", `"2"`, etag)
+	}
+
+	// JSON Patch с проверкой текущего значения и версией клиента
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().GetFilm(int64(1)).Return(current, int64(1), nil)
+	mockStorage.EXPECT().Patch(ifMatch{`"1"`}, int64(1), &patched).Return(&patched, int64(2), nil)
+
+	req = httptest.NewRequest("PATCH", "/admin/film/patch?title=Film+1&release_date=01.01.2022",
+		strings.NewReader(`[{"op":"test","path":"/rating","value":8},{"op":"replace","path":"/rating","value":9}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	rr = httptest.NewRecorder()
+	handler.PatchFilm(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// проверка test не прошла
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().GetFilm(int64(1)).Return(current, int64(1), nil)
+
+	req = httptest.NewRequest("PATCH", "/admin/film/patch?title=Film+1&release_date=01.01.2022",
+		strings.NewReader(`[{"op":"test","path":"/rating","value":7},{"op":"replace","path":"/rating","value":9}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	rr = httptest.NewRecorder()
+	handler.PatchFilm(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+
+	// неизвестное поле
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().GetFilm(int64(1)).Return(current, int64(1), nil)
+
+	req = httptest.NewRequest("PATCH", "/admin/film/patch?title=Film+1&release_date=01.01.2022", strings.NewReader(`{"ratng":9}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	rr = httptest.NewRecorder()
+	handler.PatchFilm(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// обычный JSON не принимается
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().GetFilm(int64(1)).Return(current, int64(1), nil)
+
+	req = httptest.NewRequest("PATCH", "/admin/film/patch?title=Film+1&release_date=01.01.2022", strings.NewReader(`{"rating":9}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	handler.PatchFilm(rr, req)
+
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status %d, got %d", http.StatusUnsupportedMediaType, rr.Code)
+	}
+	if accept := rr.Header().Get("Accept-Patch"); accept != "application/merge-patch+json, application/json-patch+json" {
+		t.Errorf("unexpected Accept-Patch header %q", accept)
+	}
+}
+
+func TestFilmHandler_PatchFilmValidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	filmKey := &film.Film{Title: "Film 1", ReleaseDate: "01.01.2022"}
+	current := &film.Film{Title: "Film 1", Description: "Description", ReleaseDate: "01.01.2022", Rating: 8,
+		Actors: []actor.Actor{{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"}}}
+
+	// проверяется весь документ после патча, до базы он не доходит
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		field       string
+	}{
+		{"rating removed", "application/merge-patch+json", `{"rating":null}`, "rating"},
+		{"rating out of range", "application/merge-patch+json", `{"rating":11}`, "rating"},
+		{"actor gender", "application/json-patch+json", `[{"op":"replace","path":"/actors/0/gender","value":"other"}]`, "actors[0].gender"},
+		{"rating replaced by null", "application/json-patch+json", `[{"op":"replace","path":"/rating","value":null}]`, "rating"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+			mockStorage.EXPECT().GetFilm(int64(1)).Return(current, int64(1), nil)
+
+			req := httptest.NewRequest("PATCH", "/admin/film/patch?title=Film+1&release_date=01.01.2022", strings.NewReader(tt.patch))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			handler.PatchFilm(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), `"field":"`+tt.field+`"`) {
+				t.Errorf("expected error for field %s, got %s", tt.field, rr.Body.String())
+			}
+		})
+	}
 }
 
 func TestFilmHandler_DeleteFilm(t *testing.T) {
@@ -125,8 +546,8 @@ func TestFilmHandler_DeleteFilm(t *testing.T) {
 		ReleaseDate: "01.01.2022",
 		Rating:      8,
 		Actors: []actor.Actor{
-			{Name: "Actor 1", BirthDate: "01.01.1990"},
-			{Name: "Actor 2", BirthDate: "02.01.1990"},
+			{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"},
+			{Name: "Actor 2", Gender: "woman", BirthDate: "02.01.1990"},
 		},
 	}
 	filmJSON, err := json.Marshal(filmToDelete)
@@ -135,7 +556,7 @@ func TestFilmHandler_DeleteFilm(t *testing.T) {
 	}
 
 	mockStorage.EXPECT().GetFilmId(&filmToDelete).Return(int64(1), nil)
-	mockStorage.EXPECT().Delete(int64(1)).Return(nil)
+	mockStorage.EXPECT().Delete(gomock.Any(), int64(1), false).Return(nil)
 
 	req, err := http.NewRequest("POST", "/films", bytes.NewReader(filmJSON))
 	if err != nil {
@@ -156,6 +577,64 @@ func TestFilmHandler_DeleteFilm(t *testing.T) {
 	}
 }
 
+func TestFilmHandler_DeleteFilm_Referenced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	filmToDelete := film.Film{
+		Title:       "Film to delete",
+		Description: "Description to delete",
+		ReleaseDate: "01.01.2022",
+		Rating:      8,
+	}
+	filmJSON, err := json.Marshal(filmToDelete)
+	if err != nil {
+		t.Fatalf("failed to marshal film data: %v", err)
+	}
+	refErr := &film.ReferencedError{Actors: []actor.Actor{{Name: "Actor 1", Gender: "man", BirthDate: "01.01.1990"}}}
+
+	mockStorage.EXPECT().GetFilmId(&filmToDelete).Return(int64(1), nil)
+	mockStorage.EXPECT().Delete(gomock.Any(), int64(1), false).Return(fmt.Errorf("film_repo.DeleteFilm: %w", refErr))
+
+	req, err := http.NewRequest("DELETE", "/admin/film/delete", bytes.NewReader(filmJSON))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+
+	handler.DeleteFilm(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+	expectedResponse := `{"type":"about:blank","title":"Conflict","status":409,"detail":"film is referenced by 1 actors","instance":"/admin/film/delete","actors":[{"name":"Actor 1","gender":"man","birth_date":"01.01.1990"}]}`
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+
+	// с cascade фильм удаляется вместе со связями
+	mockStorage.EXPECT().GetFilmId(&filmToDelete).Return(int64(1), nil)
+	mockStorage.EXPECT().Delete(gomock.Any(), int64(1), true).Return(nil)
+
+	req, err = http.NewRequest("DELETE", "/admin/film/delete?cascade=true", bytes.NewReader(filmJSON))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	rr = httptest.NewRecorder()
+
+	handler.DeleteFilm(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
 func TestFilmHandler_GetAllFilms(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -171,6 +650,7 @@ func TestFilmHandler_GetAllFilms(t *testing.T) {
 		t.Fatalf("failed to create request: %v", err)
 	}
 
+	mockStorage.EXPECT().CatalogVersion().Return(int64(7), time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), nil)
 	mockStorage.EXPECT().GetAllFilms("title").Return([]film.Film{
 		{Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8},
 		{Title: "Film 2", ReleaseDate: "02.01.2022", Rating: 7},
@@ -188,6 +668,15 @@ func TestFilmHandler_GetAllFilms(t *testing.T) {
 	if rr.Body.String() != expectedResponse {
 		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
 	}
+	if etag := rr.Header().Get("ETag"); etag != `W/"7"` {
+		t.Errorf("expected ETag %q, got %q", `W/"7"`, etag)
+	}
+	if modified := rr.Header().Get("Last-Modified"); modified != "Fri, 01 Mar 2024 12:00:00 GMT" {
+		t.Errorf("unexpected Last-Modified %q", modified)
+	}
+	if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != "private, no-cache" {
+		t.Errorf("unexpected Cache-Control %q", cacheControl)
+	}
 }
 
 func TestFilmHandler_FindFilms(t *testing.T) {
@@ -207,6 +696,7 @@ func TestFilmHandler_FindFilms(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
+	mockStorage.EXPECT().CatalogVersion().Return(int64(7), time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), nil)
 	mockStorage.EXPECT().FindFilms("Test").Return([]film.Film{
 		{Title: "Test Film 1", ReleaseDate: "01.01.2022", Rating: 8},
 		{Title: "Test Film 2", ReleaseDate: "02.01.2022", Rating: 7},
@@ -223,3 +713,241 @@ func TestFilmHandler_FindFilms(t *testing.T) {
 		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
 	}
 }
+
+func TestFilmHandler_ConditionalGet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	// каталог не изменился: список не читается из базы
+	mockStorage.EXPECT().CatalogVersion().Return(int64(7), modified, nil)
+
+	req := httptest.NewRequest("GET", "/user/film/filmsList", nil)
+	req.Header.Set("If-None-Match", `W/"6", W/"7"`)
+	rr := httptest.NewRecorder()
+	handler.GetAllFilms(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", rr.Body.String())
+	}
+
+	// If-Modified-Since с временем из Last-Modified
+	mockStorage.EXPECT().CatalogVersion().Return(int64(7), modified, nil)
+
+	req = httptest.NewRequest("GET", "/user/film/actorsListWithFilms", nil)
+	req.Header.Set("If-Modified-Since", "Fri, 01 Mar 2024 12:00:00 GMT")
+	rr = httptest.NewRecorder()
+	handler.ActorsListWithFilms(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+	}
+
+	// If-None-Match важнее If-Modified-Since
+	mockStorage.EXPECT().CatalogVersion().Return(int64(8), modified, nil)
+	mockStorage.EXPECT().FindFilms("Test").Return([]film.Film{}, nil)
+
+	req = httptest.NewRequest("GET", "/user/film/findFilms?find=Test", nil)
+	req.Header.Set("If-None-Match", `W/"7"`)
+	req.Header.Set("If-Modified-Since", "Fri, 01 Mar 2024 12:00:00 GMT")
+	rr = httptest.NewRecorder()
+	handler.FindFilms(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if etag := rr.Header().Get("ETag"); etag != `W/"8"` {
+		t.Errorf("expected ETag %q, got %q", `W/"8"`, etag)
+	}
+
+	// фильм сравнивается по своей версии
+	filmKey := &film.Film{Title: "Film 1", ReleaseDate: "01.01.2022"}
+	mockStorage.EXPECT().GetFilmId(filmKey).Return(int64(1), nil)
+	mockStorage.EXPECT().GetFilm(int64(1)).Return(&film.Film{Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8}, int64(5), nil)
+
+	req = httptest.NewRequest("GET", "/user/film?title=Film+1&release_date=01.01.2022", nil)
+	req.Header.Set("If-None-Match", `"5"`)
+	rr = httptest.NewRecorder()
+	handler.GetFilm(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+	}
+}
+
+func TestFilmHandler_DiffRevisions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	req, err := http.NewRequest("GET", "/user/film/revisionsDiff?title=Film+1&release_date=01.01.2022&from=1&to=2", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	mockStorage.EXPECT().GetFilmId(&film.Film{Title: "Film 1", ReleaseDate: "01.01.2022"}).Return(int64(1), nil)
+	mockStorage.EXPECT().Revision(int64(1), 1).Return(&film.Revision{
+		Revision: 1,
+		Film:     film.Film{Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 8},
+	}, nil)
+	mockStorage.EXPECT().Revision(int64(1), 2).Return(&film.Revision{
+		Revision: 2,
+		Film:     film.Film{Title: "Film 1", ReleaseDate: "01.01.2022", Rating: 9},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+
+	handler.DiffRevisions(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	expectedResponse := `{"from":1,"to":2,"diff":{"rating":{"old":8,"new":9}}}`
+	if rr.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, rr.Body.String())
+	}
+}
+
+func TestFilmHandler_RollbackFilm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	mockStorage.EXPECT().GetFilmId(&film.Film{Title: "Film 1", ReleaseDate: "01.01.2022"}).Return(int64(1), nil)
+	mockStorage.EXPECT().Revision(int64(1), 5).Return(nil, fmt.Errorf("film_repo.Revision: %w", pkg.DBError(sql.ErrNoRows)))
+
+	req, err := http.NewRequest("PUT", "/admin/film/rollback?title=Film+1&release_date=01.01.2022&revision=5", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	handler.RollbackFilm(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestFilmHandler_AddFilmErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"conflict", pkg.NewError(pkg.ErrConflict, "already exists"), http.StatusConflict},
+		{"validation", pkg.NewError(pkg.ErrValidation, "violates a check constraint"), http.StatusUnprocessableEntity},
+		{"internal", fmt.Errorf("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := NewMockStorage(ctrl)
+			handler := &film.FilmHandler{
+				FilmRepo: mockStorage,
+			}
+
+			testFilm := &film.Film{Title: "Test Film", Description: "Test description", ReleaseDate: "20.03.2024", Rating: 8}
+			mockStorage.EXPECT().Add(gomock.Any(), testFilm).Return(fmt.Errorf("film_repo.AddFilm: %w", tt.err))
+
+			reqBody, _ := json.Marshal(testFilm)
+			w := httptest.NewRecorder()
+			handler.AddFilm(w, httptest.NewRequest("POST", "/user/film/add", bytes.NewReader(reqBody)))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestFilmHandler_UpdateFilmNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	filmInfo := []film.Film{
+		{Title: "Old Film", ReleaseDate: "01.01.2022"},
+		{Title: "New Film", Description: "New Description", ReleaseDate: "01.01.2022", Rating: 7},
+	}
+	mockStorage.EXPECT().GetFilmId(&filmInfo[0]).Return(int64(0), fmt.Errorf("film_repo.GetFilmId: %w", pkg.DBError(sql.ErrNoRows)))
+
+	reqBody, _ := json.Marshal(filmInfo)
+	w := httptest.NewRecorder()
+	handler.UpdateFilm(w, httptest.NewRequest("PUT", "/admin/film/update", bytes.NewReader(reqBody)))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	var problem pkg.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("can't decode problem: %v", err)
+	}
+	if problem.Status != http.StatusNotFound || problem.Detail != "can't find film: not found" {
+		t.Errorf("unexpected problem %+v", problem)
+	}
+}
+
+func TestFilmHandler_AddFilmValidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// до хранилища невалидный фильм не доходит
+	mockStorage := NewMockStorage(ctrl)
+	handler := &film.FilmHandler{
+		FilmRepo: mockStorage,
+	}
+
+	invalidFilm := film.Film{
+		ReleaseDate: "32.01.2024",
+		Rating:      11,
+		Actors:      []actor.Actor{{Name: "Actor 1", Gender: "other", BirthDate: "01.01.1990"}},
+	}
+	reqBody, _ := json.Marshal(invalidFilm)
+	w := httptest.NewRecorder()
+	handler.AddFilm(w, httptest.NewRequest("POST", "/user/film/add", bytes.NewReader(reqBody)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	var problem pkg.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("can't decode problem: %v", err)
+	}
+	var fields []string
+	for _, e := range problem.Errors {
+		fields = append(fields, e.Field)
+	}
+	expectedFields := "title description release_date rating actors[0].gender"
+	if strings.Join(fields, " ") != expectedFields {
+		t.Errorf("expected errors for %q, got %+v", expectedFields, problem.Errors)
+	}
+}
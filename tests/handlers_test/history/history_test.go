@@ -0,0 +1,211 @@
+package historyTest
+
+import (
+	"encoding/json"
+	"filmoteka/internal/auth"
+	"filmoteka/internal/film"
+	"filmoteka/internal/history"
+	"filmoteka/pkg"
+	filmTest "filmoteka/tests/handlers_test/film"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistoryHandler_ImportHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &history.HistoryHandler{
+		HistoryRepo: mockStorage,
+	}
+
+	data := `Date,Name,Year,Letterboxd URI,Rating
+2021-01-02,Film1,2000,https://boxd.it/1,4.5
+2021-01-03,Unknown,2001,https://boxd.it/2,3
+2021-01-04,Film3,20,https://boxd.it/3,3
+`
+	mockStorage.EXPECT().FindFilm("Film1", "2000").Return(int64(1), nil)
+	mockStorage.EXPECT().FindFilm("Unknown", "2001").Return(int64(0), fmt.Errorf("history_repo.FindFilm: %w", history.ErrNoFilm))
+	mockStorage.EXPECT().Save(uint32(5), history.SourceLetterboxd, []history.Match{
+		{FilmID: 1, Entry: history.Entry{Row: 2, Title: "Film1", Year: "2000", Rating: 9}},
+	}).Return(nil)
+
+	req := httptest.NewRequest("POST", "/user/history/import?source=letterboxd", strings.NewReader(data))
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 5}))
+	w := httptest.NewRecorder()
+
+	handler.ImportHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != pkg.JSONContentType {
+		t.Errorf("expected Content-Type %q, got %q", pkg.JSONContentType, ct)
+	}
+
+	var report history.Report
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	expected := history.Report{
+		Matched:   1,
+		Unmatched: 1,
+		Errors:    1,
+		Rows: []history.RowResult{
+			{Row: 2, Title: "Film1", Year: "2000", Status: history.StatusMatched},
+			{Row: 3, Title: "Unknown", Year: "2001", Status: history.StatusUnmatched},
+			{Row: 4, Title: "Film3", Year: "20", Status: history.StatusError, Error: `wrong year "20"`},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected report %+v, got %+v", expected, report)
+	}
+}
+
+func TestHistoryHandler_ImportHistoryCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	mockFilms := filmTest.NewMockStorage(ctrl)
+	handler := &history.HistoryHandler{
+		HistoryRepo: mockStorage,
+		FilmRepo:    mockFilms,
+	}
+
+	data := `[
+		{"rating": 8, "movie": {"title": "Film1", "year": 2000, "overview": "Description1"}},
+		{"rating": 7, "movie": {"title": "Film2", "year": 2001}}
+	]`
+	newFilm := &film.Film{Title: "Film1", Description: "Description1", ReleaseDate: "2000", Rating: 8}
+	mockStorage.EXPECT().FindFilm("Film1", "2000").Return(int64(0), fmt.Errorf("history_repo.FindFilm: %w", history.ErrNoFilm))
+	mockStorage.EXPECT().FindFilm("Film2", "2001").Return(int64(0), fmt.Errorf("history_repo.FindFilm: %w", history.ErrNoFilm))
+	mockFilms.EXPECT().Add(gomock.Any(), newFilm).Return(nil)
+	mockFilms.EXPECT().GetFilmId(newFilm).Return(int64(3), nil)
+	mockStorage.EXPECT().Save(uint32(1), history.SourceTrakt, []history.Match{
+		{FilmID: 3, Entry: history.Entry{Row: 1, Title: "Film1", Year: "2000", Description: "Description1", Rating: 8}},
+	}).Return(nil)
+
+	req := httptest.NewRequest("POST", "/user/history/import?source=trakt&create=true", strings.NewReader(data))
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 1, IsAdmin: true}))
+	w := httptest.NewRecorder()
+
+	handler.ImportHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var report history.Report
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	// фильм без описания не создается
+	expected := []history.RowResult{
+		{Row: 1, Title: "Film1", Year: "2000", Status: history.StatusCreated},
+		{Row: 2, Title: "Film2", Year: "2001", Status: history.StatusError, Error: "can't create film: no film description in export"},
+	}
+	if !reflect.DeepEqual(report.Rows, expected) {
+		t.Errorf("expected rows %+v, got %+v", expected, report.Rows)
+	}
+}
+
+func TestHistoryHandler_ImportHistoryCreateSaveFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	mockFilms := filmTest.NewMockStorage(ctrl)
+	handler := &history.HistoryHandler{
+		HistoryRepo: mockStorage,
+		FilmRepo:    mockFilms,
+	}
+
+	data := `[{"rating": 8, "movie": {"title": "Film1", "year": 2000, "overview": "Description1"}}]`
+	newFilm := &film.Film{Title: "Film1", Description: "Description1", ReleaseDate: "2000", Rating: 8}
+	mockStorage.EXPECT().FindFilm("Film1", "2000").Return(int64(0), fmt.Errorf("history_repo.FindFilm: %w", history.ErrNoFilm))
+	mockFilms.EXPECT().Add(gomock.Any(), newFilm).Return(nil)
+	mockFilms.EXPECT().GetFilmId(newFilm).Return(int64(3), nil)
+	mockStorage.EXPECT().Save(uint32(1), history.SourceTrakt, gomock.Any()).Return(fmt.Errorf("db_error"))
+
+	req := httptest.NewRequest("POST", "/user/history/import?source=trakt&create=true", strings.NewReader(data))
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 1, IsAdmin: true}))
+	w := httptest.NewRecorder()
+
+	handler.ImportHistory(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	// созданный фильм остается в каталоге, и ответ об этом сообщает
+	var problem history.NotSaved
+	err := json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatalf("failed to unmarshal problem: %v", err)
+	}
+	expected := []history.RowResult{{Row: 1, Title: "Film1", Year: "2000", Status: history.StatusCreated}}
+	if !reflect.DeepEqual(problem.Created, expected) {
+		t.Errorf("expected created %+v, got %+v", expected, problem.Created)
+	}
+}
+
+func TestHistoryHandler_ImportHistoryCreateForbidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	handler := &history.HistoryHandler{
+		HistoryRepo: NewMockStorage(ctrl),
+	}
+
+	req := httptest.NewRequest("POST", "/user/history/import?source=trakt&create=true", strings.NewReader("[]"))
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 5}))
+	w := httptest.NewRecorder()
+
+	handler.ImportHistory(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestHistoryHandler_GetHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &history.HistoryHandler{
+		HistoryRepo: mockStorage,
+	}
+
+	items := []history.Item{
+		{Title: "Film1", ReleaseDate: "01.01.2000", Rating: 9, WatchedAt: []string{"02.01.2021"}},
+	}
+	mockStorage.EXPECT().Get(uint32(5)).Return(items, nil)
+
+	req := httptest.NewRequest("GET", "/user/history", nil)
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 5}))
+	w := httptest.NewRecorder()
+
+	handler.GetHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != pkg.JSONContentType {
+		t.Errorf("expected Content-Type %q, got %q", pkg.JSONContentType, ct)
+	}
+
+	expectedResponse, _ := json.Marshal(items)
+	if w.Body.String() != string(expectedResponse) {
+		t.Errorf("expected response body %s, got %s", expectedResponse, w.Body.String())
+	}
+}
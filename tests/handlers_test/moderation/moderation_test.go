@@ -0,0 +1,205 @@
+package moderationTest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/auth"
+	"filmoteka/internal/film"
+	"filmoteka/internal/moderation"
+	"filmoteka/pkg"
+	actorTest "filmoteka/tests/handlers_test/actor"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModerationHandler_SubmitFilm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &moderation.ModerationHandler{
+		ModerationRepo: mockStorage,
+	}
+
+	testFilm := film.Film{
+		Title:       "Test Film",
+		Description: "Test description",
+		ReleaseDate: "20.03.2024",
+		Rating:      8,
+	}
+	reqBody, err := json.Marshal(testFilm)
+	if err != nil {
+		t.Fatalf("failed to marshal request body: %v", err)
+	}
+
+	mockStorage.EXPECT().Add(&moderation.Submission{
+		UserID: 5,
+		Entity: moderation.EntityFilm,
+		Data:   reqBody,
+	}).Return(int64(12), nil)
+
+	req := httptest.NewRequest("POST", "/user/film/add", bytes.NewReader(reqBody))
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 5}))
+	w := httptest.NewRecorder()
+
+	handler.SubmitFilm(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	expectedResponse := "film submitted for moderation: submission 12"
+	if w.Body.String() != expectedResponse {
+		t.Errorf("expected response body %q, got %q", expectedResponse, w.Body.String())
+	}
+}
+
+func TestModerationHandler_Reject(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &moderation.ModerationHandler{
+		ModerationRepo: mockStorage,
+	}
+
+	submission := &moderation.Submission{
+		ID:     3,
+		UserID: 5,
+		Entity: moderation.EntityActor,
+		Data:   json.RawMessage(`{"name":"John","gender":"man","birth_date":"01.01.1990"}`),
+		Status: moderation.StatusPending,
+	}
+	mockStorage.EXPECT().Get(int64(3)).Return(submission, nil)
+	mockStorage.EXPECT().Review(int64(3), moderation.StatusRejected, submission.Data, uint32(1), "duplicate").Return(nil)
+
+	req := httptest.NewRequest("POST", "/admin/moderation/reject?id=3&reason=duplicate", nil)
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 1, IsAdmin: true}))
+	w := httptest.NewRecorder()
+
+	handler.Reject(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// already reviewed submission can't be rejected again
+	submission.Status = moderation.StatusApproved
+	mockStorage.EXPECT().Get(int64(3)).Return(submission, nil)
+
+	w = httptest.NewRecorder()
+	handler.Reject(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestModerationHandler_Approve(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	mockActors := actorTest.NewMockStorage(ctrl)
+	handler := &moderation.ModerationHandler{
+		ModerationRepo: mockStorage,
+		ActorRepo:      mockActors,
+	}
+
+	submission := &moderation.Submission{
+		ID:     3,
+		UserID: 5,
+		Entity: moderation.EntityActor,
+		Data:   json.RawMessage(`{"name":"John","gender":"man","birth_date":"01.01.1990"}`),
+		Status: moderation.StatusPending,
+	}
+	newActor := &actor.Actor{Name: "John", Gender: "man", BirthDate: "01.01.1990"}
+	data, err := json.Marshal(newActor)
+	if err != nil {
+		t.Fatalf("failed to marshal actor: %v", err)
+	}
+
+	// актер добавляется в каталог вместе с одобрением заявки
+	mockStorage.EXPECT().Get(int64(3)).Return(submission, nil)
+	mockActors.EXPECT().GetActorId(newActor).Return(int64(0), fmt.Errorf("actor_repo.GetByID: %w", pkg.DBError(sql.ErrNoRows)))
+	mockStorage.EXPECT().Approve(gomock.Any(), int64(3), json.RawMessage(data), uint32(1)).Return(nil)
+
+	req := httptest.NewRequest("POST", "/admin/moderation/approve?id=3", nil)
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 1, IsAdmin: true}))
+	w := httptest.NewRecorder()
+
+	handler.Approve(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// одновременное одобрение той же заявки уже ничего не добавляет
+	mockStorage.EXPECT().Get(int64(3)).Return(submission, nil)
+	mockActors.EXPECT().GetActorId(newActor).Return(int64(0), fmt.Errorf("actor_repo.GetByID: %w", pkg.DBError(sql.ErrNoRows)))
+	mockStorage.EXPECT().Approve(gomock.Any(), int64(3), json.RawMessage(data), uint32(1)).
+		Return(fmt.Errorf("moderation_repo.Approve: %w", moderation.ErrAlreadyReviewed))
+
+	w = httptest.NewRecorder()
+	handler.Approve(w, httptest.NewRequest("POST", "/admin/moderation/approve?id=3", nil).WithContext(req.Context()))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestAdminOrSubmit(t *testing.T) {
+	var called string
+	handler := moderation.AdminOrSubmit(
+		func(w http.ResponseWriter, r *http.Request) { called = "admin" },
+		func(w http.ResponseWriter, r *http.Request) { called = "submit" },
+	)
+
+	req := httptest.NewRequest("POST", "/user/film/add", nil)
+	handler(httptest.NewRecorder(), req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 1, IsAdmin: true})))
+	if called != "admin" {
+		t.Errorf("expected admin handler, got %q", called)
+	}
+
+	handler(httptest.NewRecorder(), req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 2})))
+	if called != "submit" {
+		t.Errorf("expected submit handler, got %q", called)
+	}
+}
+
+func TestModerationHandler_MySubmissions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := NewMockStorage(ctrl)
+	handler := &moderation.ModerationHandler{
+		ModerationRepo: mockStorage,
+	}
+
+	mockStorage.EXPECT().FindByUser(uint32(5)).Return([]moderation.Submission{
+		{ID: 12, UserID: 5, Entity: moderation.EntityFilm, Status: moderation.StatusPending},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/user/submissions", nil)
+	req = req.WithContext(auth.ContextWithSession(req.Context(), &auth.Session{UserID: 5}))
+	w := httptest.NewRecorder()
+
+	handler.MySubmissions(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != pkg.JSONContentType {
+		t.Errorf("expected Content-Type %q, got %q", pkg.JSONContentType, ct)
+	}
+
+	var submissions []moderation.Submission
+	if err := json.Unmarshal(w.Body.Bytes(), &submissions); err != nil || len(submissions) != 1 || submissions[0].ID != 12 {
+		t.Errorf("unexpected response body %q: %v", w.Body.String(), err)
+	}
+}
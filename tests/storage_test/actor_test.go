@@ -1,9 +1,15 @@
 package storage_test
 
 import (
+	"context"
+	"errors"
 	"filmoteka/internal/actor"
+	"filmoteka/internal/auth"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
+	"reflect"
 	"testing"
 )
 
@@ -20,7 +26,7 @@ func TestStorageAddActor(t *testing.T) {
 
 	//ok query
 	mock.ExpectExec("INSERT INTO actor").
-		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate).
+		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate, pkg.PrecisionDay, false).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = actorRepo.Add(testActor)
@@ -33,9 +39,24 @@ func TestStorageAddActor(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 
+	//partial date
+	partialActor := &actor.Actor{Name: "Ivan", Gender: "man", BirthDate: "05.1890~"}
+	mock.ExpectExec("INSERT INTO actor").
+		WithArgs(partialActor.Name, partialActor.Gender, "1890-05-01", pkg.PrecisionMonth, true).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	err = actorRepo.Add(partialActor)
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
 	//query error
 	mock.ExpectExec("INSERT INTO actor").
-		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate).
+		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate, pkg.PrecisionDay, false).
 		WillReturnError(fmt.Errorf("bad query"))
 
 	err = actorRepo.Add(testActor)
@@ -66,7 +87,7 @@ func TestStorageGetActorId(t *testing.T) {
 
 	//Good query
 	mock.ExpectQuery("SELECT id FROM actor").
-		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate).
+		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate, pkg.PrecisionDay, false).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1)) // Возвращаем идентификатор актера
 
 	actorID, err := actorRepo.GetActorId(testActor)
@@ -82,7 +103,7 @@ func TestStorageGetActorId(t *testing.T) {
 
 	//query error
 	mock.ExpectQuery("SELECT id FROM actor").
-		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate).
+		WithArgs(testActor.Name, testActor.Gender, testActor.BirthDate, pkg.PrecisionDay, false).
 		WillReturnError(fmt.Errorf("bad query"))
 
 	actorID, err = actorRepo.GetActorId(testActor)
@@ -100,6 +121,25 @@ func TestStorageGetActorId(t *testing.T) {
 
 }
 
+func expectActorVersionBump(mock sqlmock.Sqlmock, actorID int64, version int64) {
+	mock.ExpectQuery("UPDATE actor SET version = version \\+ 1 WHERE id = \\$1 RETURNING version").
+		WithArgs(actorID).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+func expectActorSnapshot(mock sqlmock.Sqlmock, actorID int64, a *actor.Actor) {
+	mock.ExpectQuery("SELECT name, gender, partial_date\\(birth_date, birth_date_precision, birth_date_approx\\) FROM actor WHERE id =").
+		WithArgs(actorID).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "gender", "birth_date"}).
+			AddRow(a.Name, a.Gender, a.BirthDate))
+}
+
+func expectFilmVersionsBump(mock sqlmock.Sqlmock, actorID int64, films int64) {
+	mock.ExpectExec("UPDATE film SET version = version \\+ 1 WHERE id IN \\(SELECT film_id FROM film_actor WHERE actor_id = \\$1\\)").
+		WithArgs(actorID).
+		WillReturnResult(sqlmock.NewResult(0, films))
+}
+
 func TestStorageUpdateActor(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -110,30 +150,51 @@ func TestStorageUpdateActor(t *testing.T) {
 	actorRepo := actor.NewActorRepository(db)
 
 	actorID := int64(1)
+	oldActor := &actor.Actor{
+		Name:      "John",
+		Gender:    "man",
+		BirthDate: "1990-01-01",
+	}
 	newActor := &actor.Actor{
 		Name:      "John Doe",
 		Gender:    "man",
 		BirthDate: "1990-01-01",
 	}
 
-	mock.ExpectQuery("UPDATE actor").
-		WithArgs(newActor.Name, newActor.Gender, newActor.BirthDate, actorID).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, oldActor)
+	mock.ExpectExec("UPDATE actor").
+		WithArgs(newActor.Name, newActor.Gender, newActor.BirthDate, pkg.PrecisionDay, false, actorID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFilmVersionsBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, newActor)
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "actor", actorID, "update", sqlmock.AnyArg(), sqlmock.AnyArg(), `{"name":{"old":"John","new":"John Doe"}}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	err = actorRepo.Update(actorID, newActor)
+	version, err := actorRepo.Update(context.Background(), actorID, newActor)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
+	if version != 2 {
+		t.Errorf("expected version 2, got %d", version)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 
 	//query error
-	mock.ExpectQuery("UPDATE actor").
-		WithArgs(newActor.Name, newActor.Gender, newActor.BirthDate, actorID).
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, oldActor)
+	mock.ExpectExec("UPDATE actor").
+		WithArgs(newActor.Name, newActor.Gender, newActor.BirthDate, pkg.PrecisionDay, false, actorID).
 		WillReturnError(fmt.Errorf("bad query"))
+	mock.ExpectRollback()
 
-	err = actorRepo.Update(actorID, newActor)
+	_, err = actorRepo.Update(context.Background(), actorID, newActor)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
@@ -142,6 +203,22 @@ func TestStorageUpdateActor(t *testing.T) {
 		return
 	}
 
+	// unchanged actor: nothing is written, the version stays the same
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, oldActor)
+	mock.ExpectRollback()
+
+	version, err = actorRepo.Update(context.Background(), actorID, oldActor)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if version != 1 {
+		t.Errorf("expected version 1, got %d", version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestStorageDeleteActor(t *testing.T) {
@@ -154,12 +231,24 @@ func TestStorageDeleteActor(t *testing.T) {
 	actorRepo := actor.NewActorRepository(db)
 
 	actorID := int64(1)
+	testActor := &actor.Actor{Name: "John Doe", Gender: "man", BirthDate: "1990-01-01"}
+
+	// deletion is recorded for the user from the session
+	ctx := auth.ContextWithSession(context.Background(), &auth.Session{UserID: 7})
 
-	mock.ExpectQuery("DELETE FROM actor").
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, testActor)
+	expectActorFilms(mock, actorID)
+	mock.ExpectExec("DELETE FROM actor").
 		WithArgs(actorID).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(uint32(7), "actor", actorID, "delete", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	err = actorRepo.Delete(actorID)
+	err = actorRepo.Delete(ctx, actorID, false)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -168,11 +257,16 @@ func TestStorageDeleteActor(t *testing.T) {
 	}
 
 	//query error
-	mock.ExpectQuery("DELETE FROM actor").
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, testActor)
+	expectActorFilms(mock, actorID)
+	mock.ExpectExec("DELETE FROM actor").
 		WithArgs(actorID).
 		WillReturnError(fmt.Errorf("bad query"))
+	mock.ExpectRollback()
 
-	err = actorRepo.Delete(actorID)
+	err = actorRepo.Delete(ctx, actorID, false)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
@@ -181,3 +275,245 @@ func TestStorageDeleteActor(t *testing.T) {
 		return
 	}
 }
+
+func TestStorageDeleteActor_Referenced(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	actorRepo := actor.NewActorRepository(db)
+
+	actorID := int64(1)
+	testActor := &actor.Actor{Name: "John Doe", Gender: "man", BirthDate: "1990-01-01"}
+	films := []actor.FilmRef{{Title: "Film1", ReleaseDate: "1999"}, {Title: "Film2", ReleaseDate: "01.02.2001"}}
+
+	//referenced actor isn't deleted without cascade
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, testActor)
+	expectActorFilms(mock, actorID, films...)
+	mock.ExpectRollback()
+
+	err = actorRepo.Delete(context.Background(), actorID, false)
+	var refErr *actor.ReferencedError
+	if !errors.As(err, &refErr) {
+		t.Fatalf("expected ReferencedError, got %v", err)
+	}
+	if !reflect.DeepEqual(refErr.Films, films) {
+		t.Errorf("expected films %v, got %v", films, refErr.Films)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	//cascade removes links in the same transaction
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, testActor)
+	expectActorFilms(mock, actorID, films...)
+	expectFilmVersionsBump(mock, actorID, int64(len(films)))
+	mock.ExpectExec("DELETE FROM film_actor WHERE actor_id =").
+		WithArgs(actorID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM actor WHERE id =").
+		WithArgs(actorID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "actor", actorID, "delete", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = actorRepo.Delete(context.Background(), actorID, true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func expectActorFilms(mock sqlmock.Sqlmock, actorID int64, films ...actor.FilmRef) {
+	rows := sqlmock.NewRows([]string{"title", "release_date"})
+	for _, f := range films {
+		rows.AddRow(f.Title, f.ReleaseDate)
+	}
+	mock.ExpectQuery("SELECT f.title, partial_date\\(f.release_date, f.release_date_precision, f.release_date_approx\\) FROM film f").
+		WithArgs(actorID).
+		WillReturnRows(rows)
+}
+
+func TestStoragePatchActor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	actorRepo := actor.NewActorRepository(db)
+
+	actorID := int64(1)
+	oldActor := &actor.Actor{Name: "John Doe", Gender: "man", BirthDate: "01.01.1990"}
+	newActor := &actor.Actor{Name: "John Doe", Gender: "man", BirthDate: "1990~"}
+
+	//only the birth date is updated
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, oldActor)
+	mock.ExpectExec("UPDATE actor SET birth_date = \\$1, birth_date_precision = \\$2, birth_date_approx = \\$3 WHERE id = \\$4").
+		WithArgs("1990-01-01", pkg.PrecisionYear, true, actorID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFilmVersionsBump(mock, actorID, 1)
+	expectActorSnapshot(mock, actorID, newActor)
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "actor", actorID, "update", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	patched, version, err := actorRepo.Patch(context.Background(), actorID, newActor)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(patched, newActor) {
+		t.Errorf("expected %v, got %v", newActor, patched)
+	}
+	if version != 2 {
+		t.Errorf("expected version 2, got %d", version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	//nothing changed
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, oldActor)
+	mock.ExpectRollback()
+
+	patched, version, err = actorRepo.Patch(context.Background(), actorID, &actor.Actor{Name: "John Doe", Gender: "man", BirthDate: "1990-01-01"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(patched, oldActor) {
+		t.Errorf("expected %v, got %v", oldActor, patched)
+	}
+	if version != 1 {
+		t.Errorf("expected version 1, got %d", version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorageDeleteActor_PreconditionFailed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	actorRepo := actor.NewActorRepository(db)
+
+	actorID := int64(1)
+
+	//actor was changed after the client read version 1
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 3)
+	mock.ExpectRollback()
+
+	err = actorRepo.Delete(pkg.WithIfMatch(context.Background(), `"1"`), actorID, false)
+	if !errors.Is(err, pkg.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	//the same version matches
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 3)
+	expectActorSnapshot(mock, actorID, &actor.Actor{Name: "John Doe", Gender: "man", BirthDate: "01.01.1990"})
+	expectActorFilms(mock, actorID)
+	mock.ExpectExec("DELETE FROM actor WHERE id =").
+		WithArgs(actorID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "actor", actorID, "delete", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = actorRepo.Delete(pkg.WithIfMatch(context.Background(), `"1", "2"`), actorID, false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorageDeleteActor_CascadeRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// the film repository records film history for cascade deletes
+	actorRepo := actor.NewActorRepository(db)
+	film.NewFilmRepository(actorRepo, db)
+
+	actorID := int64(1)
+	filmID := int64(7)
+	deleted := &actor.Actor{Name: "John Doe", Gender: "man", BirthDate: "01.01.1990"}
+	expectFilm := func(cast ...actor.Actor) {
+		mock.ExpectQuery("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film WHERE id =").
+			WithArgs(filmID).
+			WillReturnRows(sqlmock.NewRows([]string{"title", "description", "release_date", "rating"}).AddRow("Film", "d", "2020", 5))
+		rows := sqlmock.NewRows([]string{"name", "gender", "birth_date"})
+		for _, a := range cast {
+			rows.AddRow(a.Name, a.Gender, a.BirthDate)
+		}
+		mock.ExpectQuery("SELECT a.name, a.gender, partial_date\\(a.birth_date, a.birth_date_precision, a.birth_date_approx\\) FROM actor a").
+			WithArgs(filmID).
+			WillReturnRows(rows)
+	}
+
+	mock.ExpectBegin()
+	expectActorVersionBump(mock, actorID, 2)
+	expectActorSnapshot(mock, actorID, deleted)
+	expectActorFilms(mock, actorID, actor.FilmRef{Title: "Film", ReleaseDate: "2020"})
+	mock.ExpectQuery("SELECT film_id FROM film_actor WHERE actor_id = \\$1").
+		WithArgs(actorID).
+		WillReturnRows(sqlmock.NewRows([]string{"film_id"}).AddRow(filmID))
+	expectFilm(*deleted)
+	expectFilmVersionsBump(mock, actorID, 1)
+	mock.ExpectExec("DELETE FROM film_actor WHERE actor_id = \\$1").
+		WithArgs(actorID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFilm()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(filmID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(filmID, 2, "update", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", filmID, "update", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM actor WHERE id = \\$1").
+		WithArgs(actorID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "actor", actorID, "delete", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	err = actorRepo.Delete(context.Background(), actorID, true)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
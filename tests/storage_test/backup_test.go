@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
+	"encoding/json"
+	"filmoteka/internal/backup"
+	"github.com/DATA-DOG/go-sqlmock"
+	"io"
+	"strings"
+	"testing"
+)
+
+// emptyTables - таблицы архива, которые в этих тестах пустые, и их колонки.
+var emptyTables = []struct {
+	name    string
+	orderBy string
+	columns []string
+}{
+	{"film_revision", "id", []string{"id"}},
+	{"audit_log", "id", []string{"id"}},
+	{"submission", "id", []string{"id"}},
+	{"user_film_rating", "user_id, film_id", []string{"user_id", "film_id"}},
+	{"user_film_watch", "id", []string{"id"}},
+}
+
+// lockedPassword проверяет, что вместо пароля записан случайный хеш длины auth.HashPass.
+type lockedPassword struct{}
+
+func (lockedPassword) Match(v driver.Value) bool {
+	password, ok := v.([]byte)
+	return ok && len(password) == 40
+}
+
+func expectSchemaVersion(mock sqlmock.Sqlmock, migrations bool) {
+	mock.ExpectQuery(`SELECT to_regclass\('schema_migrations'\) IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(migrations))
+	if migrations {
+		mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").
+			WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(6, false))
+	}
+}
+
+func expectBackupDump(mock sqlmock.Sqlmock, noCredentials bool) {
+	mock.ExpectBegin()
+	expectSchemaVersion(mock, true)
+	mock.ExpectQuery(`SELECT \* FROM "users" ORDER BY id`).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("INT4", int64(0)),
+			sqlmock.NewColumn("login").OfType("VARCHAR", ""),
+			sqlmock.NewColumn("password").OfType("BYTEA", []byte{}),
+			sqlmock.NewColumn("role").OfType("BOOL", false),
+		).AddRow(int64(1), "admin", []byte{0, 1, 2}, true))
+	mock.ExpectQuery(`SELECT \* FROM "actor" ORDER BY id`).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("INT4", int64(0)),
+			sqlmock.NewColumn("name").OfType("VARCHAR", ""),
+			sqlmock.NewColumn("gender").OfType("VARCHAR", ""),
+			sqlmock.NewColumn("birth_date").OfType("VARCHAR", ""),
+			sqlmock.NewColumn("imdb_id").OfType("VARCHAR", ""),
+		).AddRow(int64(2), "Actor1", "man", "12.03.1995", nil))
+	mock.ExpectQuery(`SELECT \* FROM "film" ORDER BY id`).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("INT4", int64(0)),
+			sqlmock.NewColumn("title").OfType("VARCHAR", ""),
+			sqlmock.NewColumn("rating").OfType("INT4", int64(0)),
+		).AddRow(int64(3), "Film1", int64(8)))
+	mock.ExpectQuery(`SELECT \* FROM "film_actor" ORDER BY id`).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("INT4", int64(0)),
+			sqlmock.NewColumn("film_id").OfType("INT4", int64(0)),
+			sqlmock.NewColumn("actor_id").OfType("INT4", int64(0)),
+		).AddRow(int64(4), int64(3), int64(2)))
+	for _, table := range emptyTables {
+		columns := make([]*sqlmock.Column, len(table.columns))
+		for i, name := range table.columns {
+			columns[i] = sqlmock.NewColumn(name).OfType("INT4", int64(0))
+		}
+		mock.ExpectQuery(`SELECT \* FROM "` + table.name + `" ORDER BY ` + table.orderBy).
+			WillReturnRows(sqlmock.NewRowsWithColumnDefinition(columns...))
+	}
+	if !noCredentials {
+		mock.ExpectQuery(`SELECT \* FROM "sessions" ORDER BY id`).
+			WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+				sqlmock.NewColumn("id").OfType("VARCHAR", ""),
+				sqlmock.NewColumn("user_id").OfType("INT4", int64(0)),
+			).AddRow("session1", int64(1)))
+	}
+	mock.ExpectCommit()
+}
+
+func expectRestoreChecks(mock sqlmock.Sqlmock, migrations bool) {
+	mock.ExpectBegin()
+	expectSchemaVersion(mock, migrations)
+	for _, table := range backup.Tables {
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM "` + table.Name + `"\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	}
+}
+
+// expectColumns - колонки таблицы в базе, в которую восстанавливается архив.
+func expectColumns(mock sqlmock.Sqlmock, table string, columns ...string) {
+	mock.ExpectQuery(`SELECT \* FROM "` + table + `" LIMIT 0`).
+		WillReturnRows(sqlmock.NewRows(columns))
+}
+
+func expectEmptyTablesRestore(mock sqlmock.Sqlmock) {
+	for _, table := range emptyTables {
+		expectColumns(mock, table.name, table.columns...)
+		mock.ExpectPrepare(`INSERT INTO "` + table.name + `"`)
+	}
+}
+
+func expectSequences(mock sqlmock.Sqlmock) {
+	for _, table := range backup.Tables {
+		if !table.Serial {
+			continue
+		}
+		mock.ExpectExec("SELECT setval\\(pg_get_serial_sequence\\('" + table.Name + "', 'id'\\)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+func TestBackupRepository_BackupRestore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := backup.NewBackupRepository(db)
+
+	expectBackupDump(mock, false)
+	var archive bytes.Buffer
+	manifest, err := repo.Backup(&archive, backup.Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manifest.SchemaVersion != 6 || len(manifest.Tables) != len(backup.Tables) || !manifest.Credentials {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+
+	expectRestoreChecks(mock, true)
+	expectColumns(mock, "users", "id", "login", "password", "role")
+	mock.ExpectPrepare(`INSERT INTO "users" \("id", "login", "password", "role"\) VALUES \(\$1, \$2, \$3, \$4\)`).
+		ExpectExec().WithArgs(1, "admin", []byte{0, 1, 2}, true).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectColumns(mock, "actor", "id", "name", "gender", "birth_date", "imdb_id", "version")
+	mock.ExpectPrepare(`INSERT INTO "actor"`).
+		ExpectExec().WithArgs(2, "Actor1", "man", "12.03.1995", nil).WillReturnResult(sqlmock.NewResult(2, 1))
+	expectColumns(mock, "film", "id", "title", "rating")
+	mock.ExpectPrepare(`INSERT INTO "film"`).
+		ExpectExec().WithArgs(3, "Film1", 8).WillReturnResult(sqlmock.NewResult(3, 1))
+	expectColumns(mock, "film_actor", "id", "film_id", "actor_id")
+	mock.ExpectPrepare(`INSERT INTO "film_actor"`).
+		ExpectExec().WithArgs(4, 3, 2).WillReturnResult(sqlmock.NewResult(4, 1))
+	expectEmptyTablesRestore(mock)
+	expectColumns(mock, "sessions", "id", "user_id")
+	mock.ExpectPrepare(`INSERT INTO "sessions"`).
+		ExpectExec().WithArgs("session1", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSequences(mock)
+	mock.ExpectCommit()
+
+	_, err = repo.Restore(&archive)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBackupRepository_RestoreWithoutCredentials(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := backup.NewBackupRepository(db)
+
+	expectBackupDump(mock, true)
+	var archive bytes.Buffer
+	_, err = repo.Backup(&archive, backup.Options{NoCredentials: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// база без schema_migrations: совместимость проверяется только по колонкам
+	expectRestoreChecks(mock, false)
+	expectColumns(mock, "users", "id", "login", "password", "role")
+	mock.ExpectPrepare(`INSERT INTO "users" \("id", "login", "role", "password"\)`).
+		ExpectExec().WithArgs(1, "admin", true, lockedPassword{}).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectColumns(mock, "actor", "id", "name", "gender", "birth_date", "imdb_id")
+	mock.ExpectPrepare(`INSERT INTO "actor"`).
+		ExpectExec().WillReturnResult(sqlmock.NewResult(2, 1))
+	expectColumns(mock, "film", "id", "title", "rating")
+	mock.ExpectPrepare(`INSERT INTO "film"`).
+		ExpectExec().WillReturnResult(sqlmock.NewResult(3, 1))
+	expectColumns(mock, "film_actor", "id", "film_id", "actor_id")
+	mock.ExpectPrepare(`INSERT INTO "film_actor"`).
+		ExpectExec().WillReturnResult(sqlmock.NewResult(4, 1))
+	expectEmptyTablesRestore(mock)
+	// id удаленных фильмов из ревизий и аудита не выдаются заново
+	for _, table := range backup.Tables {
+		if !table.Serial {
+			continue
+		}
+		query := "SELECT setval\\(pg_get_serial_sequence\\('" + table.Name + "', 'id'\\)"
+		if table.Name == "film" {
+			query += ".*SELECT MAX\\(film_id\\) FROM film_revision"
+		}
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	manifest, err := repo.Restore(&archive)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if manifest != nil && manifest.Credentials {
+		t.Error("expected archive without credentials")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBackupRepository_RestoreMissingColumn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := backup.NewBackupRepository(db)
+
+	expectBackupDump(mock, true)
+	var archive bytes.Buffer
+	_, err = repo.Backup(&archive, backup.Options{NoCredentials: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectRestoreChecks(mock, false)
+	expectColumns(mock, "users", "id", "login", "password")
+	mock.ExpectRollback()
+
+	_, err = repo.Restore(&archive)
+	if err == nil || !strings.Contains(err.Error(), "column role is missing") {
+		t.Errorf("expected missing column error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// tamper меняет последний байт файла name внутри архива, не трогая manifest.json.
+func tamper(t *testing.T, archive []byte, name string) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := tar.NewReader(gz)
+
+	var out bytes.Buffer
+	gzOut := gzip.NewWriter(&out)
+	writer := tar.NewWriter(gzOut)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == name {
+			data = bytes.Replace(data, []byte("Film1"), []byte("Film2"), 1)
+		}
+		writer.WriteHeader(header)
+		writer.Write(data)
+	}
+	writer.Close()
+	gzOut.Close()
+	return out.Bytes()
+}
+
+func TestBackupRepository_RestoreChecksumMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := backup.NewBackupRepository(db)
+
+	expectBackupDump(mock, true)
+	var archive bytes.Buffer
+	_, err = repo.Backup(&archive, backup.Options{NoCredentials: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectRestoreChecks(mock, true)
+	expectColumns(mock, "users", "id", "login", "password", "role")
+	mock.ExpectPrepare(`INSERT INTO "users"`).
+		ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	expectColumns(mock, "actor", "id", "name", "gender", "birth_date", "imdb_id")
+	mock.ExpectPrepare(`INSERT INTO "actor"`).
+		ExpectExec().WillReturnResult(sqlmock.NewResult(2, 1))
+	expectColumns(mock, "film", "id", "title", "rating")
+	mock.ExpectPrepare(`INSERT INTO "film"`).
+		ExpectExec().WithArgs(3, "Film2", 8).WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectRollback()
+
+	_, err = repo.Restore(bytes.NewReader(tamper(t, archive.Bytes(), "film.ndjson")))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	var manifest backup.Manifest
+	gz, _ := gzip.NewReader(bytes.NewReader(archive.Bytes()))
+	reader := tar.NewReader(gz)
+	reader.Next()
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil || manifest.FormatVersion != backup.FormatVersion {
+		t.Errorf("expected manifest.json first in archive, got %+v, %v", manifest, err)
+	}
+}
@@ -0,0 +1,582 @@
+// Package contract - общий набор тестов для хранилищ фильмов и актеров. Он
+// проверяет поведение через интерфейсы film.Storage и actor.Storage, а не SQL
+// отдельной реализации, поэтому запускается для каждого хранилища: в памяти,
+// SQLite и PostgreSQL.
+package contract
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Factory создает пустое хранилище для одного теста. Фильмы и актеры должны
+// храниться вместе: актеры, добавленные с фильмом, видны через actor.Storage.
+type Factory func(t *testing.T) (film.Storage, actor.Storage)
+
+// Run проверяет хранилище, созданное newStorage, на соответствие контракту.
+// Каждая проверка получает свое пустое хранилище.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, films film.Storage, actors actor.Storage)
+	}{
+		{"AddAndGet", testAddAndGet},
+		{"Uniqueness", testUniqueness},
+		{"NotFound", testNotFound},
+		{"UpdateAndPatch", testUpdateAndPatch},
+		{"FilmCast", testFilmCast},
+		{"DeleteFilm", testDeleteFilm},
+		{"DeleteActor", testDeleteActor},
+		{"ActorChangesFilmVersion", testActorChangesFilmVersion},
+		{"Revisions", testRevisions},
+		{"Ordering", testOrdering},
+		{"Search", testSearch},
+		{"SearchSpecialCharacters", testSearchSpecialCharacters},
+		{"ActorsListWithFilms", testActorsListWithFilms},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			films, actors := newStorage(t)
+			tt.test(t, films, actors)
+		})
+	}
+}
+
+func mustAddFilm(t *testing.T, films film.Storage, f *film.Film) int64 {
+	t.Helper()
+	if err := films.Add(context.Background(), f); err != nil {
+		t.Fatalf("can't add film %s: %v", f.Title, err)
+	}
+	filmId, err := films.GetFilmId(f)
+	if err != nil {
+		t.Fatalf("can't get film id %s: %v", f.Title, err)
+	}
+	return filmId
+}
+
+func mustAddActor(t *testing.T, actors actor.Storage, a *actor.Actor) int64 {
+	t.Helper()
+	if err := actors.Add(a); err != nil {
+		t.Fatalf("can't add actor %s: %v", a.Name, err)
+	}
+	actorId, err := actors.GetActorId(a)
+	if err != nil {
+		t.Fatalf("can't get actor id %s: %v", a.Name, err)
+	}
+	return actorId
+}
+
+// isNotFound проверяет, что ненайденная запись возвращается как pkg.ErrNotFound
+// и, как раньше, как sql.ErrNoRows.
+func isNotFound(err error) bool {
+	return errors.Is(err, pkg.ErrNotFound) && errors.Is(err, sql.ErrNoRows)
+}
+
+func titles(films []film.Film) []string {
+	result := make([]string, 0, len(films))
+	for _, f := range films {
+		result = append(result, f.Title)
+	}
+	return result
+}
+
+func sortedTitles(films []film.Film) []string {
+	result := titles(films)
+	sort.Strings(result)
+	return result
+}
+
+func testAddAndGet(t *testing.T, films film.Storage, actors actor.Storage) {
+	filmId := mustAddFilm(t, films, &film.Film{
+		Title: "Film", Description: "Description", ReleaseDate: "2020-01-02", Rating: 8,
+		Actors: []actor.Actor{
+			{Name: "Zed", Gender: "man", BirthDate: "1990"},
+			{Name: "Anna", Gender: "woman", BirthDate: "1991-02-01~"},
+		},
+	})
+
+	got, version, err := films.GetFilm(filmId)
+	if err != nil {
+		t.Fatalf("can't get film: %v", err)
+	}
+	// даты возвращаются с исходной точностью, актеры - по имени
+	expected := &film.Film{
+		Title: "Film", Description: "Description", ReleaseDate: "02.01.2020", Rating: 8,
+		Actors: []actor.Actor{
+			{Name: "Anna", Gender: "woman", BirthDate: "01.02.1991~"},
+			{Name: "Zed", Gender: "man", BirthDate: "1990"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) || version != 1 {
+		t.Errorf("expected %v version 1, got %v version %d", expected, got, version)
+	}
+
+	// актеры фильма создаются вместе с ним
+	actorId, err := actors.GetActorId(&actor.Actor{Name: "Zed", Gender: "man", BirthDate: "1990"})
+	if err != nil {
+		t.Fatalf("expected actor to be created with film: %v", err)
+	}
+	a, version, err := actors.Get(actorId)
+	if err != nil || *a != (actor.Actor{Name: "Zed", Gender: "man", BirthDate: "1990"}) || version != 1 {
+		t.Errorf("unexpected actor: %v version %d, %v", a, version, err)
+	}
+}
+
+func testUniqueness(t *testing.T, films film.Storage, actors actor.Storage) {
+	mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5})
+
+	if err := films.Add(context.Background(), &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 6}); !errors.Is(err, pkg.ErrConflict) {
+		t.Errorf("expected pkg.ErrConflict for the same film, got %v", err)
+	}
+	// дата с другой точностью - другая дата, и поиск ее учитывает
+	dayId := mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "01.01.2020", Rating: 6})
+	mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020~", Rating: 6})
+	if id, err := films.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "2020-01-01"}); err != nil || id != dayId {
+		t.Errorf("expected film %d for the exact date, got %d, %v", dayId, id, err)
+	}
+	if _, err := films.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "2020-01"}); !isNotFound(err) {
+		t.Errorf("expected pkg.ErrNotFound for another precision, got %v", err)
+	}
+	// тот же фильм в другой год - другой фильм
+	mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2021", Rating: 5})
+
+	mustAddActor(t, actors, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+	if err := actors.Add(&actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"}); !errors.Is(err, pkg.ErrConflict) {
+		t.Errorf("expected pkg.ErrConflict for the same actor, got %v", err)
+	}
+	mustAddActor(t, actors, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990-01-01"})
+	yearId, err := actors.GetActorId(&actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+	if err != nil {
+		t.Fatalf("can't find actor: %v", err)
+	}
+	if a, _, err := actors.Get(yearId); err != nil || a.BirthDate != "1990" {
+		t.Errorf("expected actor born in 1990, got %v, %v", a, err)
+	}
+	mustAddActor(t, actors, &actor.Actor{Name: "Actor", Gender: "woman", BirthDate: "1990"})
+
+	if err := films.Add(context.Background(), &film.Film{Title: "Bad", Description: "d", ReleaseDate: "2020", Rating: 11}); !errors.Is(err, pkg.ErrValidation) {
+		t.Errorf("expected pkg.ErrValidation for rating out of range, got %v", err)
+	}
+	if err := actors.Add(&actor.Actor{Name: "Bad", Gender: "other", BirthDate: "1990"}); !errors.Is(err, pkg.ErrValidation) {
+		t.Errorf("expected pkg.ErrValidation for unknown gender, got %v", err)
+	}
+
+	// занятые название и дата нельзя получить и обновлением
+	secondId, _ := films.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "2021"})
+	_, _, err = films.Update(context.Background(), secondId, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5})
+	if !errors.Is(err, pkg.ErrConflict) {
+		t.Errorf("expected pkg.ErrConflict when updating film to an existing one, got %v", err)
+	}
+}
+
+func testNotFound(t *testing.T, films film.Storage, actors actor.Storage) {
+	ctx := context.Background()
+	const missing = 1000
+
+	if _, err := films.GetFilmId(&film.Film{Title: "Missing", ReleaseDate: "2020"}); !isNotFound(err) {
+		t.Errorf("GetFilmId: expected pkg.ErrNotFound, got %v", err)
+	}
+	if _, _, err := films.GetFilm(missing); !isNotFound(err) {
+		t.Errorf("GetFilm: expected pkg.ErrNotFound, got %v", err)
+	}
+	if _, _, err := films.Update(ctx, missing, &film.Film{Title: "Missing", Description: "d", ReleaseDate: "2020", Rating: 5}); !isNotFound(err) {
+		t.Errorf("Update: expected pkg.ErrNotFound, got %v", err)
+	}
+	if err := films.Delete(ctx, missing, true); !isNotFound(err) {
+		t.Errorf("Delete film: expected pkg.ErrNotFound, got %v", err)
+	}
+
+	if _, err := actors.GetActorId(&actor.Actor{Name: "Missing", Gender: "man", BirthDate: "1990"}); !isNotFound(err) {
+		t.Errorf("GetActorId: expected pkg.ErrNotFound, got %v", err)
+	}
+	if _, _, err := actors.Get(missing); !isNotFound(err) {
+		t.Errorf("Get actor: expected pkg.ErrNotFound, got %v", err)
+	}
+	if _, err := actors.Update(ctx, missing, &actor.Actor{Name: "Missing", Gender: "man", BirthDate: "1990"}); !isNotFound(err) {
+		t.Errorf("Update actor: expected pkg.ErrNotFound, got %v", err)
+	}
+	if err := actors.Delete(ctx, missing, true); !isNotFound(err) {
+		t.Errorf("Delete actor: expected pkg.ErrNotFound, got %v", err)
+	}
+}
+
+func testUpdateAndPatch(t *testing.T, films film.Storage, actors actor.Storage) {
+	ctx := context.Background()
+	filmId := mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5})
+
+	updated, version, err := films.Update(ctx, filmId, &film.Film{Title: "Film", Description: "Updated", ReleaseDate: "2020", Rating: 7})
+	if err != nil || version != 2 || updated.Description != "Updated" || updated.Rating != 7 {
+		t.Fatalf("unexpected update: %v version %d, %v", updated, version, err)
+	}
+
+	// патч без изменений не меняет версию
+	patched, version, err := films.Patch(ctx, filmId, updated)
+	if err != nil || version != 2 || !reflect.DeepEqual(patched, updated) {
+		t.Errorf("expected unchanged film version 2, got %v version %d, %v", patched, version, err)
+	}
+
+	actorId := mustAddActor(t, actors, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+	version, err = actors.Update(ctx, actorId, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990-05"})
+	if err != nil || version != 2 {
+		t.Fatalf("unexpected actor update: version %d, %v", version, err)
+	}
+	a, _, _ := actors.Get(actorId)
+	if a.BirthDate != "05.1990" {
+		t.Errorf("expected birth date 05.1990, got %s", a.BirthDate)
+	}
+
+	// обновление теми же данными не меняет версию
+	version, err = actors.Update(ctx, actorId, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990-05"})
+	if err != nil || version != 2 {
+		t.Errorf("expected unchanged actor version 2, got %d, %v", version, err)
+	}
+}
+
+func testFilmCast(t *testing.T, films film.Storage, actors actor.Storage) {
+	ctx := context.Background()
+	filmId := mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5})
+	anna := actor.Actor{Name: "Anna", Gender: "woman", BirthDate: "1991"}
+
+	f, _, err := films.AddActor(ctx, filmId, &anna)
+	if err != nil || !reflect.DeepEqual(f.Actors, []actor.Actor{anna}) {
+		t.Fatalf("unexpected cast after AddActor: %v, %v", f, err)
+	}
+	if _, _, err := films.AddActor(ctx, filmId, &anna); !errors.Is(err, film.ErrActorInFilm) {
+		t.Errorf("expected film.ErrActorInFilm, got %v", err)
+	}
+
+	f, _, err = films.RemoveActor(ctx, filmId, &anna)
+	if err != nil || len(f.Actors) != 0 {
+		t.Fatalf("unexpected cast after RemoveActor: %v, %v", f, err)
+	}
+	if _, _, err := films.RemoveActor(ctx, filmId, &anna); !errors.Is(err, film.ErrActorNotInFilm) {
+		t.Errorf("expected film.ErrActorNotInFilm, got %v", err)
+	}
+	// актер остается в каталоге без фильма
+	if _, err := actors.GetActorId(&anna); err != nil {
+		t.Errorf("expected actor to stay after RemoveActor: %v", err)
+	}
+}
+
+func testDeleteFilm(t *testing.T, films film.Storage, actors actor.Storage) {
+	ctx := context.Background()
+	cast := []actor.Actor{{Name: "Zed", Gender: "man", BirthDate: "1990"}, {Name: "Anna", Gender: "woman", BirthDate: "1991"}}
+	filmId := mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5, Actors: cast})
+
+	err := films.Delete(ctx, filmId, false)
+	var refErr *film.ReferencedError
+	if !errors.As(err, &refErr) || !errors.Is(err, pkg.ErrConflict) {
+		t.Fatalf("expected *film.ReferencedError, got %v", err)
+	}
+	if !reflect.DeepEqual(refErr.Actors, []actor.Actor{cast[1], cast[0]}) {
+		t.Errorf("expected referenced actors %v, got %v", []actor.Actor{cast[1], cast[0]}, refErr.Actors)
+	}
+	if _, _, err := films.GetFilm(filmId); err != nil {
+		t.Errorf("expected film to stay after refused delete: %v", err)
+	}
+
+	if err := films.Delete(ctx, filmId, true); err != nil {
+		t.Fatalf("can't delete film with cascade: %v", err)
+	}
+	if _, _, err := films.GetFilm(filmId); !isNotFound(err) {
+		t.Errorf("expected pkg.ErrNotFound after delete, got %v", err)
+	}
+	// каскадное удаление убирает связи, но не самих актеров
+	for i := range cast {
+		if _, err := actors.GetActorId(&cast[i]); err != nil {
+			t.Errorf("expected actor %s to stay: %v", cast[i].Name, err)
+		}
+	}
+
+	// фильм без актеров удаляется и без cascade
+	emptyId := mustAddFilm(t, films, &film.Film{Title: "Empty", Description: "d", ReleaseDate: "2020", Rating: 5})
+	if err := films.Delete(ctx, emptyId, false); err != nil {
+		t.Errorf("can't delete film without actors: %v", err)
+	}
+}
+
+func testRevisions(t *testing.T, films film.Storage, actors actor.Storage) {
+	ctx := context.Background()
+	filmId := mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5})
+
+	// первая ревизия пишется при добавлении, а не при первом изменении
+	revisions, err := films.Revisions(filmId)
+	if err != nil || len(revisions) != 1 || revisions[0].Revision != 1 || revisions[0].Action != film.RevisionInitial {
+		t.Fatalf("expected initial revision after add, got %+v, %v", revisions, err)
+	}
+
+	if _, _, err := films.Update(ctx, filmId, &film.Film{Title: "Film", Description: "Updated", ReleaseDate: "2020", Rating: 7}); err != nil {
+		t.Fatalf("can't update film: %v", err)
+	}
+	// откат к текущему состоянию не создает ревизию
+	if err := films.Rollback(ctx, filmId, 2); err != nil {
+		t.Fatalf("can't rollback film: %v", err)
+	}
+	if revisions, err := films.Revisions(filmId); err != nil || len(revisions) != 2 {
+		t.Fatalf("expected no revision for no-op rollback, got %+v, %v", revisions, err)
+	}
+	if err := films.Delete(ctx, filmId, true); err != nil {
+		t.Fatalf("can't delete film: %v", err)
+	}
+
+	// история остается после удаления фильма
+	revisions, err = films.Revisions(filmId)
+	if err != nil {
+		t.Fatalf("can't get revisions of deleted film: %v", err)
+	}
+	actions := make([]string, len(revisions))
+	for i, revision := range revisions {
+		actions[i] = revision.Action
+	}
+	expected := []string{film.RevisionInitial, film.RevisionUpdate, film.RevisionDelete}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("expected actions %v, got %v", expected, actions)
+	}
+	if revisions[2].Film.Description != "Updated" {
+		t.Errorf("expected delete revision to keep the last state, got %+v", revisions[2].Film)
+	}
+
+	if _, err := films.Revisions(filmId + 1000); !isNotFound(err) {
+		t.Errorf("expected pkg.ErrNotFound for unknown film, got %v", err)
+	}
+}
+
+func testDeleteActor(t *testing.T, films film.Storage, actors actor.Storage) {
+	ctx := context.Background()
+	zed := actor.Actor{Name: "Zed", Gender: "man", BirthDate: "1990"}
+	second := mustAddFilm(t, films, &film.Film{Title: "B", Description: "d", ReleaseDate: "2021", Rating: 5, Actors: []actor.Actor{zed}})
+	mustAddFilm(t, films, &film.Film{Title: "A", Description: "d", ReleaseDate: "2020", Rating: 5, Actors: []actor.Actor{zed}})
+	actorId, _ := actors.GetActorId(&zed)
+
+	err := actors.Delete(ctx, actorId, false)
+	var refErr *actor.ReferencedError
+	if !errors.As(err, &refErr) || !errors.Is(err, pkg.ErrConflict) {
+		t.Fatalf("expected *actor.ReferencedError, got %v", err)
+	}
+	expected := []actor.FilmRef{{Title: "A", ReleaseDate: "2020"}, {Title: "B", ReleaseDate: "2021"}}
+	if !reflect.DeepEqual(refErr.Films, expected) {
+		t.Errorf("expected referenced films %v, got %v", expected, refErr.Films)
+	}
+
+	if err := actors.Delete(ctx, actorId, true); err != nil {
+		t.Fatalf("can't delete actor with cascade: %v", err)
+	}
+	if _, _, err := actors.Get(actorId); !isNotFound(err) {
+		t.Errorf("expected pkg.ErrNotFound after delete, got %v", err)
+	}
+	// фильмы остаются без удаленного актера
+	f, _, err := films.GetFilm(second)
+	if err != nil || len(f.Actors) != 0 {
+		t.Errorf("expected film without actors, got %v, %v", f, err)
+	}
+	// и получают ревизию без него
+	revisions, err := films.Revisions(second)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("expected initial and cascade revisions, got %+v, %v", revisions, err)
+	}
+	if revisions[1].Action != film.RevisionUpdate || len(revisions[1].Film.Actors) != 0 {
+		t.Errorf("unexpected cascade revision: %+v", revisions[1])
+	}
+}
+
+// testActorChangesFilmVersion проверяет, что изменение актера меняет версию
+// фильмов, в которых он указан: актеры входят в ответ с фильмом и его ETag.
+func testActorChangesFilmVersion(t *testing.T, films film.Storage, actors actor.Storage) {
+	ctx := context.Background()
+	cast := actor.Actor{Name: "Anna", Gender: "woman", BirthDate: "1990"}
+	filmId := mustAddFilm(t, films, &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5,
+		Actors: []actor.Actor{cast}})
+	otherId := mustAddFilm(t, films, &film.Film{Title: "Other", Description: "d", ReleaseDate: "2020", Rating: 5})
+	actorId, err := actors.GetActorId(&cast)
+	if err != nil {
+		t.Fatalf("can't get actor id: %v", err)
+	}
+
+	filmVersion := func(id int64) int64 {
+		t.Helper()
+		_, version, err := films.GetFilm(id)
+		if err != nil {
+			t.Fatalf("can't get film: %v", err)
+		}
+		return version
+	}
+
+	if _, err := actors.Update(ctx, actorId, &actor.Actor{Name: "Anna B", Gender: "woman", BirthDate: "1990"}); err != nil {
+		t.Fatalf("can't update actor: %v", err)
+	}
+	if version := filmVersion(filmId); version != 2 {
+		t.Errorf("expected film version 2 after actor update, got %d", version)
+	}
+
+	if _, _, err := actors.Patch(ctx, actorId, &actor.Actor{Name: "Anna C", Gender: "woman", BirthDate: "1990"}); err != nil {
+		t.Fatalf("can't patch actor: %v", err)
+	}
+	if version := filmVersion(filmId); version != 3 {
+		t.Errorf("expected film version 3 after actor patch, got %d", version)
+	}
+
+	if _, err := actors.Update(ctx, actorId, &actor.Actor{Name: "Anna C", Gender: "woman", BirthDate: "1990"}); err != nil {
+		t.Fatalf("can't update actor: %v", err)
+	}
+	if version := filmVersion(filmId); version != 3 {
+		t.Errorf("expected film version 3 after unchanged actor update, got %d", version)
+	}
+
+	if err := actors.Delete(ctx, actorId, true); err != nil {
+		t.Fatalf("can't delete actor: %v", err)
+	}
+	if version := filmVersion(filmId); version != 4 {
+		t.Errorf("expected film version 4 after actor delete, got %d", version)
+	}
+
+	// фильмы без этого актера не меняются
+	if version := filmVersion(otherId); version != 1 {
+		t.Errorf("expected other film version 1, got %d", version)
+	}
+}
+
+func testOrdering(t *testing.T, films film.Storage, actors actor.Storage) {
+	mustAddFilm(t, films, &film.Film{Title: "Brazil", Description: "d", ReleaseDate: "1985", Rating: 9})
+	mustAddFilm(t, films, &film.Film{Title: "Alien", Description: "d", ReleaseDate: "1979-05-25", Rating: 8})
+	mustAddFilm(t, films, &film.Film{Title: "Casablanca", Description: "d", ReleaseDate: "1942-11-26", Rating: 7})
+
+	tests := []struct {
+		sortCol  string
+		expected []string
+	}{
+		{"title", []string{"Alien", "Brazil", "Casablanca"}},
+		{"release_date", []string{"Casablanca", "Alien", "Brazil"}},
+		{"rating", []string{"Casablanca", "Alien", "Brazil"}},
+	}
+	for _, tt := range tests {
+		got, err := films.GetAllFilms(tt.sortCol)
+		if err != nil || !reflect.DeepEqual(titles(got), tt.expected) {
+			t.Errorf("%s: expected %v, got %v, %v", tt.sortCol, tt.expected, titles(got), err)
+		}
+	}
+
+	if _, err := films.GetAllFilms("description; DROP TABLE film"); err == nil {
+		t.Error("expected error for unknown sort column")
+	}
+}
+
+func testSearch(t *testing.T, films film.Storage, actors actor.Storage) {
+	pryce := actor.Actor{Name: "Jonathan Pryce", Gender: "man", BirthDate: "1947"}
+	mustAddFilm(t, films, &film.Film{Title: "Brazil", Description: "d", ReleaseDate: "1985", Rating: 9, Actors: []actor.Actor{pryce}})
+	mustAddFilm(t, films, &film.Film{Title: "The Two Popes", Description: "d", ReleaseDate: "2019", Rating: 7, Actors: []actor.Actor{pryce}})
+	mustAddFilm(t, films, &film.Film{Title: "Alien", Description: "d", ReleaseDate: "1979", Rating: 8})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		// сначала ищется фрагмент названия
+		{"Bra", []string{"Brazil"}},
+		{"i", []string{"Alien", "Brazil"}},
+		// если по названию ничего нет - фрагмент имени актера
+		{"Pryce", []string{"Brazil", "The Two Popes"}},
+	}
+	for _, tt := range tests {
+		got, err := films.FindFilms(tt.query)
+		if err != nil || !reflect.DeepEqual(sortedTitles(got), tt.expected) {
+			t.Errorf("%q: expected %v, got %v, %v", tt.query, tt.expected, sortedTitles(got), err)
+		}
+	}
+
+	// поиск учитывает регистр, пустой результат - pkg.ErrNotFound
+	for _, query := range []string{"brazil", "Missing"} {
+		if got, err := films.FindFilms(query); !errors.Is(err, pkg.ErrNotFound) {
+			t.Errorf("%q: expected pkg.ErrNotFound, got %v, %v", query, titles(got), err)
+		}
+	}
+}
+
+// testSearchSpecialCharacters проверяет, что символы шаблона LIKE и кавычки в
+// строке поиска ищутся буквально.
+func testSearchSpecialCharacters(t *testing.T, films film.Storage, actors actor.Storage) {
+	mustAddFilm(t, films, &film.Film{Title: "100% Wolf", Description: "d", ReleaseDate: "2020", Rating: 5})
+	mustAddFilm(t, films, &film.Film{Title: "1000 Wolves", Description: "d", ReleaseDate: "2020", Rating: 5})
+	mustAddFilm(t, films, &film.Film{Title: "Snake_Eyes", Description: "d", ReleaseDate: "1998", Rating: 5,
+		Actors: []actor.Actor{{Name: "Ann_Lee", Gender: "woman", BirthDate: "1970"}}})
+	mustAddFilm(t, films, &film.Film{Title: "SnakeXEyes", Description: "d", ReleaseDate: "1998", Rating: 5,
+		Actors: []actor.Actor{{Name: "AnnXLee", Gender: "woman", BirthDate: "1970"}}})
+	mustAddFilm(t, films, &film.Film{Title: "Ocean's Eleven", Description: "d", ReleaseDate: "2001", Rating: 7})
+	mustAddFilm(t, films, &film.Film{Title: `AC\DC Live`, Description: "d", ReleaseDate: "1991", Rating: 6})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"%", []string{"100% Wolf"}},
+		{"0%", []string{"100% Wolf"}},
+		{"_", []string{"Snake_Eyes"}},
+		{"e_E", []string{"Snake_Eyes"}},
+		{"n's", []string{"Ocean's Eleven"}},
+		{`\`, []string{`AC\DC Live`}},
+		// по имени актера символы тоже ищутся буквально
+		{"n_L", []string{"Snake_Eyes"}},
+	}
+	for _, tt := range tests {
+		got, err := films.FindFilms(tt.query)
+		if err != nil || !reflect.DeepEqual(sortedTitles(got), tt.expected) {
+			t.Errorf("%q: expected %v, got %v, %v", tt.query, tt.expected, sortedTitles(got), err)
+		}
+	}
+
+	for _, query := range []string{"1%0", "'; DROP TABLE film; --"} {
+		if got, err := films.FindFilms(query); !errors.Is(err, pkg.ErrNotFound) {
+			t.Errorf("%q: expected pkg.ErrNotFound, got %v, %v", query, titles(got), err)
+		}
+	}
+}
+
+func testActorsListWithFilms(t *testing.T, films film.Storage, actors actor.Storage) {
+	zed := actor.Actor{Name: "Zed", Gender: "man", BirthDate: "1990"}
+	anna := actor.Actor{Name: "Anna", Gender: "woman", BirthDate: "1991"}
+	idle := actor.Actor{Name: "Idle", Gender: "man", BirthDate: "1992"}
+	mustAddFilm(t, films, &film.Film{Title: "A", Description: "d", ReleaseDate: "2020", Rating: 5, Actors: []actor.Actor{zed, anna}})
+	mustAddFilm(t, films, &film.Film{Title: "B", Description: "d", ReleaseDate: "2021", Rating: 5, Actors: []actor.Actor{zed}})
+	mustAddActor(t, actors, &idle)
+
+	list, err := films.ActorsListWithFilms()
+	if err != nil {
+		t.Fatalf("can't list actors: %v", err)
+	}
+	expected := map[actor.Actor][]string{zed: {"A", "B"}, anna: {"A"}, idle: {}}
+	if len(list) != len(expected) {
+		t.Errorf("expected %d actors, got %v", len(expected), list)
+	}
+	for a, filmTitles := range expected {
+		got, ok := list[a]
+		if !ok || !reflect.DeepEqual(sortedTitles(got), filmTitles) {
+			t.Errorf("%s: expected films %v, got %v (listed: %v)", a.Name, filmTitles, sortedTitles(got), ok)
+		}
+	}
+
+	// изменение актера видно в следующем списке
+	idleId, err := actors.GetActorId(&idle)
+	if err != nil {
+		t.Fatalf("can't get actor id: %v", err)
+	}
+	renamed := actor.Actor{Name: "Eric Idle", Gender: "man", BirthDate: "1992"}
+	if _, err := actors.Update(context.Background(), idleId, &renamed); err != nil {
+		t.Fatalf("can't update actor: %v", err)
+	}
+	list, err = films.ActorsListWithFilms()
+	if err != nil {
+		t.Fatalf("can't list actors: %v", err)
+	}
+	if _, ok := list[renamed]; !ok {
+		t.Errorf("expected renamed actor in list, got %v", list)
+	}
+	if _, ok := list[idle]; ok {
+		t.Errorf("expected old actor name to be gone, got %v", list)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/internal/memory"
+	"filmoteka/internal/migrate"
+	"filmoteka/pkg"
+	"filmoteka/schema"
+	"filmoteka/tests/storage_test/contract"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func TestContract_Memory(t *testing.T) {
+	contract.Run(t, func(t *testing.T) (film.Storage, actor.Storage) {
+		store := memory.NewStore()
+		return memory.NewFilmRepository(store), memory.NewActorRepository(store)
+	})
+}
+
+// TestContract_Cached проверяет, что кэш списков не меняет поведение хранилища,
+// в том числе после изменений через репозиторий актеров мимо CachedStorage.
+func TestContract_Cached(t *testing.T) {
+	contract.Run(t, func(t *testing.T) (film.Storage, actor.Storage) {
+		store := memory.NewStore()
+		films := film.NewCachedStorage(memory.NewFilmRepository(store), pkg.NewLRU(16, time.Minute))
+		return films, memory.NewActorRepository(store)
+	})
+}
+
+func TestContract_SQLite(t *testing.T) {
+	contract.Run(t, func(t *testing.T) (film.Storage, actor.Storage) {
+		db := openSQLite(t)
+		actorRepo := actor.NewActorRepository(db)
+		return film.NewFilmRepository(actorRepo, db), actorRepo
+	})
+}
+
+// TestContract_Postgres запускается, только если в FILMOTEKA_TEST_DSN указана
+// тестовая база PostgreSQL. Таблицы каталога в ней очищаются перед каждой проверкой.
+func TestContract_Postgres(t *testing.T) {
+	dsn := os.Getenv("FILMOTEKA_TEST_DSN")
+	if dsn == "" {
+		t.Skip("FILMOTEKA_TEST_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("can't open postgres: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.NewMigrator(db, schema.Migrations)
+	if err != nil {
+		t.Fatalf("can't load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		t.Fatalf("can't migrate: %v", err)
+	}
+
+	contract.Run(t, func(t *testing.T) (film.Storage, actor.Storage) {
+		_, err := db.Exec("TRUNCATE film_actor, film_revision, film, actor, audit_log RESTART IDENTITY CASCADE")
+		if err != nil {
+			t.Fatalf("can't clean tables: %v", err)
+		}
+		actorRepo := actor.NewActorRepository(db)
+		return film.NewFilmRepository(actorRepo, db), actorRepo
+	})
+}
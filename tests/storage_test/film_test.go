@@ -1,11 +1,19 @@
 package storage
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"filmoteka/internal/actor"
+	"filmoteka/internal/auth"
 	"filmoteka/internal/film"
+	"filmoteka/pkg"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestFilmRepositoryAdd(t *testing.T) {
@@ -28,19 +36,40 @@ func TestFilmRepositoryAdd(t *testing.T) {
 		},
 	}
 
-	//good query
+	//good query: the first actor exists, the second one is created
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO film").
-		WithArgs(film.Title, film.Description, film.ReleaseDate, film.Rating).
+		WithArgs(film.Title, film.Description, film.ReleaseDate, pkg.PrecisionDay, false, film.Rating).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs("Tim Robbins", "man", "1958-10-16", pkg.PrecisionDay, false).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs("Morgan Freeman", "man", "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO actor").
+		WithArgs("Morgan Freeman", "man", "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// the new film gets its first revision in the same transaction
+	expectFilmSnapshot(mock, 1, film)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(1, 1, "initial", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", int64(1), "create", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	for range film.Actors {
-		mock.ExpectQuery("SELECT id FROM actor").
-			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
-		mock.ExpectExec("INSERT INTO film_actor").
-			WithArgs(1, 1).
-			WillReturnResult(sqlmock.NewResult(1, 1))
-	}
-	err = filmRepo.Add(film)
+	err = filmRepo.Add(context.Background(), film)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
@@ -49,10 +78,13 @@ func TestFilmRepositoryAdd(t *testing.T) {
 	}
 
 	//query error
+	mock.ExpectBegin()
 	mock.ExpectQuery("INSERT INTO film").
-		WithArgs(film.Title, film.Description, film.ReleaseDate, film.Rating).
-		WillReturnError(err)
-	err = filmRepo.Add(film)
+		WithArgs(film.Title, film.Description, film.ReleaseDate, pkg.PrecisionDay, false, film.Rating).
+		WillReturnError(fmt.Errorf("db_error"))
+	mock.ExpectRollback()
+
+	err = filmRepo.Add(context.Background(), film)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -61,8 +93,33 @@ func TestFilmRepositoryAdd(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 
-	//query error
+	//actor error rolls back the whole film
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO film").
+		WithArgs(film.Title, film.Description, film.ReleaseDate, pkg.PrecisionDay, false, film.Rating).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs("Tim Robbins", "man", "1958-10-16", pkg.PrecisionDay, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs("Morgan Freeman", "man", "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO actor").
+		WithArgs("Morgan Freeman", "man", "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnError(fmt.Errorf("db_error"))
+	mock.ExpectRollback()
 
+	err = filmRepo.Add(context.Background(), film)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestFilmRepository_GetFilmId(t *testing.T) {
@@ -84,8 +141,8 @@ func TestFilmRepository_GetFilmId(t *testing.T) {
 		AddRow(1)
 
 	mock.
-		ExpectQuery("SELECT id FROM film where title =").
-		WithArgs(film.Title, film.ReleaseDate).
+		ExpectQuery("SELECT id FROM film WHERE title =").
+		WithArgs(film.Title, film.ReleaseDate, pkg.PrecisionDay, false).
 		WillReturnRows(rows)
 
 	// Calling the method
@@ -108,8 +165,8 @@ func TestFilmRepository_GetFilmId(t *testing.T) {
 
 	// Query error
 	mock.
-		ExpectQuery("SELECT id FROM film where title =").
-		WithArgs(film.Title, film.ReleaseDate).
+		ExpectQuery("SELECT id FROM film WHERE title =").
+		WithArgs(film.Title, film.ReleaseDate, pkg.PrecisionDay, false).
 		WillReturnError(fmt.Errorf("db_error"))
 
 	_, err = repo.GetFilmId(film)
@@ -130,8 +187,8 @@ func TestFilmRepository_GetFilmId(t *testing.T) {
 		AddRow(2) // Adding more rows to trigger row scan error
 
 	mock.
-		ExpectQuery("SELECT id FROM film where title =").
-		WithArgs(film.Title, film.ReleaseDate).
+		ExpectQuery("SELECT id FROM film WHERE title =").
+		WithArgs(film.Title, film.ReleaseDate, pkg.PrecisionDay, false).
 		WillReturnRows(rows)
 
 	_, err = repo.GetFilmId(film)
@@ -147,6 +204,26 @@ func TestFilmRepository_GetFilmId(t *testing.T) {
 	}
 }
 
+func expectVersionBump(mock sqlmock.Sqlmock, table string, id int64, version int64) {
+	mock.ExpectQuery("UPDATE " + table + " SET version = version \\+ 1 WHERE id = \\$1 RETURNING version").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+func expectFilmSnapshot(mock sqlmock.Sqlmock, filmID int64, f *film.Film) {
+	mock.ExpectQuery("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film WHERE id =").
+		WithArgs(filmID).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "description", "release_date", "rating"}).
+			AddRow(f.Title, f.Description, f.ReleaseDate, f.Rating))
+	actorRows := sqlmock.NewRows([]string{"name", "gender", "birth_date"})
+	for _, a := range f.Actors {
+		actorRows.AddRow(a.Name, a.Gender, a.BirthDate)
+	}
+	mock.ExpectQuery("SELECT a.name, a.gender, partial_date\\(a.birth_date, a.birth_date_precision, a.birth_date_approx\\) FROM actor a").
+		WithArgs(filmID).
+		WillReturnRows(actorRows)
+}
+
 func TestFilmRepository_Update(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -157,24 +234,66 @@ func TestFilmRepository_Update(t *testing.T) {
 	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
 	// Mocked film data
 	filmID := int64(1)
+	oldFilm := &film.Film{
+		Title:       "OldFilm",
+		Description: "OldDescription",
+		ReleaseDate: "01.01.2022",
+		Rating:      7,
+	}
 	newFilm := &film.Film{
 		Title:       "TestFilm",
 		Description: "TestDescription",
 		ReleaseDate: "01.01.2023",
 		Rating:      9,
+		Actors: []actor.Actor{
+			{Name: "Tim Robbins", Gender: "man", BirthDate: "16.10.1958"},
+		},
 	}
-	// good query
+	// good query: the cast is replaced too
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, oldFilm)
 	mock.
-		ExpectQuery("UPDATE film SET").
-		WithArgs(newFilm.Title, newFilm.Description, newFilm.ReleaseDate, newFilm.Rating, filmID).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		ExpectExec("UPDATE film SET").
+		WithArgs(newFilm.Title, newFilm.Description, "2023-01-01", pkg.PrecisionDay, false, newFilm.Rating, filmID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM film_actor WHERE film_id =").
+		WithArgs(filmID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs("Tim Robbins", "man", "1958-10-16", pkg.PrecisionDay, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(filmID, 5).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectFilmSnapshot(mock, filmID, newFilm)
+	// first update of a film without history also stores its previous state
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(filmID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(filmID, 1, "initial", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(filmID, 2, "update", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", filmID, "update", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	// Calling the method
-	err = repo.Update(filmID, newFilm)
+	updated, version, err := repo.Update(context.Background(), filmID, newFilm)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
 	}
+	if version != 2 {
+		t.Errorf("expected version 2, got %d", version)
+	}
+	if !reflect.DeepEqual(updated, newFilm) {
+		t.Errorf("expected updated film %v, got %v", newFilm, updated)
+	}
 
 	// Checking if all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -183,12 +302,16 @@ func TestFilmRepository_Update(t *testing.T) {
 	}
 
 	// Query error
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, oldFilm)
 	mock.
-		ExpectQuery("UPDATE film SET").
-		WithArgs(newFilm.Title, newFilm.Description, newFilm.ReleaseDate, newFilm.Rating, filmID).
+		ExpectExec("UPDATE film SET").
+		WithArgs(newFilm.Title, newFilm.Description, "2023-01-01", pkg.PrecisionDay, false, newFilm.Rating, filmID).
 		WillReturnError(fmt.Errorf("db_error"))
+	mock.ExpectRollback()
 
-	err = repo.Update(filmID, newFilm)
+	_, _, err = repo.Update(context.Background(), filmID, newFilm)
 	if err == nil {
 		t.Error("expected error, got nil")
 		return
@@ -199,6 +322,390 @@ func TestFilmRepository_Update(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 		return
 	}
+
+	// Film not found
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE film SET version = version \\+ 1 WHERE id = \\$1 RETURNING version").
+		WithArgs(int64(2)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, _, err = repo.Update(context.Background(), 2, newFilm)
+	if err == nil {
+		t.Error("expected error, got nil")
+		return
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
+
+	// Film was changed after the client read version 1
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 3)
+	mock.ExpectRollback()
+
+	_, _, err = repo.Update(pkg.WithIfMatch(context.Background(), `"1"`), filmID, newFilm)
+	if !errors.Is(err, pkg.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFilmRepository_Rollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
+
+	filmID := int64(1)
+	current := &film.Film{
+		Title:       "TestFilm",
+		Description: "TestDescription",
+		ReleaseDate: "01.01.2023",
+		Rating:      9,
+	}
+	target := &film.Film{
+		Title:       "TestFilm",
+		Description: "OldDescription",
+		ReleaseDate: "01.01.2023",
+		Rating:      7,
+		Actors: []actor.Actor{
+			{Name: "Tim Robbins", Gender: "man", BirthDate: "16.10.1958"},
+		},
+	}
+	targetData, _ := json.Marshal(target)
+	revisionColumns := []string{"revision", "action", "user_id", "data", "created_at"}
+
+	ctx := auth.ContextWithSession(context.Background(), &auth.Session{UserID: 3})
+
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, current)
+	mock.ExpectQuery("SELECT revision, action, user_id, data, created_at FROM film_revision").
+		WithArgs(filmID, 1).
+		WillReturnRows(sqlmock.NewRows(revisionColumns).AddRow(1, "initial", nil, targetData, time.Now()))
+	mock.ExpectExec("UPDATE film SET").
+		WithArgs(target.Title, target.Description, "2023-01-01", pkg.PrecisionDay, false, target.Rating, filmID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM film_actor WHERE film_id = ?").
+		WithArgs(filmID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// the actor was deleted since revision 1, so it is created again
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs("Tim Robbins", "man", "1958-10-16", pkg.PrecisionDay, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO actor").
+		WithArgs("Tim Robbins", "man", "1958-10-16", pkg.PrecisionDay, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(filmID, 5).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectFilmSnapshot(mock, filmID, target)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(filmID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(filmID, 3, "rollback", uint32(3), string(targetData)).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(uint32(3), "film", filmID, "rollback", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.Rollback(ctx, filmID, 1)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
+
+	// Revision not found
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, current)
+	mock.ExpectQuery("SELECT revision, action, user_id, data, created_at FROM film_revision").
+		WithArgs(filmID, 10).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = repo.Rollback(ctx, filmID, 10)
+	if err == nil {
+		t.Error("expected error, got nil")
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
+
+	// Rollback to the current state writes nothing
+	currentData, _ := json.Marshal(current)
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, current)
+	mock.ExpectQuery("SELECT revision, action, user_id, data, created_at FROM film_revision").
+		WithArgs(filmID, 2).
+		WillReturnRows(sqlmock.NewRows(revisionColumns).AddRow(2, "update", nil, currentData, time.Now()))
+	mock.ExpectExec("UPDATE film SET").
+		WithArgs(current.Title, current.Description, "2023-01-01", pkg.PrecisionDay, false, current.Rating, filmID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM film_actor WHERE film_id = ?").
+		WithArgs(filmID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectFilmSnapshot(mock, filmID, current)
+	mock.ExpectRollback()
+
+	err = repo.Rollback(ctx, filmID, 2)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFilmRepository_Revisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
+	revisionColumns := []string{"revision", "action", "user_id", "data", "created_at"}
+
+	// film created before revisions were introduced
+	mock.ExpectQuery("SELECT revision, action, user_id, data, created_at FROM film_revision").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(revisionColumns))
+	mock.ExpectQuery("SELECT id FROM film WHERE id = ?").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	revisions, err := repo.Revisions(1)
+	if err != nil || revisions == nil || len(revisions) != 0 {
+		t.Errorf("expected empty revisions, got %v, %v", revisions, err)
+	}
+
+	// unknown film
+	mock.ExpectQuery("SELECT revision, action, user_id, data, created_at FROM film_revision").
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows(revisionColumns))
+	mock.ExpectQuery("SELECT id FROM film WHERE id = ?").
+		WithArgs(int64(2)).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.Revisions(2)
+	if !errors.Is(err, pkg.ErrNotFound) {
+		t.Errorf("expected pkg.ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFilmRepository_AddRemoveActor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
+
+	filmID := int64(1)
+	newActor := actor.Actor{Name: "Morgan Freeman", Gender: "man", BirthDate: "01.06.1937"}
+	before := &film.Film{Title: "TestFilm", Description: "TestDescription", ReleaseDate: "1994", Rating: 9}
+	after := &film.Film{Title: "TestFilm", Description: "TestDescription", ReleaseDate: "1994", Rating: 9,
+		Actors: []actor.Actor{newActor}}
+
+	expectRevision := func() {
+		mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+			WithArgs(filmID).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+		mock.ExpectExec("INSERT INTO film_revision").
+			WithArgs(filmID, 4, "update", nil, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(4, 1))
+		mock.ExpectExec("INSERT INTO audit_log").
+			WithArgs(nil, "film", filmID, "update", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	// new actor is created and linked
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, before)
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs(newActor.Name, newActor.Gender, "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO actor").
+		WithArgs(newActor.Name, newActor.Gender, "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(filmID, 7).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectFilmSnapshot(mock, filmID, after)
+	expectRevision()
+	mock.ExpectCommit()
+
+	updated, _, err := repo.AddActor(context.Background(), filmID, &newActor)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(updated, after) {
+		t.Errorf("expected %v, got %v", after, updated)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// actor already in the film
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, after)
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs(newActor.Name, newActor.Gender, "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(filmID, 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, _, err = repo.AddActor(context.Background(), filmID, &newActor)
+	if !errors.Is(err, film.ErrActorInFilm) {
+		t.Errorf("expected ErrActorInFilm, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// actor is removed from the film
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, after)
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs(newActor.Name, newActor.Gender, "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("DELETE FROM film_actor WHERE film_id = \\$1 AND actor_id = \\$2").
+		WithArgs(filmID, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFilmSnapshot(mock, filmID, before)
+	expectRevision()
+	mock.ExpectCommit()
+
+	updated, _, err = repo.RemoveActor(context.Background(), filmID, &newActor)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(updated, before) {
+		t.Errorf("expected %v, got %v", before, updated)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// unknown actor
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, before)
+	mock.ExpectQuery("SELECT id FROM actor").
+		WithArgs(newActor.Name, newActor.Gender, "1937-06-01", pkg.PrecisionDay, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, _, err = repo.RemoveActor(context.Background(), filmID, &newActor)
+	if !errors.Is(err, film.ErrActorNotInFilm) {
+		t.Errorf("expected ErrActorNotInFilm, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFilmRepository_Patch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
+
+	filmID := int64(1)
+	cast := []actor.Actor{{Name: "Morgan Freeman", Gender: "man", BirthDate: "01.06.1937"}}
+	before := &film.Film{Title: "TestFilm", Description: "TestDescription", ReleaseDate: "1994", Rating: 8, Actors: cast}
+	after := &film.Film{Title: "TestFilm", Description: "TestDescription", ReleaseDate: "1994", Rating: 9, Actors: cast}
+
+	// only changed columns are updated, the cast stays untouched
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 2)
+	expectFilmSnapshot(mock, filmID, before)
+	mock.ExpectExec("UPDATE film SET rating = \\$1 WHERE id = \\$2").
+		WithArgs(9, filmID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectFilmSnapshot(mock, filmID, after)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(filmID).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(filmID, 2, "update", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", filmID, "update", sqlmock.AnyArg(), sqlmock.AnyArg(), `{"rating":{"old":8,"new":9}}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	patched := *before
+	patched.Rating = 9
+	updated, version, err := repo.Patch(context.Background(), filmID, &patched)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if version != 2 {
+		t.Errorf("expected version 2, got %d", version)
+	}
+	if !reflect.DeepEqual(updated, after) {
+		t.Errorf("expected %v, got %v", after, updated)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// nothing changed - no update, no revision and the version bump is rolled back
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", filmID, 3)
+	expectFilmSnapshot(mock, filmID, after)
+	expectFilmSnapshot(mock, filmID, after)
+	mock.ExpectRollback()
+
+	// та же дата в другом формате не считается изменением
+	unchanged := *after
+	unchanged.ReleaseDate = "1994"
+	unchanged.Actors = []actor.Actor{{Name: "Morgan Freeman", Gender: "man", BirthDate: "1937-06-01"}}
+	updated, version, err = repo.Patch(context.Background(), filmID, &unchanged)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if version != 2 {
+		t.Errorf("expected version 2, got %d", version)
+	}
+	if !reflect.DeepEqual(updated, after) {
+		t.Errorf("expected %v, got %v", after, updated)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestFilmRepository_Delete(t *testing.T) {
@@ -211,18 +718,40 @@ func TestFilmRepository_Delete(t *testing.T) {
 	// Creating a new repository with mocked DB
 	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
 
+	filmToDelete := &film.Film{
+		Title:       "TestFilm",
+		Description: "TestDescription",
+		ReleaseDate: "01.01.2023",
+		Rating:      9,
+		Actors: []actor.Actor{
+			{Name: "Tim Robbins", Gender: "man", BirthDate: "16.10.1958"},
+		},
+	}
+
 	// Mocking the transaction and queries
 	mock.ExpectBegin()
+	expectVersionBump(mock, "film", 1, 2)
+	expectFilmSnapshot(mock, 1, filmToDelete)
+	// the revision history outlives the film
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(1, 4, "delete", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(4, 1))
 	mock.ExpectExec("DELETE FROM film_actor WHERE film_id = ?").
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(0, 1)) // 1 row affected
 	mock.ExpectExec("DELETE FROM film WHERE id = ?").
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(0, 1)) // 1 row affected
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", int64(1), "delete", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// Calling the method
-	err = repo.Delete(1)
+	err = repo.Delete(context.Background(), 1, true)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -236,13 +765,21 @@ func TestFilmRepository_Delete(t *testing.T) {
 
 	// Query error
 	mock.ExpectBegin()
+	expectVersionBump(mock, "film", 2, 2)
+	expectFilmSnapshot(mock, 2, filmToDelete)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(2, 2, "delete", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectExec("DELETE FROM film_actor WHERE film_id = ?").
 		WithArgs(2).
 		WillReturnError(fmt.Errorf("db_error"))
 	mock.ExpectRollback()
 
 	// Calling the method
-	err = repo.Delete(2)
+	err = repo.Delete(context.Background(), 2, true)
 	if err == nil {
 		t.Error("expected error, got nil")
 		return
@@ -253,6 +790,53 @@ func TestFilmRepository_Delete(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 		return
 	}
+
+	// Film with actors isn't deleted without cascade
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", 3, 2)
+	expectFilmSnapshot(mock, 3, filmToDelete)
+	mock.ExpectRollback()
+
+	err = repo.Delete(context.Background(), 3, false)
+	var refErr *film.ReferencedError
+	if !errors.As(err, &refErr) {
+		t.Errorf("expected ReferencedError, got %v", err)
+		return
+	}
+	if !reflect.DeepEqual(refErr.Actors, filmToDelete.Actors) {
+		t.Errorf("expected actors %v, got %v", filmToDelete.Actors, refErr.Actors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
+
+	// Film without actors doesn't need cascade
+	mock.ExpectBegin()
+	expectVersionBump(mock, "film", 4, 2)
+	expectFilmSnapshot(mock, 4, &film.Film{Title: "NoCast", ReleaseDate: "2023", Rating: 5})
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(4, 2, "delete", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("DELETE FROM film WHERE id = ?").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", int64(4), "delete", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.Delete(context.Background(), 4, false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestFilmRepository_GetAllFilms(t *testing.T) {
@@ -266,8 +850,8 @@ func TestFilmRepository_GetAllFilms(t *testing.T) {
 	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
 
 	// Valid column test
-	mock.ExpectPrepare("SELECT title, description, release_date, rating FROM film ORDER BY title")
-	mock.ExpectQuery("SELECT title, description, release_date, rating FROM film ORDER BY title").
+	mock.ExpectPrepare("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film ORDER BY title")
+	mock.ExpectQuery("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film ORDER BY title").
 		WillReturnRows(sqlmock.NewRows([]string{"title", "description", "release_date", "rating"}))
 
 	_, err = repo.GetAllFilms("title")
@@ -288,7 +872,7 @@ func TestFilmRepository_GetAllFilms(t *testing.T) {
 	}
 
 	// Prepare error test
-	mock.ExpectPrepare("SELECT title, description, release_date, rating FROM film ORDER BY title").
+	mock.ExpectPrepare("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film ORDER BY title").
 		WillReturnError(fmt.Errorf("prepare_error"))
 
 	_, err = repo.GetAllFilms("title")
@@ -302,8 +886,8 @@ func TestFilmRepository_GetAllFilms(t *testing.T) {
 	}
 
 	// Query execution error test
-	mock.ExpectPrepare("SELECT title, description, release_date, rating FROM film ORDER BY title")
-	mock.ExpectQuery("SELECT title, description, release_date, rating FROM film ORDER BY title").
+	mock.ExpectPrepare("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film ORDER BY title")
+	mock.ExpectQuery("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film ORDER BY title").
 		WillReturnError(fmt.Errorf("query_execution_error"))
 
 	_, err = repo.GetAllFilms("title")
@@ -317,8 +901,8 @@ func TestFilmRepository_GetAllFilms(t *testing.T) {
 	}
 
 	// Row scan error test
-	mock.ExpectPrepare("SELECT title, description, release_date, rating FROM film ORDER BY title")
-	mock.ExpectQuery("SELECT title, description, release_date, rating FROM film ORDER BY title").
+	mock.ExpectPrepare("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film ORDER BY title")
+	mock.ExpectQuery("SELECT title, description, partial_date\\(release_date, release_date_precision, release_date_approx\\), rating FROM film ORDER BY title").
 		WillReturnRows(sqlmock.NewRows([]string{"title"}).AddRow("film1"))
 
 	_, err = repo.GetAllFilms("title")
@@ -438,3 +1022,69 @@ func TestFilmRepository_GetAllFilms(t *testing.T) {
 //		return
 //	}
 //}
+
+func TestFilmRepository_EachFilm(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
+
+	columns := []string{"id", "title", "description", "release_date", "rating", "name", "gender", "birth_date"}
+	mock.ExpectQuery("SELECT f.id, f.title, f.description, partial_date\\(f.release_date, f.release_date_precision, f.release_date_approx\\), f.rating, a.name, a.gender, partial_date\\(a.birth_date, a.birth_date_precision, a.birth_date_approx\\) FROM film f").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "Film1", "Description1", "01.01.2000", 8, "Actor1", "man", "12.03.1995").
+			AddRow(1, "Film1", "Description1", "01.01.2000", 8, "Actor2", "woman", "10.05.1989").
+			AddRow(2, "Film2", "Description2", "02.02.2002", 7, nil, nil, nil))
+
+	var films []film.Film
+	err = repo.EachFilm("title", func(f *film.Film) error {
+		films = append(films, *f)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
+
+	if len(films) != 2 || len(films[0].Actors) != 2 || films[0].Actors[1].Name != "Actor2" || films[1].Title != "Film2" || films[1].Actors != nil {
+		t.Errorf("unexpected films: %+v", films)
+	}
+
+	// Invalid column test
+	err = repo.EachFilm("id; DROP TABLE film", func(f *film.Film) error { return nil })
+	if err == nil {
+		t.Error("expected error, got nil for invalid column")
+	}
+}
+
+func TestFilmRepository_CatalogVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
+
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT version, modified_at FROM catalog_version").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "modified_at"}).AddRow(7, modified))
+
+	version, gotModified, err := repo.CatalogVersion()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if version != 7 || !gotModified.Equal(modified) {
+		t.Errorf("expected version 7 at %v, got %d at %v", modified, version, gotModified)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
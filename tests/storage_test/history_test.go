@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"filmoteka/internal/history"
+	"github.com/DATA-DOG/go-sqlmock"
+	"reflect"
+	"testing"
+)
+
+func TestHistoryRepository_FindFilm(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := history.NewHistoryRepository(db)
+
+	mock.ExpectQuery("SELECT id FROM film WHERE lower\\(title\\) = lower\\(\\$1\\)").
+		WithArgs("film1", "2000").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM film WHERE lower\\(title\\) = lower\\(\\$1\\)").
+		WithArgs("Film2", "2001").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(3))
+
+	filmId, err := repo.FindFilm("film1", "2000")
+	if err != nil || filmId != 1 {
+		t.Errorf("expected film 1, got %d, %v", filmId, err)
+	}
+	_, err = repo.FindFilm("Film2", "2001")
+	if !errors.Is(err, history.ErrAmbiguous) {
+		t.Errorf("expected ErrAmbiguous, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHistoryRepository_Save(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := history.NewHistoryRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user_film_rating").
+		WithArgs(5, 1, 9, history.SourceLetterboxd).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_film_watch").
+		WithArgs(5, 1, "2021-01-04", history.SourceLetterboxd).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO user_film_watch").
+		WithArgs(5, 2, "2021-01-06", history.SourceLetterboxd).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = repo.Save(5, history.SourceLetterboxd, []history.Match{
+		{FilmID: 1, Entry: history.Entry{Title: "Film1", Year: "2000", Rating: 9, WatchedAt: "2021-01-04"}},
+		{FilmID: 2, Entry: history.Entry{Title: "Film2", Year: "2005", WatchedAt: "2021-01-06"}},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestHistoryRepository_Get(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := history.NewHistoryRepository(db)
+
+	mock.ExpectQuery("SELECT f.id, f.title, partial_date\\(f.release_date, f.release_date_precision, f.release_date_approx\\)").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "release_date", "rating", "watched_at"}).
+			AddRow(1, "Film1", "2000-01-01", 9, "2021-01-04").
+			AddRow(1, "Film1", "2000-01-01", 9, "2021-02-05").
+			AddRow(2, "Film2", "2005-01-01", 0, nil))
+
+	items, err := repo.Get(5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []history.Item{
+		{Title: "Film1", ReleaseDate: "01.01.2000", Rating: 9, WatchedAt: []string{"04.01.2021", "05.02.2021"}},
+		{Title: "Film2", ReleaseDate: "01.01.2005"},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("expected %+v, got %+v", expected, items)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"database/sql"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/internal/imdb"
+	"github.com/DATA-DOG/go-sqlmock"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTSV(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImdbRepository_Import(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	opts := imdb.Options{
+		TitlesPath: writeTSV(t, "title.basics.tsv", "tconst\ttitleType\tprimaryTitle\tisAdult\tstartYear\tgenres\n"+
+			"tt0000001\tmovie\tImported earlier\t0\t1990\tDrama\n"+
+			"tt0000002\tmovie\tFilm2\t0\t2000\tDrama,Comedy\n"+
+			"tt0000003\tshort\tShort\t0\t2001\tShort\n"+
+			"tt0000004\tmovie\tNo genres\t0\t2002\t\\N\n"),
+		NamesPath: writeTSV(t, "name.basics.tsv", "nconst\tprimaryName\tbirthYear\n"+
+			"nm0000001\tActor1\t1970\n"+
+			"nm0000002\tDirector\t1960\n"),
+		PrincipalsPath: writeTSV(t, "title.principals.tsv", "tconst\tnconst\tcategory\n"+
+			"tt0000002\tnm0000001\tactor\n"+
+			"tt0000002\tnm0000002\tdirector\n"),
+		BatchSize: 100,
+	}
+
+	// titles: the first line was saved by a previous run
+	mock.ExpectQuery("SELECT line FROM imdb_import_progress").
+		WithArgs(imdb.StageTitles).
+		WillReturnRows(sqlmock.NewRows([]string{"line"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO film").
+		WithArgs("Film2", "Drama, Comedy", "2000-01-01", imdb.DefaultRating, "tt0000002").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	// created films get their first revision and an audit entry
+	film2 := &film.Film{Title: "Film2", Description: "Drama, Comedy", ReleaseDate: "2000", Rating: imdb.DefaultRating}
+	expectFilmSnapshot(mock, 2, film2)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(2, 1, "initial", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", int64(2), "create", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// the film without genres has no description and is skipped
+	mock.ExpectExec("INSERT INTO imdb_import_progress").
+		WithArgs(imdb.StageTitles, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// names: the actor already exists without imdb_id
+	mock.ExpectQuery("SELECT line FROM imdb_import_progress").
+		WithArgs(imdb.StageNames).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT imdb_id FROM film").
+		WillReturnRows(sqlmock.NewRows([]string{"imdb_id"}).AddRow("tt0000001").AddRow("tt0000002"))
+	mock.ExpectQuery("SELECT line FROM imdb_import_progress").
+		WithArgs(imdb.StageNames).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO actor").
+		WithArgs("Actor1", "man", "1970-01-01", "nm0000001").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("UPDATE actor SET imdb_id").
+		WithArgs("nm0000001", "Actor1", "man", "1970-01-01").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO imdb_import_progress").
+		WithArgs(imdb.StageNames, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// links
+	mock.ExpectQuery("SELECT line FROM imdb_import_progress").
+		WithArgs(imdb.StageLinks).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT f.id, a.id FROM film f, actor a").
+		WithArgs("tt0000002", "nm0000001").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id"}).AddRow(2, 1))
+	expectFilmSnapshot(mock, 2, film2)
+	mock.ExpectExec("INSERT INTO film_actor").
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// the film with a new cast gets a new version, revision and audit entry once per batch
+	expectFilmSnapshot(mock, 2, &film.Film{Title: "Film2", Description: "Drama, Comedy", ReleaseDate: "2000", Rating: imdb.DefaultRating,
+		Actors: []actor.Actor{{Name: "Actor1", Gender: "man", BirthDate: "1970"}}})
+	expectVersionBump(mock, "film", 2, 2)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(2, 2, "update", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", int64(2), "update", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO imdb_import_progress").
+		WithArgs(imdb.StageLinks, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = imdb.NewImdbRepository(db).Import(opts)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
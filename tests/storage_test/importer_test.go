@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"filmoteka/internal/film"
+	"filmoteka/internal/importer"
+	"filmoteka/pkg"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+)
+
+func TestImportRepository_Import(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := importer.NewImportRepository(db)
+
+	records := []importer.Record{
+		{Row: 1, Film: film.Film{Title: "Film1", Description: "Description1", ReleaseDate: "01.01.2000", Rating: 8}},
+		{Row: 2, Film: film.Film{Title: "Film2", Description: "Description2", ReleaseDate: "01.01.2000", Rating: 11}},
+		{Row: 3, Film: film.Film{Title: "Film3", Description: "Description3", ReleaseDate: "01.01.2000", Rating: 5}},
+	}
+
+	mock.ExpectBegin()
+	// Film1 is new
+	mock.ExpectExec("SAVEPOINT import_film").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id FROM film WHERE title =").
+		WithArgs("Film1", "2000-01-01", pkg.PrecisionDay, false).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("INSERT INTO film").
+		WithArgs("Film1", "Description1", "2000-01-01", pkg.PrecisionDay, false, 8).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	// created films get their first revision and an audit entry
+	expectFilmSnapshot(mock, 1, &records[0].Film)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(revision\\), 0\\) FROM film_revision").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO film_revision").
+		WithArgs(1, 1, "initial", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(nil, "film", int64(1), "create", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT import_film").WillReturnResult(sqlmock.NewResult(0, 0))
+	// Film2 fails validation and doesn't touch the database
+	// Film3 fails in the database and is rolled back to the savepoint
+	mock.ExpectExec("SAVEPOINT import_film").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id FROM film WHERE title =").
+		WithArgs("Film3", "2000-01-01", pkg.PrecisionDay, false).
+		WillReturnError(fmt.Errorf("db_error"))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT import_film").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	report, err := repo.Import(context.Background(), records, importer.Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	if !report.DryRun || report.Created != 1 || report.Errors != 2 || len(report.Rows) != 3 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if report.Rows[0].Status != importer.StatusCreated || report.Rows[1].Status != importer.StatusError || report.Rows[2].Error != "db_error" {
+		t.Errorf("unexpected report rows: %+v", report.Rows)
+	}
+}
+
+func TestImportRepository_ImportCanceled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := importer.NewImportRepository(db)
+
+	// the batch transaction is bound to the request context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	records := []importer.Record{
+		{Row: 1, Film: film.Film{Title: "Film1", Description: "Description1", ReleaseDate: "01.01.2000", Rating: 8}},
+	}
+	_, err = repo.Import(ctx, records, importer.Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/internal/memory"
+	"filmoteka/pkg"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newMemoryRepos() (*memory.FilmRepository, *memory.ActorRepository) {
+	store := memory.NewStore()
+	return memory.NewFilmRepository(store), memory.NewActorRepository(store)
+}
+
+func TestMemoryFilmRepository_AddAndGet(t *testing.T) {
+	films, actors := newMemoryRepos()
+
+	newFilm := &film.Film{
+		Title:       "Film",
+		Description: "Description",
+		ReleaseDate: "2020-01-02",
+		Rating:      8,
+		Actors: []actor.Actor{
+			{Name: "Zed", Gender: "man", BirthDate: "1990"},
+			{Name: "Anna", Gender: "woman", BirthDate: "01.02.1991"},
+		},
+	}
+	if err := films.Add(context.Background(), newFilm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	filmId, err := films.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "02.01.2020"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, version, err := films.GetFilm(filmId)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &film.Film{
+		Title:       "Film",
+		Description: "Description",
+		ReleaseDate: "02.01.2020",
+		Rating:      8,
+		Actors: []actor.Actor{
+			{Name: "Anna", Gender: "woman", BirthDate: "01.02.1991"},
+			{Name: "Zed", Gender: "man", BirthDate: "1990"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) || version != 1 {
+		t.Errorf("expected %v version 1, got %v version %d", expected, got, version)
+	}
+
+	// актеры фильма создаются вместе с ним
+	if _, err := actors.GetActorId(&actor.Actor{Name: "Zed", Gender: "man", BirthDate: "1990"}); err != nil {
+		t.Errorf("expected actor to be created: %v", err)
+	}
+}
+
+func TestMemoryFilmRepository_Uniqueness(t *testing.T) {
+	films, actors := newMemoryRepos()
+
+	if err := films.Add(context.Background(), &film.Film{Title: "Film", ReleaseDate: "2020", Rating: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := films.Add(context.Background(), &film.Film{Title: "Film", ReleaseDate: "2020", Rating: 6})
+	if !errors.Is(err, memory.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+	// дата с другой точностью - другая дата
+	err = films.Add(context.Background(), &film.Film{Title: "Film", ReleaseDate: "01.01.2020", Rating: 5})
+	if err != nil {
+		t.Errorf("unexpected error for another precision: %v", err)
+	}
+
+	err = films.Add(context.Background(), &film.Film{Title: "Other", ReleaseDate: "2020", Rating: 5, Actors: []actor.Actor{
+		{Name: "Actor", Gender: "man", BirthDate: "1990"},
+		{Name: "Actor", Gender: "man", BirthDate: "1990"},
+	}})
+	if !errors.Is(err, memory.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate for repeated actor, got %v", err)
+	}
+	// при ошибке ничего не сохраняется
+	if _, err := actors.GetActorId(&actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no actor after failed add, got %v", err)
+	}
+
+	err = films.Add(context.Background(), &film.Film{Title: "Bad", ReleaseDate: "2020", Rating: 11})
+	if !errors.Is(err, memory.ErrInvalid) {
+		t.Errorf("expected ErrInvalid for rating, got %v", err)
+	}
+
+	if err := actors.Add(&actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = actors.Add(&actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+	if !errors.Is(err, memory.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+	err = actors.Add(&actor.Actor{Name: "Actor", Gender: "other", BirthDate: "1990"})
+	if !errors.Is(err, memory.ErrInvalid) {
+		t.Errorf("expected ErrInvalid for gender, got %v", err)
+	}
+}
+
+func TestMemoryFilmRepository_UpdateVersionsAndRevisions(t *testing.T) {
+	films, _ := newMemoryRepos()
+	ctx := context.Background()
+
+	films.Add(ctx, &film.Film{Title: "Film", ReleaseDate: "2020", Rating: 5})
+	filmId, _ := films.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "2020"})
+
+	// без изменений версия и ревизии не меняются
+	_, version, err := films.Update(ctx, filmId, &film.Film{Title: "Film", ReleaseDate: "2020", Rating: 5})
+	if err != nil || version != 1 {
+		t.Fatalf("expected version 1, got %d, %v", version, err)
+	}
+
+	updated, version, err := films.Update(pkg.WithIfMatch(ctx, pkg.ETag(1)), filmId, &film.Film{
+		Title: "Film", ReleaseDate: "2020", Rating: 7,
+		Actors: []actor.Actor{{Name: "Actor", Gender: "man", BirthDate: "1990"}},
+	})
+	if err != nil || version != 2 || updated.Rating != 7 || len(updated.Actors) != 1 {
+		t.Fatalf("unexpected update result: %v, %d, %v", updated, version, err)
+	}
+
+	_, _, err = films.Update(pkg.WithIfMatch(ctx, pkg.ETag(1)), filmId, &film.Film{Title: "Film", ReleaseDate: "2020", Rating: 9})
+	if !errors.Is(err, pkg.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	_, _, err = films.AddActor(ctx, filmId, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+	if !errors.Is(err, film.ErrActorInFilm) {
+		t.Errorf("expected ErrActorInFilm, got %v", err)
+	}
+	_, version, err = films.RemoveActor(ctx, filmId, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+	if err != nil || version != 3 {
+		t.Errorf("expected version 3, got %d, %v", version, err)
+	}
+	_, _, err = films.RemoveActor(ctx, filmId, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+	if !errors.Is(err, film.ErrActorNotInFilm) {
+		t.Errorf("expected ErrActorNotInFilm, got %v", err)
+	}
+
+	revisions, err := films.Revisions(filmId)
+	if err != nil || len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %v, %v", revisions, err)
+	}
+	if revisions[0].Action != film.RevisionInitial || revisions[0].Film.Rating != 5 || revisions[2].Action != film.RevisionUpdate {
+		t.Errorf("unexpected revisions: %v", revisions)
+	}
+
+	err = films.Rollback(ctx, filmId, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rolledBack, version, _ := films.GetFilm(filmId)
+	if rolledBack.Rating != 5 || version != 4 {
+		t.Errorf("expected rating 5 version 4 after rollback, got %v version %d", rolledBack, version)
+	}
+	if _, err := films.Revision(filmId, 4); err != nil {
+		t.Errorf("expected rollback revision: %v", err)
+	}
+	if _, err := films.Revision(filmId, 5); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestMemoryRepository_DeleteReferenced(t *testing.T) {
+	films, actors := newMemoryRepos()
+	ctx := context.Background()
+
+	films.Add(ctx, &film.Film{Title: "Film", ReleaseDate: "2020", Rating: 5, Actors: []actor.Actor{{Name: "Actor", Gender: "man", BirthDate: "1990"}}})
+	filmId, _ := films.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "2020"})
+	actorId, _ := actors.GetActorId(&actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990"})
+
+	var filmRef *film.ReferencedError
+	err := films.Delete(ctx, filmId, false)
+	if !errors.As(err, &filmRef) || len(filmRef.Actors) != 1 {
+		t.Errorf("expected film.ReferencedError, got %v", err)
+	}
+
+	var actorRef *actor.ReferencedError
+	err = actors.Delete(ctx, actorId, false)
+	if !errors.As(err, &actorRef) || !reflect.DeepEqual(actorRef.Films, []actor.FilmRef{{Title: "Film", ReleaseDate: "2020"}}) {
+		t.Errorf("expected actor.ReferencedError, got %v", err)
+	}
+
+	if err := actors.Delete(ctx, actorId, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _, _ := films.GetFilm(filmId)
+	if len(got.Actors) != 0 {
+		t.Errorf("expected cascade delete to remove the actor from the film, got %v", got.Actors)
+	}
+	if err := films.Delete(ctx, filmId, false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, _, err := films.GetFilm(filmId); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestMemoryFilmRepository_Lists(t *testing.T) {
+	films, _ := newMemoryRepos()
+
+	films.Add(context.Background(), &film.Film{Title: "B", ReleaseDate: "2001", Rating: 9, Actors: []actor.Actor{{Name: "John", Gender: "man", BirthDate: "1990"}}})
+	films.Add(context.Background(), &film.Film{Title: "A", ReleaseDate: "2003", Rating: 7})
+	films.Add(context.Background(), &film.Film{Title: "C", ReleaseDate: "2002", Rating: 8})
+	version, _, _ := films.CatalogVersion()
+
+	titles := func(list []film.Film) []string {
+		var result []string
+		for _, f := range list {
+			result = append(result, f.Title)
+		}
+		return result
+	}
+	for sortCol, expected := range map[string][]string{
+		"title":        {"A", "B", "C"},
+		"release_date": {"B", "C", "A"},
+		"rating":       {"A", "C", "B"},
+	} {
+		list, err := films.GetAllFilms(sortCol)
+		if err != nil || !reflect.DeepEqual(titles(list), expected) {
+			t.Errorf("%s: expected %v, got %v, %v", sortCol, expected, titles(list), err)
+		}
+	}
+	if _, err := films.GetAllFilms("id; DROP TABLE film"); err == nil {
+		t.Error("expected error for invalid sort column")
+	}
+
+	found, err := films.FindFilms("Joh")
+	if err != nil || !reflect.DeepEqual(titles(found), []string{"B"}) {
+		t.Errorf("expected film found by actor, got %v, %v", found, err)
+	}
+	if _, err := films.FindFilms("missing"); err == nil {
+		t.Error("expected error when nothing found")
+	}
+
+	list, _ := films.ActorsListWithFilms()
+	johnFilms := list[actor.Actor{Name: "John", Gender: "man", BirthDate: "1990"}]
+	if !reflect.DeepEqual(titles(johnFilms), []string{"B"}) {
+		t.Errorf("unexpected actors list: %v", list)
+	}
+
+	films.Add(context.Background(), &film.Film{Title: "D", ReleaseDate: "2004", Rating: 1})
+	newVersion, _, _ := films.CatalogVersion()
+	if newVersion <= version {
+		t.Errorf("expected catalog version to grow, got %d after %d", newVersion, version)
+	}
+}
+
+func TestMemoryRepository_Concurrent(t *testing.T) {
+	films, _ := newMemoryRepos()
+	ctx := context.Background()
+
+	films.Add(ctx, &film.Film{Title: "Film", ReleaseDate: "2020", Rating: 1})
+	filmId, _ := films.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "2020"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			films.AddActor(ctx, filmId, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: pkg.FormatDate(time.Date(1950+i, 1, 1, 0, 0, 0, 0, time.UTC))})
+			films.GetAllFilms("title")
+		}(i)
+	}
+	wg.Wait()
+
+	got, version, _ := films.GetFilm(filmId)
+	if len(got.Actors) != 50 || version != 51 {
+		t.Errorf("expected 50 actors and version 51, got %d actors and version %d", len(got.Actors), version)
+	}
+}
+
+// После изменения актера GET фильма с прежним ETag возвращает фильм с новыми
+// данными актера, а не 304.
+func TestMemory_GetFilmAfterActorChange(t *testing.T) {
+	films, actors := newMemoryRepos()
+	filmHandler := &film.FilmHandler{FilmRepo: films, ActorRepo: actors}
+	actorHandler := &actor.ActorHandler{ActorRepo: actors}
+
+	err := films.Add(context.Background(), &film.Film{Title: "Film", Description: "d", ReleaseDate: "2020", Rating: 5,
+		Actors: []actor.Actor{{Name: "Anna", Gender: "woman", BirthDate: "1990"}}})
+	if err != nil {
+		t.Fatalf("can't add film: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	filmHandler.GetFilm(rr, httptest.NewRequest(http.MethodGet, "/user/film?title=Film&release_date=2020", nil))
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("unexpected response: %d %v", rr.Code, rr.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/actor/patch?name=Anna&gender=woman&birth_date=1990",
+		strings.NewReader(`{"name":"Anna B"}`))
+	req.Header.Set("Content-Type", pkg.MergePatchType)
+	rr = httptest.NewRecorder()
+	actorHandler.PatchActor(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("can't patch actor: %d %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/user/film?title=Film&release_date=2020", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	filmHandler.GetFilm(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"name":"Anna B"`) {
+		t.Errorf("expected film with patched actor, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("ETag") == etag {
+		t.Errorf("expected new ETag, got %s", etag)
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"filmoteka/internal/moderation"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+)
+
+func TestModerationRepository_Approve(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := moderation.NewModerationRepository(db)
+	data := json.RawMessage(`{"name":"John","gender":"man","birth_date":"01.01.1990"}`)
+
+	// actor is added and the submission approved in one transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT entity FROM submission WHERE id = \\$1 AND status = \\$2 FOR UPDATE").
+		WithArgs(3, moderation.StatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"entity"}).AddRow(moderation.EntityActor))
+	mock.ExpectExec("INSERT INTO actor").
+		WithArgs("John", "man", "1990-01-01", sqlmock.AnyArg(), false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE submission SET status").
+		WithArgs(moderation.StatusApproved, string(data), 1, "", 3, moderation.StatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.Approve(context.Background(), 3, data, 1); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// submission reviewed concurrently: nothing is added
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT entity FROM submission").
+		WithArgs(3, moderation.StatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"entity"}))
+	mock.ExpectRollback()
+
+	if err := repo.Approve(context.Background(), 3, data, 1); !errors.Is(err, moderation.ErrAlreadyReviewed) {
+		t.Errorf("expected ErrAlreadyReviewed, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// failed insert leaves the submission pending
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT entity FROM submission").
+		WithArgs(3, moderation.StatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"entity"}).AddRow(moderation.EntityActor))
+	mock.ExpectExec("INSERT INTO actor").
+		WillReturnError(fmt.Errorf("db_error"))
+	mock.ExpectRollback()
+
+	if err := repo.Approve(context.Background(), 3, data, 1); err == nil {
+		t.Error("expected error, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
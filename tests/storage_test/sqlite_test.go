@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"filmoteka/internal/actor"
+	"filmoteka/internal/audit"
+	"filmoteka/internal/auth"
+	"filmoteka/internal/film"
+	"filmoteka/internal/history"
+	"filmoteka/internal/migrate"
+	"filmoteka/internal/sqlite"
+	"filmoteka/pkg"
+	"filmoteka/schema"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	return openSQLiteDSN(t, sqlite.Scheme+filepath.Join(t.TempDir(), "filmoteka.db"))
+}
+
+func openSQLiteDSN(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sqlite.Open(dsn)
+	if err != nil {
+		t.Fatalf("can't open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.NewSQLiteMigrator(db, schema.SQLiteMigrations)
+	if err != nil {
+		t.Fatalf("can't load migrations: %v", err)
+	}
+	_, err = migrator.Up(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("can't migrate: %v", err)
+	}
+	return db
+}
+
+func TestSQLite_Migrations(t *testing.T) {
+	db := openSQLite(t)
+
+	migrator, _ := migrate.NewSQLiteMigrator(db, schema.SQLiteMigrations)
+	ctx := context.Background()
+	if _, err := migrator.Down(ctx, -1); err != nil {
+		t.Fatalf("can't migrate down: %v", err)
+	}
+	applied, err := migrator.Up(ctx, 0)
+	if err != nil || len(applied) == 0 {
+		t.Fatalf("can't migrate up again: %v, %v", applied, err)
+	}
+	status, err := migrator.Status(ctx)
+	if err != nil || status.Dirty || status.Version != applied[len(applied)-1].Version {
+		t.Errorf("unexpected status: %+v, %v", status, err)
+	}
+}
+
+func TestSQLite_FilmRepository(t *testing.T) {
+	db := openSQLite(t)
+	actorRepo := actor.NewActorRepository(db)
+	repo := film.NewFilmRepository(actorRepo, db)
+	ctx := context.Background()
+
+	err := repo.Add(ctx, &film.Film{
+		Title: "Film", Description: "Description", ReleaseDate: "1920~", Rating: 8,
+		Actors: []actor.Actor{{Name: "Zed", Gender: "man", BirthDate: "05.1890"}, {Name: "Anna", Gender: "woman", BirthDate: "1891-02-03"}},
+	})
+	if err != nil {
+		t.Fatalf("can't add film: %v", err)
+	}
+	if err := repo.Add(ctx, &film.Film{Title: "Film", Description: "Copy", ReleaseDate: "1920~", Rating: 5}); err == nil {
+		t.Error("expected unique violation for the same title and release date")
+	}
+
+	filmId, err := repo.GetFilmId(&film.Film{Title: "Film", ReleaseDate: "1920~"})
+	if err != nil {
+		t.Fatalf("can't get film id: %v", err)
+	}
+	got, version, err := repo.GetFilm(filmId)
+	if err != nil {
+		t.Fatalf("can't get film: %v", err)
+	}
+	expected := &film.Film{
+		Title: "Film", Description: "Description", ReleaseDate: "1920~", Rating: 8,
+		Actors: []actor.Actor{{Name: "Anna", Gender: "woman", BirthDate: "03.02.1891"}, {Name: "Zed", Gender: "man", BirthDate: "05.1890"}},
+	}
+	if !reflect.DeepEqual(got, expected) || version != 1 {
+		t.Errorf("expected %v version 1, got %v version %d", expected, got, version)
+	}
+
+	updated, version, err := repo.Update(pkg.WithIfMatch(ctx, pkg.ETag(1)), filmId, &film.Film{
+		Title: "Film", Description: "Updated", ReleaseDate: "1920~", Rating: 9,
+		Actors: []actor.Actor{{Name: "Anna", Gender: "woman", BirthDate: "1891-02-03"}},
+	})
+	if err != nil || version != 2 || updated.Description != "Updated" || len(updated.Actors) != 1 {
+		t.Fatalf("unexpected update: %v, %d, %v", updated, version, err)
+	}
+	_, _, err = repo.Update(pkg.WithIfMatch(ctx, pkg.ETag(1)), filmId, &film.Film{Title: "Film", Description: "Stale", ReleaseDate: "1920~", Rating: 1})
+	if !errors.Is(err, pkg.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	_, _, err = repo.AddActor(ctx, filmId, &actor.Actor{Name: "Anna", Gender: "woman", BirthDate: "03.02.1891"})
+	if !errors.Is(err, film.ErrActorInFilm) {
+		t.Errorf("expected ErrActorInFilm, got %v", err)
+	}
+
+	revisions, err := repo.Revisions(filmId)
+	if err != nil || len(revisions) != 2 || revisions[0].Film.Description != "Description" {
+		t.Fatalf("unexpected revisions: %v, %v", revisions, err)
+	}
+	if err := repo.Rollback(ctx, filmId, 1); err != nil {
+		t.Fatalf("can't roll back: %v", err)
+	}
+	rolledBack, _, _ := repo.GetFilm(filmId)
+	if !reflect.DeepEqual(rolledBack, expected) {
+		t.Errorf("expected %v after rollback, got %v", expected, rolledBack)
+	}
+
+	entries, err := audit.NewAuditRepository(db).Find(&audit.Filter{Entity: audit.EntityFilm})
+	if err != nil || len(entries) != 3 || entries[2].Action != audit.ActionCreate {
+		t.Errorf("expected create, update and rollback audit entries, got %v, %v", entries, err)
+	}
+
+	err = repo.Delete(ctx, filmId, false)
+	var refErr *film.ReferencedError
+	if !errors.As(err, &refErr) || len(refErr.Actors) != 2 {
+		t.Errorf("expected film.ReferencedError, got %v", err)
+	}
+	if err := repo.Delete(ctx, filmId, true); err != nil {
+		t.Errorf("can't delete film: %v", err)
+	}
+}
+
+func TestSQLite_Search(t *testing.T) {
+	db := openSQLite(t)
+	repo := film.NewFilmRepository(actor.NewActorRepository(db), db)
+
+	repo.Add(context.Background(), &film.Film{Title: "Brazil", Description: "d", ReleaseDate: "1985", Rating: 9, Actors: []actor.Actor{{Name: "Jonathan Pryce", Gender: "man", BirthDate: "1947"}}})
+	repo.Add(context.Background(), &film.Film{Title: "Alien", Description: "d", ReleaseDate: "1979-05-25", Rating: 8})
+	repo.Add(context.Background(), &film.Film{Title: "Casablanca", Description: "d", ReleaseDate: "1942-11-26", Rating: 7})
+	before, _, err := repo.CatalogVersion()
+	if err != nil {
+		t.Fatalf("can't get catalog version: %v", err)
+	}
+
+	titles := func(films []film.Film) []string {
+		var result []string
+		for _, f := range films {
+			result = append(result, f.Title)
+		}
+		return result
+	}
+	for sortCol, expected := range map[string][]string{
+		"title":        {"Alien", "Brazil", "Casablanca"},
+		"release_date": {"Casablanca", "Alien", "Brazil"},
+		"rating":       {"Casablanca", "Alien", "Brazil"},
+	} {
+		films, err := repo.GetAllFilms(sortCol)
+		if err != nil || !reflect.DeepEqual(titles(films), expected) {
+			t.Errorf("%s: expected %v, got %v, %v", sortCol, expected, titles(films), err)
+		}
+	}
+
+	// LIKE учитывает регистр, как в PostgreSQL
+	if _, err := repo.FindFilms("brazil"); err == nil {
+		t.Error("expected case sensitive search")
+	}
+	found, err := repo.FindFilms("Pryce")
+	if err != nil || !reflect.DeepEqual(titles(found), []string{"Brazil"}) {
+		t.Errorf("expected film found by actor, got %v, %v", titles(found), err)
+	}
+
+	actors, err := repo.ActorsListWithFilms()
+	if err != nil || len(actors[actor.Actor{Name: "Jonathan Pryce", Gender: "man", BirthDate: "1947"}]) != 1 {
+		t.Errorf("unexpected actors list: %v, %v", actors, err)
+	}
+
+	filmId, err := history.NewHistoryRepository(db).FindFilm("ALIEN", "1979")
+	if err != nil || filmId == 0 {
+		t.Errorf("expected film found by title and year, got %d, %v", filmId, err)
+	}
+
+	repo.Add(context.Background(), &film.Film{Title: "Dune", Description: "d", ReleaseDate: "1984", Rating: 6})
+	after, _, _ := repo.CatalogVersion()
+	if after <= before {
+		t.Errorf("expected catalog version to grow, got %d after %d", after, before)
+	}
+}
+
+func TestSQLite_ActorRepository(t *testing.T) {
+	db := openSQLite(t)
+	repo := actor.NewActorRepository(db)
+	ctx := context.Background()
+
+	newActor := &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990-01-02"}
+	if err := repo.Add(newActor); err != nil {
+		t.Fatalf("can't add actor: %v", err)
+	}
+	if err := repo.Add(newActor); err == nil {
+		t.Error("expected unique violation")
+	}
+	if err := repo.Add(&actor.Actor{Name: "Other", Gender: "unknown", BirthDate: "1990"}); err == nil {
+		t.Error("expected check violation for gender")
+	}
+
+	actorId, err := repo.GetActorId(newActor)
+	if err != nil {
+		t.Fatalf("can't get actor id: %v", err)
+	}
+	patched, version, err := repo.Patch(ctx, actorId, &actor.Actor{Name: "Actor", Gender: "man", BirthDate: "1990~"})
+	if err != nil || version != 2 || patched.BirthDate != "1990~" {
+		t.Errorf("unexpected patch: %v, %d, %v", patched, version, err)
+	}
+	if err := repo.Delete(ctx, actorId, false); err != nil {
+		t.Errorf("can't delete actor: %v", err)
+	}
+	if _, _, err := repo.Get(actorId); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestSQLite_UsersAndSessions(t *testing.T) {
+	db := openSQLite(t)
+	users := auth.NewUserRepository(db)
+
+	user, err := users.Create("admin", "secret", true)
+	if err != nil {
+		t.Fatalf("can't create user: %v", err)
+	}
+	if _, err := users.Create("admin", "other", false); err == nil {
+		t.Error("expected unique violation for login")
+	}
+
+	sessions := auth.NewSessionsDB(db)
+	w := httptest.NewRecorder()
+	if err := sessions.Create(w, user); err != nil {
+		t.Fatalf("can't create session: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	sess, err := sessions.Check(r)
+	if err != nil || sess.UserID != user.ID || !sess.IsAdmin {
+		t.Fatalf("unexpected session: %v, %v", sess, err)
+	}
+
+	list, err := users.Sessions("admin")
+	if err != nil || len(list) != 1 {
+		t.Errorf("expected one session, got %v, %v", list, err)
+	}
+
+	if err := users.SetDisabled("admin", true); err != nil {
+		t.Fatalf("can't disable user: %v", err)
+	}
+	if _, err := sessions.Check(r); !errors.Is(err, auth.ErrNoAuth) {
+		t.Errorf("expected ErrNoAuth for disabled user, got %v", err)
+	}
+}
+
+// Временная база в памяти, с которой сервер работает в режиме STORAGE=memory,
+// одна на все запросы: пользователь, созданный в одном запросе, виден в других.
+func TestSQLite_MemoryDSN(t *testing.T) {
+	db := openSQLiteDSN(t, sqlite.MemoryDSN)
+	users := auth.NewUserRepository(db)
+	sessions := auth.NewSessionsDB(db)
+
+	user, err := users.Create("admin", "secret", true)
+	if err != nil {
+		t.Fatalf("can't create user: %v", err)
+	}
+	w := httptest.NewRecorder()
+	if err := sessions.Create(w, user); err != nil {
+		t.Fatalf("can't create session: %v", err)
+	}
+
+	cookies := w.Result().Cookies()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, cookie := range cookies {
+				r.AddCookie(cookie)
+			}
+			sess, err := sessions.Check(r)
+			if err != nil || sess.UserID != user.ID {
+				t.Errorf("unexpected session: %v, %v", sess, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
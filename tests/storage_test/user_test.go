@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"filmoteka/internal/auth"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+)
+
+// passwordHash проверяет, что аргумент - хеш пароля password в формате auth.HashPass.
+type passwordHash struct {
+	password string
+}
+
+func (arg passwordHash) Match(v driver.Value) bool {
+	hash, ok := v.([]byte)
+	return ok && len(hash) > 8 && bytes.Equal(hash, auth.HashPass(arg.password, string(hash[:8])))
+}
+
+func TestUserRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := auth.NewUserRepository(db)
+
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("admin", passwordHash{"secret"}, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	user, err := repo.Create("admin", "secret", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != 1 || user.Login != "admin" || !user.IsAdmin {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRepository_SetPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := auth.NewUserRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET password = \\$1 WHERE login = \\$2 RETURNING id").
+		WithArgs(passwordHash{"new"}, "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec("DELETE FROM sessions WHERE user_id = \\$1").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.SetPassword("user1", "new")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRepository_SetAdmin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := auth.NewUserRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET role = \\$1 WHERE login = \\$2 RETURNING id").
+		WithArgs(true, "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET role = \\$1 WHERE login = \\$2 RETURNING id").
+		WithArgs(false, "nobody").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = repo.SetAdmin("user1", true)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = repo.SetAdmin("nobody", false)
+	if !errors.Is(err, auth.ErrNoUser) {
+		t.Errorf("expected ErrNoUser, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRepository_SetDisabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := auth.NewUserRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET disabled = \\$1 WHERE login = \\$2 RETURNING id").
+		WithArgs(true, "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec("DELETE FROM sessions WHERE user_id = \\$1").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.SetDisabled("user1", true)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUserRepository_RevokeSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+	}
+	defer db.Close()
+
+	repo := auth.NewUserRepository(db)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("user1").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("DELETE FROM sessions WHERE user_id = \\(SELECT id FROM users WHERE login = \\$1\\)").
+		WithArgs("user1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	revoked, err := repo.RevokeSessions("user1")
+	if err != nil || revoked != 2 {
+		t.Errorf("expected 2 revoked sessions, got %d, %v", revoked, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
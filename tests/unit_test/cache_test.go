@@ -0,0 +1,79 @@
+package unit_test
+
+import (
+	"filmoteka/pkg"
+	"testing"
+	"time"
+)
+
+func TestLRU_Evicts(t *testing.T) {
+	cache := pkg.NewLRU(2, 0)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a in cache")
+	}
+	// b использовался давнее a, поэтому вытесняется он
+	cache.Set("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if value, ok := cache.Get("a"); !ok || value != 1 {
+		t.Errorf("expected a = 1, got %v, %v", value, ok)
+	}
+	if value, ok := cache.Get("c"); !ok || value != 3 {
+		t.Errorf("expected c = 3, got %v, %v", value, ok)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+}
+
+func TestLRU_SetReplaces(t *testing.T) {
+	cache := pkg.NewLRU(2, 0)
+
+	cache.Set("a", 1)
+	cache.Set("a", 2)
+	if value, ok := cache.Get("a"); !ok || value != 2 {
+		t.Errorf("expected a = 2, got %v, %v", value, ok)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", cache.Len())
+	}
+}
+
+func TestLRU_Expires(t *testing.T) {
+	cache := pkg.NewLRU(10, 20*time.Millisecond)
+
+	cache.Set("a", 1)
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a in cache")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected a to expire")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected expired entry to be removed, got %d entries", cache.Len())
+	}
+}
+
+func TestLRU_DeleteAndPurge(t *testing.T) {
+	cache := pkg.NewLRU(10, time.Minute)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected a to be deleted")
+	}
+	cache.Purge()
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be purged")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected empty cache, got %d entries", cache.Len())
+	}
+}
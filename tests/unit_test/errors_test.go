@@ -0,0 +1,86 @@
+package unit_test
+
+import (
+	"database/sql"
+	"errors"
+	"filmoteka/pkg"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"no rows", sql.ErrNoRows, pkg.ErrNotFound},
+		{"unique violation", &pq.Error{Code: "23505"}, pkg.ErrConflict},
+		{"foreign key violation", &pq.Error{Code: "23503"}, pkg.ErrConflict},
+		{"check violation", &pq.Error{Code: "23514"}, pkg.ErrValidation},
+		{"wrapped", fmt.Errorf("film_repo.Add: %w", &pq.Error{Code: "23505"}), pkg.ErrConflict},
+	}
+	for _, tt := range tests {
+		err := pkg.DBError(tt.err)
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.kind, err)
+		}
+		// исходная ошибка остается в цепочке
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v to wrap %v", tt.name, err, tt.err)
+		}
+	}
+
+	other := &pq.Error{Code: "40001"}
+	if err := pkg.DBError(other); err != other {
+		t.Errorf("expected unknown error unchanged, got %v", err)
+	}
+	if pkg.DBError(nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+
+	once := pkg.DBError(sql.ErrNoRows)
+	if err := pkg.DBError(fmt.Errorf("op: %w", once)); !errors.Is(err, once) || err.Error() != "op: not found: "+sql.ErrNoRows.Error() {
+		t.Errorf("expected translated error unchanged, got %v", err)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{pkg.DBError(sql.ErrNoRows), http.StatusNotFound},
+		{pkg.NewError(pkg.ErrConflict, "already exists"), http.StatusConflict},
+		{fmt.Errorf("op: %w", pkg.NewError(pkg.ErrValidation, "wrong rating")), http.StatusUnprocessableEntity},
+		{fmt.Errorf("op: %w", pkg.ErrPreconditionFailed), http.StatusPreconditionFailed},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if status := pkg.HTTPStatus(tt.err); status != tt.status {
+			t.Errorf("%v: expected %d, got %d", tt.err, tt.status, status)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	r := httptest.NewRequest("POST", "/user/film/add", nil)
+	w := httptest.NewRecorder()
+	pkg.WriteError(w, r, fmt.Errorf("film_repo.Add: %w", pkg.DBError(&pq.Error{Code: "23505"})), "can't add film")
+	problem := decodeProblem(t, w)
+	if w.Code != http.StatusConflict || problem.Detail != "can't add film: already exists" {
+		t.Errorf("unexpected response: %d %+v", w.Code, problem)
+	}
+
+	// внутренние ошибки не раскрываются клиенту
+	w = httptest.NewRecorder()
+	pkg.WriteError(w, r, errors.New("dial tcp: connection refused"), "can't add film")
+	problem = decodeProblem(t, w)
+	if w.Code != http.StatusInternalServerError || problem.Detail != "can't add film" {
+		t.Errorf("unexpected response: %d %+v", w.Code, problem)
+	}
+}
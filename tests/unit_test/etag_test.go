@@ -0,0 +1,39 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"filmoteka/pkg"
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		version int64
+		ok      bool
+	}{
+		{"", 3, true},
+		{`"3"`, 3, true},
+		{`"2"`, 3, false},
+		{`"1", "3"`, 3, true},
+		{"*", 3, true},
+		{`W/"3"`, 3, false},
+		{`3`, 3, false},
+	}
+
+	for _, test := range tests {
+		ctx := pkg.WithIfMatch(context.Background(), test.ifMatch)
+		err := pkg.CheckVersion(ctx, test.version)
+		if test.ok && err != nil {
+			t.Errorf("If-Match %s, version %d: unexpected error: %v", test.ifMatch, test.version, err)
+		}
+		if !test.ok && !errors.Is(err, pkg.ErrPreconditionFailed) {
+			t.Errorf("If-Match %s, version %d: expected ErrPreconditionFailed, got %v", test.ifMatch, test.version, err)
+		}
+	}
+
+	if etag := pkg.ETag(12); etag != `"12"` {
+		t.Errorf("expected ETag %q, got %q", `"12"`, etag)
+	}
+}
@@ -1,11 +1,19 @@
 package unit_test
 
 import (
+	"context"
+	"encoding/json"
 	"filmoteka/internal/actor"
+	"filmoteka/internal/audit"
 	"filmoteka/internal/film"
+	"filmoteka/internal/importer"
 	"filmoteka/pkg"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDateValidation_ValidDate(t *testing.T) {
@@ -19,26 +27,148 @@ func TestDateValidation_ValidDate(t *testing.T) {
 func TestDateValidation_InvalidDate(t *testing.T) {
 	date := "32.13.2022" // Неверный день и месяц
 	err := pkg.DateValidation(date)
-	expectedErr := "32.13.2022 is not a valid date\n"
+	expectedErr := "32.13.2022 is not a valid date"
 	if err == nil || err.Error() != expectedErr {
 		t.Errorf("expected error %q, got %v", expectedErr, err)
 	}
 
 	date = "01.01.20" // Неполный год
 	err = pkg.DateValidation(date)
-	expectedErr = "01.01.20 is not a valid date\n"
+	expectedErr = "01.01.20 is not a valid date"
 	if err == nil || err.Error() != expectedErr {
 		t.Errorf("expected error %q, got %v", expectedErr, err)
 	}
 
 	date = "01/01/2022" // Неверный разделитель
 	err = pkg.DateValidation(date)
-	expectedErr = "01/01/2022 is not a valid date\n"
+	expectedErr = "01/01/2022 is not a valid date"
 	if err == nil || err.Error() != expectedErr {
 		t.Errorf("expected error %q, got %v", expectedErr, err)
 	}
 }
 
+func TestDateValidation_ISODate(t *testing.T) {
+	err := pkg.DateValidation("2000-01-31")
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	err = pkg.DateValidation("2000-31-01")
+	if err == nil {
+		t.Error("expected error for 2000-31-01, got nil")
+	}
+}
+
+func TestDBDateAndScanDate(t *testing.T) {
+	if got := pkg.DBDate("31.01.2000"); got != "2000-01-31" {
+		t.Errorf("expected 2000-01-31, got %s", got)
+	}
+	if got := pkg.DBDate("2000-01-31"); got != "2000-01-31" {
+		t.Errorf("expected 2000-01-31, got %s", got)
+	}
+
+	var date string
+	err := pkg.ScanDate(&date).Scan(time.Date(2000, 1, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil || date != "31.01.2000" {
+		t.Errorf("expected 31.01.2000, got %q, %v", date, err)
+	}
+
+	if err := pkg.SetDateFormat("iso"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer pkg.SetDateFormat("legacy")
+
+	err = pkg.ScanDate(&date).Scan([]byte("2000-01-31"))
+	if err != nil || date != "2000-01-31" {
+		t.Errorf("expected 2000-01-31, got %q, %v", date, err)
+	}
+	err = pkg.ScanDate(&date).Scan(nil)
+	if err != nil || date != "" {
+		t.Errorf("expected empty date for NULL, got %q, %v", date, err)
+	}
+	if err := pkg.SetDateFormat("rfc"); err == nil {
+		t.Error("expected error for unknown date format, got nil")
+	}
+}
+
+func TestParseDate_PartialDates(t *testing.T) {
+	tests := []struct {
+		input, precision, legacy, iso string
+		approximate                   bool
+	}{
+		{"1920", pkg.PrecisionYear, "1920", "1920", false},
+		{"1920~", pkg.PrecisionYear, "1920~", "1920~", true},
+		{"1920-05", pkg.PrecisionMonth, "05.1920", "1920-05", false},
+		{"05.1920~", pkg.PrecisionMonth, "05.1920~", "1920-05~", true},
+		{"17.05.1920", pkg.PrecisionDay, "17.05.1920", "1920-05-17", false},
+		{"1920-05-17~", pkg.PrecisionDay, "17.05.1920~", "1920-05-17~", true},
+	}
+	defer pkg.SetDateFormat("legacy")
+
+	for _, test := range tests {
+		date, err := pkg.ParseDate(test.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.input, err)
+			continue
+		}
+		if date.Precision != test.precision || date.Approximate != test.approximate {
+			t.Errorf("%s: expected %s/%v, got %s/%v", test.input, test.precision, test.approximate, date.Precision, date.Approximate)
+		}
+
+		pkg.SetDateFormat("legacy")
+		if got := date.String(); got != test.legacy {
+			t.Errorf("%s: expected %s, got %s", test.input, test.legacy, got)
+		}
+		pkg.SetDateFormat("iso")
+		if got := date.String(); got != test.iso {
+			t.Errorf("%s: expected %s, got %s", test.input, test.iso, got)
+		}
+	}
+
+	// неполная дата хранится как первый день периода, поэтому сортируется вместе с полными
+	if got := pkg.DBDate("05.1920~"); got != "1920-05-01" {
+		t.Errorf("expected 1920-05-01, got %s", got)
+	}
+	if got := pkg.DBPrecision("1920"); got != pkg.PrecisionYear {
+		t.Errorf("expected year precision, got %s", got)
+	}
+
+	pkg.SetDateFormat("legacy")
+	var date string
+	err := pkg.ScanDate(&date).Scan("1920-05~")
+	if err != nil || date != "05.1920~" {
+		t.Errorf("expected 05.1920~, got %q, %v", date, err)
+	}
+
+	for _, input := range []string{"~", "1920~~", "13.1920", "192", "1920-5"} {
+		if err := pkg.DateValidation(input); err == nil {
+			t.Errorf("expected error for %q, got nil", input)
+		}
+	}
+}
+
+func TestByMethod(t *testing.T) {
+	handler := pkg.ByMethod(map[string]http.HandlerFunc{
+		http.MethodPost:   func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("post")) },
+		http.MethodDelete: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("delete")) },
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodDelete, "/", nil))
+	if rr.Body.String() != "delete" {
+		t.Errorf("expected delete handler, got %q", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+	if allow := rr.Header().Get("Allow"); allow != "DELETE, POST" {
+		t.Errorf("expected Allow header %q, got %q", "DELETE, POST", allow)
+	}
+}
+
 func TestConvertMapToActorsListWithFilms(t *testing.T) {
 	// Sample data
 	data := map[actor.Actor][]film.Film{
@@ -66,3 +196,81 @@ func TestConvertMapToActorsListWithFilms(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, result)
 	}
 }
+
+func TestAuditDiff(t *testing.T) {
+	before := json.RawMessage(`{"title":"Film1","rating":7,"actors":[{"name":"Actor1"}]}`)
+	after := json.RawMessage(`{"title":"Film1","rating":8,"actors":[{"name":"Actor1"}]}`)
+
+	diff, err := audit.Diff(before, after)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"rating":{"old":7,"new":8}}`
+	if string(diff) != expected {
+		t.Errorf("expected diff %s, got %s", expected, diff)
+	}
+
+	// удаление: все поля уходят в old, new пустой
+	diff, err = audit.Diff(before, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = `{"actors":{"old":[{"name":"Actor1"}],"new":null},"rating":{"old":7,"new":null},"title":{"old":"Film1","new":null}}`
+	if string(diff) != expected {
+		t.Errorf("expected diff %s, got %s", expected, diff)
+	}
+}
+
+type auditEntries []audit.Entry
+
+func (e auditEntries) Find(filter *audit.Filter) ([]audit.Entry, error) {
+	return e, nil
+}
+
+func TestAuditHandler_GetEntries(t *testing.T) {
+	handler := &audit.AuditHandler{AuditRepo: auditEntries{
+		{ID: 1, Entity: audit.EntityFilm, EntityID: 7, Action: audit.ActionCreate, Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}}
+
+	w := httptest.NewRecorder()
+	handler.GetEntries(w, httptest.NewRequest(http.MethodGet, "/admin/audit?entity=film", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != pkg.JSONContentType {
+		t.Errorf("expected content type %q, got %q", pkg.JSONContentType, contentType)
+	}
+	expected := `[{"id":1,"entity":"film","entity_id":7,"action":"create","time":"2024-01-02T03:04:05Z"}]`
+	if body := w.Body.String(); body != expected {
+		t.Errorf("expected body %s, got %s", expected, body)
+	}
+}
+
+type importRecords struct {
+	records []importer.Record
+}
+
+func (i *importRecords) Import(ctx context.Context, records []importer.Record, opts importer.Options) (*importer.Report, error) {
+	i.records = records
+	return &importer.Report{Created: len(records)}, nil
+}
+
+func TestImportHandler_ImportFilms(t *testing.T) {
+	repo := &importRecords{}
+	handler := &importer.ImportHandler{ImportRepo: repo}
+
+	body := `[{"title":"Film","description":"d","release_date":"2020","rating":5}]`
+	w := httptest.NewRecorder()
+	handler.ImportFilms(w, httptest.NewRequest(http.MethodPost, "/admin/film/import?format=json", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != pkg.JSONContentType {
+		t.Errorf("expected content type %q, got %q", pkg.JSONContentType, contentType)
+	}
+	if len(repo.records) != 1 || !strings.Contains(w.Body.String(), `"created":1`) {
+		t.Errorf("unexpected import: %v, body %s", repo.records, w.Body.String())
+	}
+}
@@ -0,0 +1,55 @@
+package unit_test
+
+import (
+	"filmoteka/internal/history"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistoryParseLetterboxdDiary(t *testing.T) {
+	data := `Date,Name,Year,Letterboxd URI,Rating,Rewatch,Tags,Watched Date
+2021-01-05,"Film, with comma",1999,https://boxd.it/1,0.5,,,2021-01-04
+2021-01-06,Film2,2005,https://boxd.it/2,,Yes,,2021-01-06
+`
+	entries, err := history.Parse(strings.NewReader(data), history.SourceLetterboxd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []history.Entry{
+		{Row: 2, Title: "Film, with comma", Year: "1999", Rating: 1, WatchedAt: "2021-01-04"},
+		{Row: 3, Title: "Film2", Year: "2005", WatchedAt: "2021-01-06"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries)
+	}
+}
+
+func TestHistoryParseTrakt(t *testing.T) {
+	data := `[
+{"id":1,"watched_at":"2020-05-17T20:15:00.000Z","action":"watch","type":"movie","movie":{"title":"Film1","year":2010}},
+{"id":2,"watched_at":"2020-05-18T20:15:00.000Z","action":"watch","type":"episode","episode":{"title":"Pilot"}},
+{"rated_at":"2020-06-01T10:00:00.000Z","rating":11,"type":"movie","movie":{"title":"Film2","year":2012}}
+]`
+	entries, err := history.Parse(strings.NewReader(data), history.SourceTrakt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	expected := history.Entry{Row: 1, Title: "Film1", Year: "2010", WatchedAt: "2020-05-17"}
+	if !reflect.DeepEqual(entries[0], expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries[0])
+	}
+	if entries[1].Row != 3 || entries[1].Err == nil {
+		t.Errorf("expected rating error on row 3, got %+v", entries[1])
+	}
+
+	_, err = history.Parse(strings.NewReader(data), "imdb")
+	if err == nil {
+		t.Error("expected error for unknown source, got nil")
+	}
+}
@@ -0,0 +1,59 @@
+package unit_test
+
+import (
+	"compress/gzip"
+	"filmoteka/internal/imdb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImdbTSVReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "title.basics.tsv.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	gz.Write([]byte("tconst\ttitleType\tprimaryTitle\tstartYear\n" +
+		"tt0000001\tmovie\t\"Quoted\" title\t1894\n" +
+		"tt0000002\tshort\tNo year\t\\N\n"))
+	gz.Close()
+	file.Close()
+
+	reader, err := imdb.OpenTSV(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	if err := reader.Require("tconst", "startYear"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := reader.Require("genres"); err == nil {
+		t.Error("expected error for missing column, got nil")
+	}
+
+	if !reader.Next() || reader.Line() != 1 || reader.Get("primaryTitle") != `"Quoted" title` ||
+		imdb.YearDate(reader.Get("startYear")) != "1894-01-01" {
+		t.Errorf("unexpected first row: line %d, title %q", reader.Line(), reader.Get("primaryTitle"))
+	}
+	if !reader.Next() || reader.Get("startYear") != "" || imdb.YearDate(reader.Get("startYear")) != "" {
+		t.Errorf("expected empty startYear, got %q", reader.Get("startYear"))
+	}
+	if reader.Next() {
+		t.Error("expected end of file")
+	}
+	if err := reader.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestImdbGender(t *testing.T) {
+	cases := map[string]string{"actor": "man", "actress": "woman", "director": ""}
+	for category, expected := range cases {
+		if got := imdb.Gender(category); got != expected {
+			t.Errorf("Gender(%q) = %q, expected %q", category, got, expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package unit_test
+
+import (
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/internal/importer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImporterParseCSV(t *testing.T) {
+	data := `title,description,release_date,rating,actor_name,actor_gender,actor_birth_date
+Film1,Description1,01.01.2000,8,Actor1,man,12.03.1995
+Film2,Description2,02.02.2002,x,,,
+Film1,Description1,01.01.2000,8,Actor2,woman,10.05.1989
+`
+	records, err := importer.Parse(strings.NewReader(data), importer.FormatCSV)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	expected := film.Film{
+		Title:       "Film1",
+		Description: "Description1",
+		ReleaseDate: "01.01.2000",
+		Rating:      8,
+		Actors: []actor.Actor{
+			{Name: "Actor1", Gender: "man", BirthDate: "12.03.1995"},
+			{Name: "Actor2", Gender: "woman", BirthDate: "10.05.1989"},
+		},
+	}
+	if records[0].Row != 2 || records[0].Err != nil || !reflect.DeepEqual(records[0].Film, expected) {
+		t.Errorf("expected film %v on row 2, got %+v", expected, records[0])
+	}
+	if records[1].Row != 3 || records[1].Err == nil {
+		t.Errorf("expected rating error on row 3, got %+v", records[1])
+	}
+
+	_, err = importer.Parse(strings.NewReader("name,rating\n"), importer.FormatCSV)
+	if err == nil {
+		t.Error("expected error for csv without required columns, got nil")
+	}
+}
+
+func TestImporterParseJSON(t *testing.T) {
+	records, err := importer.Parse(strings.NewReader(`[{"title":"Film1","release_date":"01.01.2000","rating":8},{"title":1}]`), importer.FormatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 || records[0].Film.Title != "Film1" || records[0].Err != nil || records[1].Row != 2 || records[1].Err == nil {
+		t.Errorf("unexpected records: %+v", records)
+	}
+
+	records, err = importer.Parse(strings.NewReader("{\"title\":\"Film1\"}\n\n{\"title\":\"Film2\"}\n"), importer.FormatNDJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 || records[1].Row != 3 || records[1].Film.Title != "Film2" {
+		t.Errorf("unexpected records: %+v", records)
+	}
+}
+
+func TestImporterValidateFilm(t *testing.T) {
+	valid := film.Film{
+		Title:       "Film1",
+		Description: "Description",
+		ReleaseDate: "01.01.2000",
+		Rating:      10,
+		Actors:      []actor.Actor{{Name: "Actor1", Gender: "woman", BirthDate: "10.05.1989"}},
+	}
+	if err := importer.ValidateFilm(&valid); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	invalid := []film.Film{
+		{Title: "", Description: "d", ReleaseDate: "01.01.2000", Rating: 5},
+		{Title: "Film1", ReleaseDate: "01.01.2000", Rating: 5},
+		{Title: "Film1", Description: "d", ReleaseDate: "2000-13-01", Rating: 5},
+		{Title: "Film1", Description: "d", ReleaseDate: "01.01.2000", Rating: 11},
+		{Title: "Film1", Description: "d", ReleaseDate: "01.01.2000", Rating: 5, Actors: []actor.Actor{{Name: "Actor1", Gender: "other", BirthDate: "10.05.1989"}}},
+		{Title: "Film1", Description: "d", ReleaseDate: "01.01.2000", Rating: 5, Actors: []actor.Actor{{Name: "Actor1", BirthDate: "10.05.1989"}}},
+	}
+	for _, f := range invalid {
+		if err := importer.ValidateFilm(&f); err == nil {
+			t.Errorf("expected error for %+v, got nil", f)
+		}
+	}
+}
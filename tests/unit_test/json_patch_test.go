@@ -0,0 +1,110 @@
+package unit_test
+
+import (
+	"errors"
+	"filmoteka/pkg"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// примеры из приложения A RFC 7396
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		result, err := pkg.MergePatch([]byte(test.doc), []byte(test.patch))
+		if err != nil {
+			t.Errorf("%s + %s: unexpected error: %v", test.doc, test.patch, err)
+			continue
+		}
+		if string(result) != test.expected {
+			t.Errorf("%s + %s: expected %s, got %s", test.doc, test.patch, test.expected, result)
+		}
+	}
+
+	_, err := pkg.MergePatch([]byte(`{}`), []byte(`{"a":`))
+	if !errors.Is(err, pkg.ErrInvalidPatch) {
+		t.Errorf("expected ErrInvalidPatch, got %v", err)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	// примеры из приложения A RFC 6902
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"foo":{"bar":"baz"}}`, `[{"op":"copy","from":"/foo","path":"/qux"},{"op":"add","path":"/qux/bar","value":1}]`,
+			`{"foo":{"bar":"baz"},"qux":{"bar":1}}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"replace","path":"/~1","value":8}]`, `{"/":8,"~1":10}`},
+		{`{"rating":7}`, `[{"op":"replace","path":"","value":{"rating":9}}]`, `{"rating":9}`},
+	}
+
+	for _, test := range tests {
+		result, err := pkg.JSONPatch([]byte(test.doc), []byte(test.patch))
+		if err != nil {
+			t.Errorf("%s + %s: unexpected error: %v", test.doc, test.patch, err)
+			continue
+		}
+		if string(result) != test.expected {
+			t.Errorf("%s + %s: expected %s, got %s", test.doc, test.patch, test.expected, result)
+		}
+	}
+}
+
+func TestJSONPatch_Errors(t *testing.T) {
+	tests := []struct {
+		doc, patch string
+		expected   error
+	}{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, pkg.ErrPatchTestFailed},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, pkg.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, pkg.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, pkg.ErrInvalidPatch},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/01","value":1}]`, pkg.ErrInvalidPatch},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, pkg.ErrInvalidPatch},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, pkg.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, pkg.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"rename","path":"/foo"}]`, pkg.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"foo","value":1}]`, pkg.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `{"op":"add"}`, pkg.ErrInvalidPatch},
+	}
+
+	for _, test := range tests {
+		_, err := pkg.JSONPatch([]byte(test.doc), []byte(test.patch))
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s + %s: expected %v, got %v", test.doc, test.patch, test.expected, err)
+		}
+	}
+
+	_, err := pkg.ApplyPatch("application/json", []byte(`{}`), []byte(`{}`))
+	if !errors.Is(err, pkg.ErrUnsupportedPatch) {
+		t.Errorf("expected ErrUnsupportedPatch, got %v", err)
+	}
+}
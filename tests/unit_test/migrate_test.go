@@ -0,0 +1,45 @@
+package unit_test
+
+import (
+	"filmoteka/internal/migrate"
+	"filmoteka/schema"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrateLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"000002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"000001_init.up.sql":     {Data: []byte("CREATE TABLE a (id INT);")},
+		"000001_init.down.sql":   {Data: []byte("DROP TABLE a;")},
+		"schema.go":              {Data: []byte("package schema")},
+	}
+
+	migrations, err := migrate.Load(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "second" ||
+		migrations[1].Down != "DROP TABLE b;" {
+		t.Errorf("unexpected migrations: %+v", migrations)
+	}
+
+	delete(fsys, "000002_second.down.sql")
+	_, err = migrate.Load(fsys)
+	if err == nil {
+		t.Error("expected error for migration without down file, got nil")
+	}
+}
+
+func TestMigrateEmbeddedSchema(t *testing.T) {
+	migrations, err := migrate.Load(schema.Migrations)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("expected migration %d, got %d_%s", i+1, migration.Version, migration.Name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package unit_test
+
+import (
+	"encoding/json"
+	"filmoteka/pkg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) pkg.Problem {
+	t.Helper()
+	if contentType := w.Header().Get("Content-Type"); contentType != pkg.ProblemContentType {
+		t.Fatalf("expected content type %q, got %q", pkg.ProblemContentType, contentType)
+	}
+	var problem pkg.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("can't decode problem: %v", err)
+	}
+	return problem
+}
+
+func TestWriteProblem(t *testing.T) {
+	handler := pkg.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pkg.WriteProblem(w, r, http.StatusBadRequest, "empty title")
+	}))
+
+	req := httptest.NewRequest("GET", "/user/film", nil)
+	req.Header.Set(pkg.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	problem := decodeProblem(t, w)
+	expected := pkg.Problem{
+		Type:      "about:blank",
+		Title:     "Bad Request",
+		Status:    http.StatusBadRequest,
+		Detail:    "empty title",
+		Instance:  "/user/film",
+		RequestID: "req-1",
+	}
+	if w.Code != http.StatusBadRequest || problem.Type != expected.Type || problem.Title != expected.Title ||
+		problem.Status != expected.Status || problem.Detail != expected.Detail ||
+		problem.Instance != expected.Instance || problem.RequestID != expected.RequestID {
+		t.Errorf("expected %+v, got %d %+v", expected, w.Code, problem)
+	}
+	if id := w.Header().Get(pkg.RequestIDHeader); id != "req-1" {
+		t.Errorf("expected request id header %q, got %q", "req-1", id)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := pkg.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = pkg.RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"from client", "abc-123", true},
+		{"empty", "", false},
+		{"control characters", "abc\x01", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/user/film", nil)
+			req.Header.Set(pkg.RequestIDHeader, tt.header)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			id := w.Header().Get(pkg.RequestIDHeader)
+			if id == "" || id != seen {
+				t.Fatalf("response id %q doesn't match context id %q", id, seen)
+			}
+			if (id == tt.header) != tt.keep {
+				t.Errorf("header %q: got id %q", tt.header, id)
+			}
+		})
+	}
+}
+
+func TestWriteValidationProblem(t *testing.T) {
+	req := httptest.NewRequest("POST", "/user/film/add", nil)
+	w := httptest.NewRecorder()
+	pkg.WriteValidationProblem(w, req, []pkg.FieldError{
+		{Field: "release_date", Message: "wrong date format"},
+		{Field: "actors[0].birth_date", Message: "wrong date format"},
+	})
+
+	problem := decodeProblem(t, w)
+	if w.Code != http.StatusBadRequest || len(problem.Errors) != 2 || problem.Errors[1].Field != "actors[0].birth_date" {
+		t.Errorf("unexpected response: %d %+v", w.Code, problem)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	w := httptest.NewRecorder()
+	pkg.NotFound(w, httptest.NewRequest("GET", "/user/unknown", nil))
+
+	problem := decodeProblem(t, w)
+	if w.Code != http.StatusNotFound || problem.Instance != "/user/unknown" {
+		t.Errorf("unexpected response: %d %+v", w.Code, problem)
+	}
+}
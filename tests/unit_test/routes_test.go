@@ -0,0 +1,49 @@
+package unit_test
+
+import (
+	"filmoteka/pkg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestByPath(t *testing.T) {
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + ":" + pkg.PathValue(r, "id") + ":" + pkg.PathValue(r, "actorId")))
+		}
+	}
+	router := pkg.ByPath(map[string]http.HandlerFunc{
+		"/films/{id}":                  handler("film"),
+		"/films/{id}/revisions":        handler("revisions"),
+		"/films/{id}/{actorId}":        handler("any"),
+		"/films/{id}/actors/{actorId}": handler("actor"),
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/films/1", http.StatusOK, "film:1:"},
+		{"/films/1/", http.StatusOK, "film:1:"},
+		{"/films/1/revisions", http.StatusOK, "revisions:1:"},
+		{"/films/1/5", http.StatusOK, "any:1:5"},
+		{"/films/1/actors/5", http.StatusOK, "actor:1:5"},
+		{"/films", http.StatusNotFound, ""},
+		{"/films//revisions", http.StatusNotFound, ""},
+		{"/films/1/revisions/diff/x", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		router(rr, httptest.NewRequest("GET", test.path, nil))
+		if rr.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, rr.Code)
+			continue
+		}
+		if test.body != "" && rr.Body.String() != test.body {
+			t.Errorf("%s: expected body %q, got %q", test.path, test.body, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package unit_test
+
+import (
+	"filmoteka/internal/actor"
+	"filmoteka/internal/film"
+	"filmoteka/pkg"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := film.Film{
+		Title:       "Film1",
+		Description: "Description",
+		ReleaseDate: "01.01.2000",
+		Rating:      10,
+		Actors:      []actor.Actor{{Name: "Actor1", Gender: "woman", BirthDate: "1989~"}},
+	}
+	if errs := pkg.Validate(&valid); len(errs) != 0 {
+		t.Errorf("expected no errors, got %+v", errs)
+	}
+
+	tests := []struct {
+		name     string
+		film     film.Film
+		expected []pkg.FieldError
+	}{
+		{
+			name: "empty fields",
+			film: film.Film{},
+			expected: []pkg.FieldError{
+				{Field: "title", Message: "must not be empty"},
+				{Field: "description", Message: "must not be empty"},
+				{Field: "release_date", Message: "must not be empty"},
+				{Field: "rating", Message: "must not be empty"},
+			},
+		},
+		{
+			name: "rules",
+			film: film.Film{Title: "Film1", Description: "d", ReleaseDate: "2000-13-01", Rating: 11},
+			expected: []pkg.FieldError{
+				{Field: "release_date", Message: "2000-13-01 is not a valid date"},
+				{Field: "rating", Message: "must be at most 10"},
+			},
+		},
+		{
+			name: "nested actors",
+			film: film.Film{Title: "Film1", Description: "d", ReleaseDate: "2000", Rating: 1, Actors: []actor.Actor{
+				{Name: "Actor1", Gender: "man", BirthDate: "01.01.1990"},
+				{Gender: "other", BirthDate: "yesterday"},
+			}},
+			expected: []pkg.FieldError{
+				{Field: "actors[1].name", Message: "must not be empty"},
+				{Field: "actors[1].gender", Message: "must be one of: man, woman"},
+				{Field: "actors[1].birth_date", Message: "yesterday is not a valid date"},
+			},
+		},
+		{
+			name: "actor without gender",
+			film: film.Film{Title: "Film1", Description: "d", ReleaseDate: "2000", Rating: 1, Actors: []actor.Actor{
+				{Name: "Actor1", BirthDate: "01.01.1990"},
+			}},
+			expected: []pkg.FieldError{
+				{Field: "actors[0].gender", Message: "must not be empty"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := pkg.Validate(&tt.film); !reflect.DeepEqual(errs, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, errs)
+			}
+		})
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	key := film.Film{Title: "Film1", ReleaseDate: "01.01.2000"}
+	if errs := pkg.ValidateFields(&key, "title", "release_date"); len(errs) != 0 {
+		t.Errorf("expected no errors, got %+v", errs)
+	}
+
+	key.Title = ""
+	expected := []pkg.FieldError{{Field: "title", Message: "must not be empty"}}
+	if errs := pkg.ValidateFields(&key, "title", "release_date"); !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, errs)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	var value struct {
+		Name  string   `json:"name" validate:"min=2,max=3"`
+		Tags  []string `json:"tags" validate:"max=1"`
+		Skip  string   `json:"-" notempty:"true"`
+		Plain int      `notempty:"true"`
+	}
+	value.Name = "ёжик"
+	value.Tags = []string{"a", "b"}
+	value.Plain = 1
+
+	expected := []pkg.FieldError{
+		{Field: "name", Message: "must be at most 3 characters"},
+		{Field: "tags", Message: "must be at most 1 items"},
+	}
+	if errs := pkg.Validate(value); !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, errs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on unknown rule")
+		}
+	}()
+	pkg.Validate(struct {
+		Name string `validate:"email"`
+	}{Name: "a"})
+}